@@ -1,7 +1,14 @@
 package tama
 
 import (
+	"bytes"
+	"errors"
+	"github.com/hyusuk/tama/parser"
+	"github.com/hyusuk/tama/printer"
 	"github.com/hyusuk/tama/types"
+	"io"
+	"math"
+	"strings"
 )
 
 func (s *State) OpenBase() *State {
@@ -13,25 +20,154 @@ func (s *State) OpenBase() *State {
 	s.registerSyntax("quote", types.NewSyntax("quote", nil))
 	s.registerSyntax("if", types.NewSyntax("if", nil))
 	s.registerSyntax("call/cc", types.NewSyntax("call/cc", nil))
+	s.registerSyntax("delay", types.NewSyntax("delay", nil))
+	s.registerSyntax("delay-force", types.NewSyntax("delay-force", nil))
+	s.registerSyntax("define-record-type", types.NewSyntax("define-record-type", nil))
+	s.registerSyntax("match", types.NewSyntax("match", nil))
 
 	// set procedures
+	s.RegisterFunc("eq?", 2, 2, fnIsEqv)
+	s.RegisterFunc("eqv?", 2, 2, fnIsEqv)
+	s.RegisterFunc("equal?", 2, 2, fnIsEqual)
 	s.RegisterFunc("+", 0, -1, fnAdd)
 	s.RegisterFunc("-", 1, -1, fnSub)
 	s.RegisterFunc("*", 0, -1, fnMul)
 	s.RegisterFunc("/", 1, -1, fnDiv)
+	s.RegisterFunc("pair?", 1, 1, fnIsPair)
 	s.RegisterFunc("cons", 2, 2, fnCons)
 	s.RegisterFunc("car", 1, 1, fnCar)
 	s.RegisterFunc("cdr", 1, 1, fnCdr)
+	s.RegisterFunc("set-car!", 2, 2, fnSetCar)
+	s.RegisterFunc("set-cdr!", 2, 2, fnSetCdr)
+	for _, path := range cxrPaths() {
+		s.RegisterFunc("c"+path+"r", 1, 1, genFnCxr(path))
+	}
+	s.RegisterFunc("null?", 1, 1, fnIsNull)
+	s.RegisterFunc("list?", 1, 1, fnIsList)
+	s.RegisterFunc("make-list", 1, 2, fnMakeList)
+	s.RegisterFunc("list", 0, -1, fnList)
+	s.RegisterFunc("length", 1, 1, fnLength)
+	s.RegisterFunc("append", 0, -1, fnAppend)
+	s.RegisterFunc("reverse", 1, 1, fnReverse)
+	s.RegisterFunc("list-tail", 2, 2, fnListTail)
+	s.RegisterFunc("list-ref", 2, 2, fnListRef)
+	s.RegisterFunc("list-copy", 1, 1, fnListCopy)
+	s.RegisterFunc("memq", 2, 2, genFnMember("memq"))
+	s.RegisterFunc("memv", 2, 2, genFnMember("memv"))
+	s.RegisterFunc("member", 2, 3, genFnMember("member"))
+	s.RegisterFunc("assq", 2, 2, genFnAssoc("assq"))
+	s.RegisterFunc("assv", 2, 2, genFnAssoc("assv"))
+	s.RegisterFunc("assoc", 2, 3, genFnAssoc("assoc"))
 	s.RegisterFunc("=", 2, -1, fnNumEq)
 	s.RegisterFunc("<", 2, -1, genFnComp("<"))
 	s.RegisterFunc(">", 2, -1, genFnComp(">"))
 	s.RegisterFunc("<=", 2, -1, genFnComp("<="))
 	s.RegisterFunc(">=", 2, -1, genFnComp(">="))
+	s.RegisterFunc("make-string", 1, 2, fnMakeStr)
+	s.RegisterFunc("string", 0, -1, fnStr)
 	s.RegisterFunc("string-length", 1, 1, fnStrLen)
+	s.RegisterFunc("string-ref", 2, 2, fnStrRef)
+	s.RegisterFunc("string-set!", 3, 3, fnStrSet)
+	s.RegisterFunc("substring", 3, 3, fnSubstr)
+	s.RegisterFunc("string-fill!", 2, 4, fnStrFill)
+	s.RegisterFunc("string-copy!", 3, 5, fnStrCopyTo)
+	s.RegisterFunc("procedure?", 1, 1, fnIsProc)
+	s.RegisterFunc("apply", 2, -1, fnApply)
+	apply, _ := s.GetGlobal("apply")
+	s.applyCl = apply.(*types.Closure)
+	s.RegisterFunc("values", 0, -1, fnValues)
+	s.RegisterFunc("call-with-values", 2, 2, fnCallWithValues)
+	s.RegisterFunc("dynamic-wind", 3, 3, fnDynamicWind)
+	s.RegisterFunc("map", 2, -1, fnMap)
+	s.RegisterFunc("for-each", 2, -1, fnForEach)
+	s.RegisterFunc("force", 1, 1, fnForce)
+	s.RegisterFunc("make-promise", 1, 1, fnMakePromise)
+	s.RegisterFunc("promise?", 1, 1, fnIsPromise)
+	// used by define-record-type
+	s.RegisterFunc("make-record-type", 2, 2, fnMakeRecordType)
+	s.RegisterFunc("record-constructor", 2, 2, fnRecordConstructor)
+	s.RegisterFunc("record-predicate", 1, 1, fnRecordPredicate)
+	s.RegisterFunc("record-accessor", 2, 2, genFnRecordField(false))
+	s.RegisterFunc("record-modifier", 2, 2, genFnRecordField(true))
+	s.RegisterFunc("file-error?", 1, 1, genFnIsError(types.ErrFile))
+	s.RegisterFunc("read-error?", 1, 1, genFnIsError(types.ErrRead))
+	s.RegisterFunc("eof-object", 0, 0, fnEOFObject)
+	s.RegisterFunc("eof-object?", 1, 1, fnIsEOFObject)
+	s.RegisterFunc("current-input-port", 0, 0, fnCurrentInputPort)
+	s.RegisterFunc("current-output-port", 0, 0, fnCurrentOutputPort)
+	s.RegisterFunc("current-error-port", 0, 0, fnCurrentErrorPort)
+	s.RegisterFunc("port?", 1, 1, fnIsPort)
+	s.RegisterFunc("input-port?", 1, 1, genFnPortPred((*types.Port).IsInput))
+	s.RegisterFunc("output-port?", 1, 1, genFnPortPred((*types.Port).IsOutput))
+	s.RegisterFunc("textual-port?", 1, 1, genFnPortPred(func(p *types.Port) bool { return !p.IsBinary() }))
+	s.RegisterFunc("binary-port?", 1, 1, genFnPortPred((*types.Port).IsBinary))
+	s.RegisterFunc("input-port-open?", 1, 1, genFnPortOpen(true))
+	s.RegisterFunc("output-port-open?", 1, 1, genFnPortOpen(false))
+	s.RegisterFunc("close-port", 1, 1, fnClosePort)
+	s.RegisterFunc("close-input-port", 1, 1, fnClosePort)
+	s.RegisterFunc("close-output-port", 1, 1, fnClosePort)
+	s.RegisterFunc("read", 0, 1, fnRead)
+	s.RegisterFunc("read-char", 0, 1, genFnReadChar(false))
+	s.RegisterFunc("peek-char", 0, 1, genFnReadChar(true))
+	s.RegisterFunc("read-line", 0, 1, fnReadLine)
+	s.RegisterFunc("read-string", 1, 2, fnReadString)
+	s.RegisterFunc("read-u8", 0, 1, genFnReadU8(false))
+	s.RegisterFunc("peek-u8", 0, 1, genFnReadU8(true))
+	s.RegisterFunc("read-bytevector", 1, 2, fnReadBytevector)
+	s.RegisterFunc("newline", 0, 1, fnNewline)
+	s.RegisterFunc("write-char", 1, 2, fnWriteChar)
+	s.RegisterFunc("write-string", 1, 4, fnWriteString)
+	s.RegisterFunc("write-u8", 1, 2, fnWriteU8)
+	s.RegisterFunc("write-bytevector", 1, 4, fnWriteBytevector)
+	s.RegisterFunc("display", 1, 2, genFnWrite(printer.DisplayString))
+	s.RegisterFunc("write", 1, 2, genFnWrite(printer.WriteString))
+	s.RegisterFunc("write-shared", 1, 2, genFnWrite((&printer.Printer{Mode: printer.Write, Labels: printer.LabelShared}).Sprint))
+	s.RegisterFunc("write-simple", 1, 2, genFnWrite((&printer.Printer{Mode: printer.Write, Labels: printer.LabelNone}).Sprint))
+	s.RegisterFunc("flush-output-port", 0, 1, fnFlushOutputPort)
+	s.RegisterFunc("open-input-string", 1, 1, fnOpenInputString)
+	s.RegisterFunc("open-output-string", 0, 0, fnOpenOutputString)
+	s.RegisterFunc("get-output-string", 1, 1, fnGetOutputString)
+	s.RegisterFunc("open-input-bytevector", 1, 1, fnOpenInputBytevector)
+	s.RegisterFunc("open-output-bytevector", 0, 0, fnOpenOutputBytevector)
+	s.RegisterFunc("get-output-bytevector", 1, 1, fnGetOutputBytevector)
+	s.RegisterFunc("call-with-output-string", 1, 1, fnCallWithOutputString)
+	s.RegisterFunc("with-output-to-string", 1, 1, fnWithOutputToString)
+	s.RegisterFunc("vector?", 1, 1, fnIsVec)
+	s.RegisterFunc("make-vector", 1, 2, fnMakeVec)
+	s.RegisterFunc("vector", 0, -1, fnVec)
+	s.RegisterFunc("vector-length", 1, 1, fnVecLen)
 	s.RegisterFunc("vector-ref", 2, 2, fnVecRef)
+	s.RegisterFunc("vector-set!", 3, 3, fnVecSet)
+	s.RegisterFunc("vector->list", 1, 3, fnVecToList)
+	s.RegisterFunc("list->vector", 1, 1, fnListToVec)
+	s.RegisterFunc("vector-copy", 1, 3, fnVecCopy)
+	s.RegisterFunc("vector-copy!", 3, 5, fnVecCopyTo)
+	s.RegisterFunc("vector-append", 0, -1, fnVecAppend)
+	s.RegisterFunc("vector-fill!", 2, 4, fnVecFill)
+	s.RegisterFunc("vector-map", 2, -1, fnVecMap)
+	s.RegisterFunc("vector-for-each", 2, -1, fnVecForEach)
+	// SRFI-133
+	s.RegisterFunc("vector-count", 2, -1, fnVecCount)
+	s.RegisterFunc("vector-binary-search", 3, 5, fnVecBinarySearch)
 	return s
 }
 
+// 6.1. Equivalence predicates
+
+func fnIsEqv(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.Eqv(args[0], args[1])), nil
+}
+
+func fnIsEqual(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.Equal(args[0], args[1])), nil
+}
+
+// 6.4. Pairs and lists
+
+func fnIsPair(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.IsPair(args[0])), nil
+}
+
 func fnCons(s *State, args []types.Object) (types.Object, error) {
 	return types.Cons(args[0], args[1]), nil
 }
@@ -52,6 +188,241 @@ func fnCdr(s *State, args []types.Object) (types.Object, error) {
 	return pair.Cdr(), nil
 }
 
+func fnSetCar(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyPair, args[0]); err != nil {
+		return nil, err
+	}
+	if err := args[0].(*types.Pair).SetCar(args[1]); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnSetCdr(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyPair, args[0]); err != nil {
+		return nil, err
+	}
+	if err := args[0].(*types.Pair).SetCdr(args[1]); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// cxrPaths returns the paths of the car and cdr compositions from caar to cddddr.
+// e.g. "ad" for cadr
+func cxrPaths() []string {
+	paths := []string{}
+	prev := []string{""}
+	for depth := 1; depth <= 4; depth++ {
+		next := []string{}
+		for _, p := range prev {
+			next = append(next, p+"a", p+"d")
+		}
+		if depth >= 2 {
+			paths = append(paths, next...)
+		}
+		prev = next
+	}
+	return paths
+}
+
+// genFnCxr generates the composition of car and cdr. (e.g. cadr for "ad")
+func genFnCxr(path string) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		obj := args[0]
+		for i := len(path) - 1; i >= 0; i-- {
+			pair, ok := obj.(*types.Pair)
+			if !ok {
+				return nil, types.NewTypeError("pair required, but got %v", obj)
+			}
+			if path[i] == 'a' {
+				obj = pair.Car()
+			} else {
+				obj = pair.Cdr()
+			}
+		}
+		return obj, nil
+	}
+}
+
+func fnIsNull(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.IsNull(args[0])), nil
+}
+
+func fnIsList(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.IsList(args[0])), nil
+}
+
+func fnMakeList(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	var fill types.Object = types.UndefinedObject
+	if len(args) > 1 {
+		fill = args[1]
+	}
+	elems := make([]types.Object, k)
+	for i := range elems {
+		elems[i] = fill
+	}
+	return types.List(elems...), nil
+}
+
+func fnList(s *State, args []types.Object) (types.Object, error) {
+	return types.List(args...), nil
+}
+
+func fnLength(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(len(elems)), nil
+}
+
+// (append list ... obj)
+// The last argument is shared with the result, the others are copied.
+func fnAppend(s *State, args []types.Object) (types.Object, error) {
+	if len(args) == 0 {
+		return types.NilObject, nil
+	}
+	result := args[len(args)-1]
+	for i := len(args) - 2; i >= 0; i-- {
+		elems, err := toSlice(args[i])
+		if err != nil {
+			return nil, err
+		}
+		result = types.ListWithTail(result, elems...)
+	}
+	return result, nil
+}
+
+func fnReverse(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var result types.Object = types.NilObject
+	for _, elem := range elems {
+		result = types.Cons(elem, result)
+	}
+	return result, nil
+}
+
+// listTail returns the k-th cdr of list.
+func listTail(list types.Object, kObj types.Object) (types.Object, error) {
+	k, err := toIndex(kObj, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < k; i++ {
+		pair, ok := list.(*types.Pair)
+		if !ok {
+			return nil, types.NewInternalError("index out of range: %d", k)
+		}
+		list = pair.Cdr()
+	}
+	return list, nil
+}
+
+func fnListTail(s *State, args []types.Object) (types.Object, error) {
+	return listTail(args[0], args[1])
+}
+
+func fnListRef(s *State, args []types.Object) (types.Object, error) {
+	tail, err := listTail(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	pair, ok := tail.(*types.Pair)
+	if !ok {
+		return nil, types.NewInternalError("index out of range: %v", args[1])
+	}
+	return pair.Car(), nil
+}
+
+// (list-copy obj)
+// Only the pairs of the list spine are copied. An improper tail is shared.
+func fnListCopy(s *State, args []types.Object) (types.Object, error) {
+	if pair, ok := args[0].(*types.Pair); ok && pair.Len() < 0 {
+		return nil, types.NewTypeError("list required, but got %v", args[0])
+	}
+	elems := []types.Object{}
+	obj := args[0]
+	for pair, ok := obj.(*types.Pair); ok; pair, ok = obj.(*types.Pair) {
+		elems = append(elems, pair.Car())
+		obj = pair.Cdr()
+	}
+	return types.ListWithTail(obj, elems...), nil
+}
+
+// equivalence returns the equivalence function of the procedure name,
+// or the function calling the compare procedure if it is given.
+func (s *State) equivalence(name string, compare []types.Object) func(a, b types.Object) (bool, error) {
+	if len(compare) > 0 {
+		return func(a, b types.Object) (bool, error) {
+			v, err := s.Call(compare[0], a, b)
+			if err != nil {
+				return false, err
+			}
+			return types.IsTruthy(v), nil
+		}
+	}
+	if name == "member" || name == "assoc" {
+		return func(a, b types.Object) (bool, error) { return types.Equal(a, b), nil }
+	}
+	return func(a, b types.Object) (bool, error) { return types.Eqv(a, b), nil }
+}
+
+// genFnMember generates (member obj list [compare]), memq and memv.
+func genFnMember(name string) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		equiv := s.equivalence(name, args[2:])
+		list := args[1]
+		for pair, ok := list.(*types.Pair); ok; pair, ok = list.(*types.Pair) {
+			same, err := equiv(args[0], pair.Car())
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				return pair, nil
+			}
+			list = pair.Cdr()
+		}
+		if !types.IsNull(list) {
+			return nil, types.NewTypeError("list required, but got %v", args[1])
+		}
+		return types.Boolean(false), nil
+	}
+}
+
+// genFnAssoc generates (assoc obj alist [compare]), assq and assv.
+func genFnAssoc(name string) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		equiv := s.equivalence(name, args[2:])
+		list := args[1]
+		for pair, ok := list.(*types.Pair); ok; pair, ok = list.(*types.Pair) {
+			assoc, ok := pair.Car().(*types.Pair)
+			if !ok {
+				return nil, types.NewTypeError("pair required, but got %v", pair.Car())
+			}
+			same, err := equiv(args[0], assoc.Car())
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				return assoc, nil
+			}
+			list = pair.Cdr()
+		}
+		if !types.IsNull(list) {
+			return nil, types.NewTypeError("list required, but got %v", args[1])
+		}
+		return types.Boolean(false), nil
+	}
+}
+
 func fnAdd(s *State, args []types.Object) (types.Object, error) {
 	if err := types.AssertType(types.TyNumber, args...); err != nil {
 		return nil, err
@@ -156,29 +527,1131 @@ func genFnComp(name string) GoFunc {
 	}
 }
 
-// 6.3.5. Strings
+// 6.10. Control features
 
-func fnStrLen(s *State, args []types.Object) (types.Object, error) {
-	if err := types.AssertType(types.TyString, args[0]); err != nil {
+func fnIsProc(s *State, args []types.Object) (types.Object, error) {
+	switch args[0].Type() {
+	case types.TyClosure, types.TyContinuation:
+		return types.Boolean(true), nil
+	}
+	return types.Boolean(false), nil
+}
+
+// (apply proc arg1 ... args)
+// Applications of apply in scheme code are expanded by the VM (see spreadApplyArgs),
+// so this is only called from Go code.
+func fnApply(s *State, args []types.Object) (types.Object, error) {
+	rest, err := toSlice(args[len(args)-1])
+	if err != nil {
 		return nil, err
 	}
-	str := args[0].(types.String)
-	return types.Number(len(str)), nil
+	spread := append(args[1:len(args)-1:len(args)-1], rest...)
+	return s.Call(args[0], spread...)
 }
 
-// 6.3.6 Vectors
+func fnValues(s *State, args []types.Object) (types.Object, error) {
+	objs := make([]types.Object, len(args))
+	copy(objs, args)
+	return types.NewValues(objs), nil
+}
 
-func fnVecRef(s *State, args []types.Object) (types.Object, error) {
-	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+// valuesSlice returns the objects of the multiple values obj.
+func valuesSlice(obj types.Object) []types.Object {
+	if v, ok := obj.(*types.Values); ok {
+		return v.Objs
+	}
+	return []types.Object{obj}
+}
+
+// (call-with-values producer consumer)
+func fnCallWithValues(s *State, args []types.Object) (types.Object, error) {
+	v, err := s.Call(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return s.Call(args[1], valuesSlice(v)...)
+}
+
+// (dynamic-wind before thunk after)
+// after is kept in s.winders while thunk is running, so that exit can run it.
+// after is also run when a continuation escapes from thunk, but not when an error occurs.
+func fnDynamicWind(s *State, args []types.Object) (types.Object, error) {
+	if _, err := s.Call(args[0]); err != nil {
+		return nil, err
+	}
+	depth := len(s.winders)
+	s.winders = append(s.winders, args[2])
+	result, err := s.Call(args[1])
+	if len(s.winders) > depth {
+		s.winders = s.winders[:depth]
+	}
+	if esc := (*continuationEscape)(nil); errors.As(err, &esc) {
+		// leaving the extent by a continuation
+		if _, err := s.Call(args[2]); err != nil {
+			return nil, err
+		}
+		return nil, esc
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Call(args[2]); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 4.2.5. Delayed evaluation
+
+// force forces obj if it is a promise. Otherwise obj is returned as is.
+// The chain of promises created by delay-force is forced iteratively,
+// so that it does not grow the stacks.
+func (s *State) force(obj types.Object) (types.Object, error) {
+	p, ok := obj.(*types.Promise)
+	if !ok {
+		return obj, nil
+	}
+	for !p.IsDone() {
+		thunk, lazy := p.Thunk()
+		v, err := s.Call(thunk)
+		if err != nil {
+			return nil, err
+		}
+		if p.IsDone() {
+			// forced while calling the thunk
+			break
+		}
+		if !lazy {
+			p.Resolve(v)
+			break
+		}
+		q, ok := v.(*types.Promise)
+		if !ok {
+			return nil, types.NewTypeError("delay-force: promise required, but got %v", v)
+		}
+		p.Merge(q)
+	}
+	return p.Value(), nil
+}
+
+func fnForce(s *State, args []types.Object) (types.Object, error) {
+	return s.force(args[0])
+}
+
+func fnMakePromise(s *State, args []types.Object) (types.Object, error) {
+	if p, ok := args[0].(*types.Promise); ok {
+		return p, nil
+	}
+	return types.NewForcedPromise(args[0]), nil
+}
+
+func fnIsPromise(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyPromise), nil
+}
+
+// 5.5. Record-type definitions
+
+func toRecordType(obj types.Object) (*types.RecordType, error) {
+	if err := types.AssertType(types.TyRecordType, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.RecordType), nil
+}
+
+// toFieldIndex returns the index of the field named by the symbol obj in rt.
+func toFieldIndex(rt *types.RecordType, obj types.Object) (int, error) {
+	if err := types.AssertType(types.TySymbol, obj); err != nil {
+		return 0, err
+	}
+	i := rt.FieldIndex(obj.(*types.Symbol).Name)
+	if i < 0 {
+		return 0, types.NewInternalError("%s has no field %v", rt.Name, obj)
+	}
+	return i, nil
+}
+
+// toRecord returns obj if it is a record of rt.
+func toRecord(rt *types.RecordType, obj types.Object) (*types.Record, error) {
+	rec, ok := obj.(*types.Record)
+	if !ok || rec.RecordType != rt {
+		return nil, types.NewTypeError("record of type %s required, but got %v", rt.Name, obj)
+	}
+	return rec, nil
+}
+
+// (make-record-type name fields)
+func fnMakeRecordType(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TySymbol, args[0]); err != nil {
+		return nil, err
+	}
+	syms, err := toSlice(args[1])
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(syms))
+	for i, sym := range syms {
+		if err := types.AssertType(types.TySymbol, sym); err != nil {
+			return nil, err
+		}
+		fields[i] = sym.(*types.Symbol).Name
+	}
+	return types.NewRecordType(args[0].(*types.Symbol).Name, fields), nil
+}
+
+// (record-constructor rtd fields)
+// The constructor takes the values of fields. The other fields are initialized with #f.
+func fnRecordConstructor(s *State, args []types.Object) (types.Object, error) {
+	rt, err := toRecordType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	syms, err := toSlice(args[1])
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]int, len(syms))
+	for i, sym := range syms {
+		if indices[i], err = toFieldIndex(rt, sym); err != nil {
+			return nil, err
+		}
+	}
+	var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+		fields := make([]types.Object, len(rt.Fields))
+		for i := range fields {
+			fields[i] = types.Boolean(false)
+		}
+		for i, index := range indices {
+			fields[index] = args[i]
+		}
+		return types.NewRecord(rt, fields), nil
+	}
+	return types.NewGoClosure(rt.Name, len(indices), len(indices), fn), nil
+}
+
+// (record-predicate rtd)
+func fnRecordPredicate(s *State, args []types.Object) (types.Object, error) {
+	rt, err := toRecordType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+		rec, ok := args[0].(*types.Record)
+		return types.Boolean(ok && rec.RecordType == rt), nil
+	}
+	return types.NewGoClosure(rt.Name, 1, 1, fn), nil
+}
+
+// genFnRecordField generates (record-accessor rtd field) and (record-modifier rtd field).
+func genFnRecordField(modifier bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		rt, err := toRecordType(args[0])
+		if err != nil {
+			return nil, err
+		}
+		index, err := toFieldIndex(rt, args[1])
+		if err != nil {
+			return nil, err
+		}
+		name := rt.Name + " " + rt.Fields[index]
+		if modifier {
+			var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+				rec, err := toRecord(rt, args[0])
+				if err != nil {
+					return nil, err
+				}
+				rec.Fields[index] = args[1]
+				return types.UndefinedObject, nil
+			}
+			return types.NewGoClosure(name, 2, 2, fn), nil
+		}
+		var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+			rec, err := toRecord(rt, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return rec.Fields[index], nil
+		}
+		return types.NewGoClosure(name, 1, 1, fn), nil
+	}
+}
+
+// 6.11. Exceptions
+
+// genFnIsError generates the predicates on the kind of error objects, such as file-error?.
+func genFnIsError(errType types.ErrorType) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		e, ok := args[0].(*types.Error)
+		return types.Boolean(ok && e.ErrorType() == errType), nil
+	}
+}
+
+// 6.13. Input and output
+
+func fnEOFObject(s *State, args []types.Object) (types.Object, error) {
+	return types.EOFObject, nil
+}
+
+func fnIsEOFObject(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0] == types.EOFObject), nil
+}
+
+func fnCurrentInputPort(s *State, args []types.Object) (types.Object, error) {
+	return s.stdin, nil
+}
+
+func fnCurrentOutputPort(s *State, args []types.Object) (types.Object, error) {
+	return s.stdout, nil
+}
+
+func fnCurrentErrorPort(s *State, args []types.Object) (types.Object, error) {
+	return s.stderr, nil
+}
+
+func toPort(obj types.Object) (*types.Port, error) {
+	if err := types.AssertType(types.TyPort, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.Port), nil
+}
+
+// optionalPort returns the port at args[i], or def if it is omitted.
+func optionalPort(args []types.Object, i int, def *types.Port) (*types.Port, error) {
+	if len(args) <= i {
+		return def, nil
+	}
+	return toPort(args[i])
+}
+
+func fnIsPort(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyPort), nil
+}
+
+func genFnPortPred(pred func(p *types.Port) bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		p, ok := args[0].(*types.Port)
+		return types.Boolean(ok && pred(p)), nil
+	}
+}
+
+// genFnPortOpen generates input-port-open? and output-port-open?.
+func genFnPortOpen(input bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		p, err := toPort(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if input {
+			return types.Boolean(p.IsInput() && p.IsOpen()), nil
+		}
+		return types.Boolean(p.IsOutput() && p.IsOpen()), nil
+	}
+}
+
+func fnClosePort(s *State, args []types.Object) (types.Object, error) {
+	p, err := toPort(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Close(); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (read [port])
+// Malformed data are reported as read errors with the position in the port.
+func fnRead(s *State, args []types.Object) (types.Object, error) {
+	port, err := optionalPort(args, 0, s.stdin)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := port.PeekChar(); err == io.EOF {
+		return types.EOFObject, nil
+	} else if err != nil {
 		return nil, err
 	}
-	if err := types.AssertType(types.TyNumber, args[1]); err != nil {
+	p := &parser.Parser{}
+	line, column := port.Position()
+	p.InitReader(port, line, column)
+	obj, err := p.ParseObject()
+	if err == io.EOF {
+		return types.EOFObject, nil
+	}
+	if err != nil {
 		return nil, err
 	}
-	v := args[0].(types.Vector)
-	k := args[1].(types.Number)
-	if int(k) > len(v)-1 {
-		return nil, types.NewInternalError("index out of range: %d", int(k))
+	return obj, nil
+}
+
+// genFnReadChar generates (read-char [port]) and (peek-char [port]).
+func genFnReadChar(peek bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		p, err := optionalPort(args, 0, s.stdin)
+		if err != nil {
+			return nil, err
+		}
+		var r rune
+		if peek {
+			r, err = p.PeekChar()
+		} else {
+			r, err = p.ReadChar()
+		}
+		if err == io.EOF {
+			return types.EOFObject, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return types.Char(r), nil
+	}
+}
+
+// (read-line [port])
+func fnReadLine(s *State, args []types.Object) (types.Object, error) {
+	p, err := optionalPort(args, 0, s.stdin)
+	if err != nil {
+		return nil, err
+	}
+	line, err := p.ReadLine()
+	if err == io.EOF {
+		return types.EOFObject, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.NewString(line), nil
+}
+
+// (read-string k [port])
+func fnReadString(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	p, err := optionalPort(args, 1, s.stdin)
+	if err != nil {
+		return nil, err
+	}
+	str, err := p.ReadString(k)
+	if err == io.EOF {
+		return types.EOFObject, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.NewString(str), nil
+}
+
+// genFnReadU8 generates (read-u8 [port]) and (peek-u8 [port]).
+func genFnReadU8(peek bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		p, err := optionalPort(args, 0, s.stdin)
+		if err != nil {
+			return nil, err
+		}
+		var b byte
+		if peek {
+			b, err = p.PeekByte()
+		} else {
+			b, err = p.ReadByte()
+		}
+		if err == io.EOF {
+			return types.EOFObject, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return types.Number(b), nil
+	}
+}
+
+// (read-bytevector k [port])
+func fnReadBytevector(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	p, err := optionalPort(args, 1, s.stdin)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := p.ReadBytes(k)
+	if err == io.EOF {
+		return types.EOFObject, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBytevector(bs), nil
+}
+
+// (newline [port])
+func fnNewline(s *State, args []types.Object) (types.Object, error) {
+	p, err := optionalPort(args, 0, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteString("\n"); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (write-char char [port])
+func fnWriteChar(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyChar, args[0]); err != nil {
+		return nil, err
+	}
+	p, err := optionalPort(args, 1, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteChar(rune(args[0].(types.Char))); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (write-string string [port [start [end]]])
+func fnWriteString(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	p, err := optionalPort(args, 1, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[min(len(args), 2):], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteString(str.Substring(start, end)); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (write-u8 byte [port])
+func fnWriteU8(s *State, args []types.Object) (types.Object, error) {
+	b, err := toIndex(args[0], math.MaxUint8)
+	if err != nil {
+		return nil, err
+	}
+	p, err := optionalPort(args, 1, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteBytes([]byte{byte(b)}); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (write-bytevector bytevector [port [start [end]]])
+func fnWriteBytevector(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bs := args[0].(*types.Bytevector).Bytes()
+	p, err := optionalPort(args, 1, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[min(len(args), 2):], len(bs))
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteBytes(bs[start:end]); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// genFnWrite generates (display obj [port]) and (write obj [port]).
+// repr returns the representation of obj.
+func genFnWrite(repr func(obj types.Object) string) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		p, err := optionalPort(args, 1, s.stdout)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.WriteString(repr(args[0])); err != nil {
+			return nil, err
+		}
+		return types.UndefinedObject, nil
+	}
+}
+
+// (flush-output-port [port])
+func fnFlushOutputPort(s *State, args []types.Object) (types.Object, error) {
+	p, err := optionalPort(args, 0, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Flush(); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnOpenInputString(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	return types.NewInputPort(strings.NewReader(args[0].(*types.String).String()), false), nil
+}
+
+func fnOpenOutputString(s *State, args []types.Object) (types.Object, error) {
+	return types.NewOutputStringPort(), nil
+}
+
+func fnGetOutputString(s *State, args []types.Object) (types.Object, error) {
+	p, err := toPort(args[0])
+	if err != nil {
+		return nil, err
+	}
+	str, ok := p.OutputString()
+	if !ok {
+		return nil, types.NewTypeError("string output port required, but got %v", p)
+	}
+	return types.NewString(str), nil
+}
+
+func fnOpenInputBytevector(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bs := bytes.Clone(args[0].(*types.Bytevector).Bytes())
+	return types.NewInputPort(bytes.NewReader(bs), true), nil
+}
+
+func fnOpenOutputBytevector(s *State, args []types.Object) (types.Object, error) {
+	return types.NewOutputBytevectorPort(), nil
+}
+
+func fnGetOutputBytevector(s *State, args []types.Object) (types.Object, error) {
+	p, err := toPort(args[0])
+	if err != nil {
+		return nil, err
+	}
+	bs, ok := p.OutputBytes()
+	if !ok {
+		return nil, types.NewTypeError("bytevector output port required, but got %v", p)
+	}
+	return types.NewBytevector(bs), nil
+}
+
+// (call-with-output-string proc)
+// proc is called with a string output port, and the accumulated string is returned.
+func fnCallWithOutputString(s *State, args []types.Object) (types.Object, error) {
+	p := types.NewOutputStringPort()
+	if _, err := s.Call(args[0], p); err != nil {
+		return nil, err
+	}
+	str, _ := p.OutputString()
+	return types.NewString(str), nil
+}
+
+// (with-output-to-string thunk)
+// thunk is called with the current output port bound to a string output port,
+// and the accumulated string is returned.
+func fnWithOutputToString(s *State, args []types.Object) (types.Object, error) {
+	p := types.NewOutputStringPort()
+	stdout := s.stdout
+	s.stdout = p
+	_, err := s.Call(args[0])
+	s.stdout = stdout
+	if err != nil {
+		return nil, err
+	}
+	str, _ := p.OutputString()
+	return types.NewString(str), nil
+}
+
+// mapLists calls fn with the i-th elements of lists, for i from 0 to the length of the shortest list.
+func mapLists(lists []types.Object, fn func(elems []types.Object) error) error {
+	if len(lists) == 1 {
+		// avoid converting the list to a slice
+		list := lists[0]
+		for pair, ok := list.(*types.Pair); ok; pair, ok = list.(*types.Pair) {
+			if err := fn([]types.Object{pair.Car()}); err != nil {
+				return err
+			}
+			list = pair.Cdr()
+		}
+		if !types.IsNull(list) {
+			return types.NewTypeError("list required, but got %v", lists[0])
+		}
+		return nil
+	}
+	slices := make([][]types.Object, len(lists))
+	n := math.MaxInt32
+	for i, list := range lists {
+		elems, err := toSlice(list)
+		if err != nil {
+			return err
+		}
+		slices[i] = elems
+		if len(elems) < n {
+			n = len(elems)
+		}
+	}
+	for i := 0; i < n; i++ {
+		elems := make([]types.Object, len(slices))
+		for j, slice := range slices {
+			elems[j] = slice[i]
+		}
+		if err := fn(elems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// (map proc list1 list2 ...)
+func fnMap(s *State, args []types.Object) (types.Object, error) {
+	results := []types.Object{}
+	err := mapLists(args[1:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], elems...)
+		if err != nil {
+			return err
+		}
+		results = append(results, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.List(results...), nil
+}
+
+// (for-each proc list1 list2 ...)
+func fnForEach(s *State, args []types.Object) (types.Object, error) {
+	err := mapLists(args[1:], func(elems []types.Object) error {
+		_, err := s.Call(args[0], elems...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// toSlice converts the list obj to a slice.
+func toSlice(obj types.Object) ([]types.Object, error) {
+	if !types.IsList(obj) {
+		return nil, types.NewTypeError("list required, but got %v", obj)
+	}
+	return obj.(types.SlicableObject).Slice()
+}
+
+// toIndex converts obj to an index in the range [0, max].
+func toIndex(obj types.Object, max int) (int, error) {
+	if err := types.AssertType(types.TyNumber, obj); err != nil {
+		return 0, err
+	}
+	num := obj.(types.Number)
+	k := int(num)
+	if types.Number(k) != num {
+		return 0, types.NewTypeError("exact integer required, but got %v", num)
+	}
+	if k < 0 || k > max {
+		return 0, types.NewInternalError("index out of range: %d", k)
+	}
+	return k, nil
+}
+
+// toRange converts the optional start and end arguments to indices.
+// start defaults to 0 and end defaults to length.
+func toRange(args []types.Object, length int) (start int, end int, err error) {
+	start, end = 0, length
+	if len(args) > 0 {
+		if start, err = toIndex(args[0], length); err != nil {
+			return
+		}
+	}
+	if len(args) > 1 {
+		if end, err = toIndex(args[1], length); err != nil {
+			return
+		}
+	}
+	if start > end {
+		err = types.NewInternalError("start index %d is greater than end index %d", start, end)
+	}
+	return
+}
+
+// 6.3.5. Strings
+
+func fnMakeStr(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	ch := types.Char(' ')
+	if len(args) > 1 {
+		if err := types.AssertType(types.TyChar, args[1]); err != nil {
+			return nil, err
+		}
+		ch = args[1].(types.Char)
+	}
+	runes := make([]rune, k)
+	for i := range runes {
+		runes[i] = rune(ch)
+	}
+	return types.NewStringFromRunes(runes), nil
+}
+
+func fnStr(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyChar, args...); err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(args))
+	for i, arg := range args {
+		runes[i] = rune(arg.(types.Char))
+	}
+	return types.NewStringFromRunes(runes), nil
+}
+
+func fnStrLen(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	return types.Number(str.Len()), nil
+}
+
+func fnStrRef(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	k, err := toIndex(args[1], str.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	return str.Ref(k), nil
+}
+
+func fnStrSet(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyChar, args[2]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	k, err := toIndex(args[1], str.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	if err := str.Set(k, args[2].(types.Char)); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnSubstr(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[1:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	return types.NewString(str.Substring(start, end)), nil
+}
+
+func fnStrFill(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyChar, args[1]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[2:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	if err := str.Fill(args[1].(types.Char), start, end); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (string-copy! to at from [start [end]])
+func fnStrCopyTo(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0], args[2]); err != nil {
+		return nil, err
+	}
+	to := args[0].(*types.String)
+	from := args[2].(*types.String)
+	at, err := toIndex(args[1], to.Len())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[3:], from.Len())
+	if err != nil {
+		return nil, err
+	}
+	if to.Len()-at < end-start {
+		return nil, types.NewInternalError("not enough room to copy %d characters", end-start)
+	}
+	if err := to.CopyFrom(at, from, start, end); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// 6.3.6 Vectors
+
+func fnIsVec(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyVector), nil
+}
+
+func fnMakeVec(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	var fill types.Object = types.UndefinedObject
+	if len(args) > 1 {
+		fill = args[1]
+	}
+	elems := make([]types.Object, k)
+	for i := range elems {
+		elems[i] = fill
+	}
+	return types.NewVector(elems), nil
+}
+
+func fnVec(s *State, args []types.Object) (types.Object, error) {
+	elems := make([]types.Object, len(args))
+	copy(elems, args)
+	return types.NewVector(elems), nil
+}
+
+func fnVecLen(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	return types.Number(args[0].(*types.Vector).Len()), nil
+}
+
+func fnVecRef(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	k, err := toIndex(args[1], v.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	return v.Ref(k), nil
+}
+
+func fnVecSet(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	k, err := toIndex(args[1], v.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Set(k, args[2]); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnVecToList(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	start, end, err := toRange(args[1:], v.Len())
+	if err != nil {
+		return nil, err
+	}
+	return types.List(v.Elems()[start:end]...), nil
+}
+
+func fnListToVec(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(elems), nil
+}
+
+func fnVecCopy(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	start, end, err := toRange(args[1:], v.Len())
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]types.Object, end-start)
+	copy(elems, v.Elems()[start:end])
+	return types.NewVector(elems), nil
+}
+
+// (vector-copy! to at from [start [end]])
+func fnVecCopyTo(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0], args[2]); err != nil {
+		return nil, err
+	}
+	to := args[0].(*types.Vector)
+	from := args[2].(*types.Vector)
+	at, err := toIndex(args[1], to.Len())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[3:], from.Len())
+	if err != nil {
+		return nil, err
+	}
+	if to.Len()-at < end-start {
+		return nil, types.NewInternalError("not enough room to copy %d elements", end-start)
+	}
+	if err := to.CopyFrom(at, from, start, end); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnVecAppend(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args...); err != nil {
+		return nil, err
+	}
+	elems := []types.Object{}
+	for _, arg := range args {
+		elems = append(elems, arg.(*types.Vector).Elems()...)
+	}
+	return types.NewVector(elems), nil
+}
+
+func fnVecFill(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	start, end, err := toRange(args[2:], v.Len())
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Fill(args[1], start, end); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// mapVectors calls fn with the i-th elements of vecs, for i from 0 to the length of the shortest vector.
+func mapVectors(vecs []types.Object, fn func(elems []types.Object) error) error {
+	if err := types.AssertType(types.TyVector, vecs...); err != nil {
+		return err
+	}
+	n := math.MaxInt32
+	for _, v := range vecs {
+		if l := v.(*types.Vector).Len(); l < n {
+			n = l
+		}
+	}
+	for i := 0; i < n; i++ {
+		elems := make([]types.Object, len(vecs))
+		for j, v := range vecs {
+			elems[j] = v.(*types.Vector).Ref(i)
+		}
+		if err := fn(elems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// (vector-map proc vector1 vector2 ...)
+func fnVecMap(s *State, args []types.Object) (types.Object, error) {
+	results := []types.Object{}
+	err := mapVectors(args[1:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], elems...)
+		if err != nil {
+			return err
+		}
+		results = append(results, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(results), nil
+}
+
+// (vector-for-each proc vector1 vector2 ...)
+func fnVecForEach(s *State, args []types.Object) (types.Object, error) {
+	err := mapVectors(args[1:], func(elems []types.Object) error {
+		_, err := s.Call(args[0], elems...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (vector-count pred vector1 vector2 ...)
+func fnVecCount(s *State, args []types.Object) (types.Object, error) {
+	count := 0
+	err := mapVectors(args[1:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], elems...)
+		if err != nil {
+			return err
+		}
+		if types.IsTruthy(v) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(count), nil
+}
+
+// (vector-binary-search vector value cmp [start end])
+// cmp is called as (cmp element value) and must return a negative number, zero or
+// a positive number. Returns the index of the element or #f if not found.
+func fnVecBinarySearch(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	v := args[0].(*types.Vector)
+	lo, hi, err := toRange(args[3:], v.Len())
+	if err != nil {
+		return nil, err
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		c, err := s.Call(args[2], v.Ref(mid), args[1])
+		if err != nil {
+			return nil, err
+		}
+		if err := types.AssertType(types.TyNumber, c); err != nil {
+			return nil, err
+		}
+		switch n := c.(types.Number); {
+		case n == 0:
+			return types.Number(mid), nil
+		case n < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
 	}
-	return v[int(k)], nil
+	return types.Boolean(false), nil
 }
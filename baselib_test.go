@@ -1,8 +1,10 @@
 package tama
 
 import (
+	"bytes"
 	"github.com/hyusuk/tama/types"
 
+	"strings"
 	"testing"
 )
 
@@ -25,9 +27,13 @@ func (tc *tcase) noerror(t *testing.T, caseNo int) {
 	t.Fatalf("case %d: expected error, but got no error\nsrc: %s", caseNo, tc.src)
 }
 
-func testTcases(t *testing.T, tcases []*tcase) {
+// testTcases runs tcases. libs are opened in addition to the base library.
+func testTcases(t *testing.T, tcases []*tcase, libs ...func(*State) *State) {
 	for i, tc := range tcases {
 		s := NewState(tc.option)
+		for _, open := range libs {
+			open(s)
+		}
 		err := s.ExecString(tc.src)
 		if tc.expectErr {
 			if err == nil {
@@ -62,6 +68,9 @@ func TestLambda(t *testing.T) {
 		&tcase{src: "(((lambda (a) (lambda (b) (set! a 1) (+ a b))) 100) 2)", expect: "3"},
 		&tcase{src: "((lambda args (+ (car args) 100)) 1 2 3)", expect: "101"},
 		&tcase{src: "((lambda (a b . rest) (+ a b (car rest))) 1 2 3 4)", expect: "6"},
+		// the captured arguments must survive the reuse of the frame after return
+		&tcase{src: "(define (f a b) (lambda () (list a b))) (equal? (map (lambda (g) (g)) (map f '(5 6) '(7 8))) '((5 7) (6 8)))", expect: "#t"},
+		&tcase{src: "(define (f a b) ((lambda (c) (lambda () (list a b c))) 3)) ((f 1 2))", expect: "(1 2 3)"},
 	}
 	testTcases(t, tcases)
 }
@@ -112,6 +121,31 @@ func TestCallCC(t *testing.T) {
 		&tcase{src: "((lambda (z) ((lambda (x y) (call/cc (lambda (cc) (cc x)))) 3 4)) 100)", expect: "3"},
 		&tcase{src: "((lambda (z) ((lambda (x y) (call/cc (lambda (cc) (cc x) 99))) 3 4)) 100)", expect: "3"},
 		&tcase{src: "((lambda (z a) ((lambda (x y) (call/cc (lambda (cc) (cc x) 99))) 3 4)) 100 99)", expect: "3"},
+		// escaping from the procedures called by go functions
+		&tcase{src: `(call/cc (lambda (k) (string-for-each (lambda (c) (k 1)) "ab")))`, expect: "1"},
+		&tcase{src: `(+ 1 (call/cc (lambda (k) (string-map (lambda (c) (k 1)) "ab"))))`, expect: "2"},
+		&tcase{src: "(+ 1 (call/cc (lambda (k) (vector-map (lambda (x) (k x)) #(5 2)))))", expect: "6"},
+		&tcase{src: "(+ 1 (call/cc (lambda (k) (list-sort (lambda (a b) (k 10)) (list 3 1 2)))))", expect: "11"},
+		&tcase{src: "(+ 1 (call/cc (lambda (k) (force (delay (k 4))))))", expect: "5"},
+		&tcase{src: `(define r (call/cc (lambda (k) (with-output-to-string (lambda () (display "x") (k 2)))))) (with-output-to-string (lambda () (display r)))`, expect: "2"},
+		&tcase{src: "(define trace '()) (define (note x) (set! trace (cons x trace))) (define r (call/cc (lambda (k) (dynamic-wind (lambda () (note 'before)) (lambda () (k 7)) (lambda () (note 'after)))))) (list r trace)", expect: "(7 (after before))"},
+		&tcase{src: "(+ 1 (call/cc (lambda (k) (map (lambda (x) (k x)) (list 5 2)))))", expect: "6"},
+		&tcase{src: "(+ 1 (call/cc (lambda (k) (for-each k (list 5 2)))))", expect: "6"},
+		&tcase{src: "(map (lambda (x) (call/cc (lambda (k) (vector-map (lambda (y) (k (* x 10))) #(1))))) (list 1 2))", expect: "(10 20)"},
+		&tcase{src: "(define k #f) (map (lambda (x) (call/cc (lambda (c) (set! k c))) x) (list 1)) (k 5)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenString, (*State).OpenSort)
+}
+
+// 6.1. Equivalence predicates
+func TestFnEquivalence(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(eq? 'a 'a)", expect: "#t"},
+		&tcase{src: "(eqv? 1 1)", expect: "#t"},
+		&tcase{src: "(eqv? (cons 1 2) (cons 1 2))", expect: "#f"},
+		&tcase{src: "(define p (cons 1 2)) (eq? p p)", expect: "#t"},
+		&tcase{src: "(equal? (cons 1 \"a\") (cons 1 \"a\"))", expect: "#t"},
+		&tcase{src: "(equal? #(1 2) #(1 3))", expect: "#f"},
 	}
 	testTcases(t, tcases)
 }
@@ -193,21 +227,546 @@ func TestFnComp(t *testing.T) {
 }
 
 // 6.3.5. Strings
+func TestFnMakeStr(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(make-string 3 #\\a)", expect: "aaa"},
+		&tcase{src: "(string-length (make-string 2))", expect: "2"},
+		&tcase{src: "(make-string -1)", expectErr: true},
+		&tcase{src: "(make-string 2 \"a\")", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnStr(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(string #\\h #\\é #\\x41)", expect: "héA"},
+		&tcase{src: "(string)", expect: ""},
+		&tcase{src: "(string 1)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
 func TestFnStrLen(t *testing.T) {
 	tcases := []*tcase{
 		&tcase{src: "(string-length \"test\")", expect: "4"},
 		&tcase{src: "(string-length \"\")", expect: "0"},
+		&tcase{src: "(string-length \"héllo\")", expect: "5"},
 		&tcase{src: "(string-length 1)", expectErr: true},
 	}
 	testTcases(t, tcases)
 }
 
+func TestFnStrRef(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(string-ref \"abc\" 1)", expect: "b"},
+		&tcase{src: "(string-ref \"héllo\" 2)", expect: "l"},
+		&tcase{src: "(string-ref \"abc\" 3)", expectErr: true},
+		&tcase{src: "(string-ref \"abc\" -1)", expectErr: true},
+		&tcase{src: "(string-ref \"abc\" 0.5)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnStrSet(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define s (make-string 3 #\\a)) (string-set! s 1 #\\λ) s", expect: "aλa"},
+		&tcase{src: "(define s (string #\\é #\\b)) (string-set! s 1 #\\c) (string-length s)", expect: "2"},
+		&tcase{src: "(string-set! \"abc\" 0 #\\z)", expectErr: true},
+		&tcase{src: "(define (f) \"abc\") (string-set! (f) 0 #\\z)", expectErr: true},
+		&tcase{src: "(string-set! (make-string 1) 1 #\\z)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnSubstr(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(substring \"hello\" 1 3)", expect: "el"},
+		&tcase{src: "(substring \"héllo\" 1 3)", expect: "él"},
+		&tcase{src: "(substring \"hello\" 3 1)", expectErr: true},
+		&tcase{src: "(substring \"hello\" 0 6)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnStrFill(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define s (make-string 3 #\\a)) (string-fill! s #\\ü) s", expect: "üüü"},
+		&tcase{src: "(define s (make-string 4 #\\a)) (string-fill! s #\\b 1 3) s", expect: "abba"},
+		&tcase{src: "(string-fill! \"abc\" #\\b)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnStrCopyTo(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define s (make-string 5 #\\-)) (string-copy! s 1 \"héllo\" 1 3) s", expect: "-él--"},
+		&tcase{src: "(define s (string #\\a #\\b #\\c #\\d)) (string-copy! s 1 s 0 3) s", expect: "aabc"},
+		&tcase{src: "(string-copy! (make-string 2) 0 \"abc\")", expectErr: true},
+		&tcase{src: "(string-copy! \"abc\" 0 \"x\")", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
 // 6.3.6 Vectors
+func TestFnIsVec(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(vector? #(1 2))", expect: "#t"},
+		&tcase{src: "(vector? '(1 2))", expect: "#f"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnMakeVec(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(vector-ref (make-vector 3 'a) 2)", expect: "a"},
+		&tcase{src: "(vector-length (make-vector 3))", expect: "3"},
+		&tcase{src: "(vector-ref (vector 1 2 3) 1)", expect: "2"},
+		&tcase{src: "(vector-length (vector))", expect: "0"},
+		&tcase{src: "(make-vector -1)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
 func TestFnVecRef(t *testing.T) {
 	tcases := []*tcase{
 		&tcase{src: "(vector-ref #(1 2 3 4 5) 1)", expect: "2"},
 		&tcase{src: "(vector-ref '(1 2 3 4 5) 1)", expectErr: true},
 		&tcase{src: "(vector-ref #(1 2 3 4 5) 5)", expectErr: true},
+		&tcase{src: "(vector-ref #(1 2 3 4 5) -1)", expectErr: true},
+		&tcase{src: "(vector-ref #(1 2 3 4 5) 1.5)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnVecSet(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define v (vector 1 2 3)) (vector-set! v 0 'x) (vector-ref v 0)", expect: "x"},
+		&tcase{src: "(vector-set! (vector 1) 1 'x)", expectErr: true},
+		&tcase{src: "(vector-set! #(1 2 3) 0 'x)", expectErr: true},
+		&tcase{src: "(define (f) '#(1 2 3)) (vector-set! (f) 0 'x)", expectErr: true},
+		&tcase{src: "(vector-set! (car '(#(1 2 3))) 0 'x)", expectErr: true},
+		&tcase{src: "(vector-fill! #(1 2 3) 0)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnVecConversion(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(car (cdr (vector->list #(1 2 3))))", expect: "2"},
+		&tcase{src: "(car (vector->list #(1 2 3) 2))", expect: "3"},
+		&tcase{src: "(vector-ref (list->vector '(1 2 3)) 2)", expect: "3"},
+		&tcase{src: "(list->vector (cons 1 2))", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnVecCopy(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define v (vector-copy #(1 2 3))) (vector-set! v 0 9) (vector-ref v 0)", expect: "9"},
+		&tcase{src: "(vector-length (vector-copy #(1 2 3) 1 2))", expect: "1"},
+		&tcase{src: "(define v (vector 1 2 3 4 5)) (vector-copy! v 0 #(a b) 1) (vector-ref v 0)", expect: "b"},
+		&tcase{src: "(define v (vector 1 2 3 4 5)) (vector-copy! v 1 v 0 3) (vector-ref v 3)", expect: "3"},
+		&tcase{src: "(vector-copy! (vector 1) 0 #(1 2))", expectErr: true},
+		&tcase{src: "(vector-ref (vector-append #(1) #(2 3) #()) 2)", expect: "3"},
+		&tcase{src: "(define v (make-vector 4 0)) (vector-fill! v 1 1 3) (+ (vector-ref v 1) (vector-ref v 3))", expect: "1"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnVecMap(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(vector-ref (vector-map (lambda (x) (* x x)) #(1 2 3)) 2)", expect: "9"},
+		&tcase{src: "(vector-length (vector-map + #(1 2 3) #(10 20)))", expect: "2"},
+		&tcase{src: "(define n 0) (vector-for-each (lambda (x) (set! n (+ n x))) #(1 2 3)) n", expect: "6"},
+		&tcase{src: "(vector-map car #(1 2 3))", expectErr: true},
+		&tcase{src: "(vector-count (lambda (x) (< x 3)) #(1 2 3 4))", expect: "2"},
+		&tcase{src: "(vector-count < #(1 5 3) #(2 4 6))", expect: "2"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnVecBinarySearch(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(vector-binary-search #(1 3 5 7 9) 7 -)", expect: "3"},
+		&tcase{src: "(vector-binary-search #(1 3 5 7 9) 4 -)", expect: "#f"},
+		&tcase{src: "(vector-binary-search #() 4 -)", expect: "#f"},
+		&tcase{src: "(vector-binary-search #(1 3 5 7 9) 1 - 1)", expect: "#f"},
+		&tcase{src: "(vector-binary-search #(1 3) 1 (lambda (a b) 'x))", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnList(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(length (list 1 2 3))", expect: "3"},
+		&tcase{src: "(list? (list 1 2))", expect: "#t"},
+		&tcase{src: "(list? (cons 1 2))", expect: "#f"},
+		&tcase{src: "(equal? (append (list 1) (list 2 3) '()) (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(cdr (append (list 1) 2))", expect: "2"},
+		&tcase{src: "(equal? (reverse (list 1 2 3)) (list 3 2 1))", expect: "#t"},
+		&tcase{src: "(list-ref (list 1 2 3) 2)", expect: "3"},
+		&tcase{src: "(car (list-tail (list 1 2 3) 1))", expect: "2"},
+		&tcase{src: "(list-ref (list 1 2 3) 3)", expectErr: true},
+		&tcase{src: "(define x (list 1 2)) (eq? x (list-copy x))", expect: "#f"},
+		&tcase{src: "(caddr (list 1 2 3))", expect: "3"},
+		&tcase{src: "(cdadr (list 1 (cons 2 3)))", expect: "3"},
+		&tcase{src: "(length (make-list 3 #f))", expect: "3"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnSetCar(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define x (list 1 2)) (set-car! x 3) (car x)", expect: "3"},
+		&tcase{src: "(define x (list 1 2)) (set-cdr! x 3) (cdr x)", expect: "3"},
+		&tcase{src: "(set-car! '(1 2) 3)", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnMember(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(car (memq 'b '(a b c)))", expect: "b"},
+		&tcase{src: "(memv 4 '(1 2 3))", expect: "#f"},
+		&tcase{src: "(car (car (member (list 1) (list (list 1)))))", expect: "1"},
+		&tcase{src: "(car (member 2.0 (list 1 2 3) =))", expect: "2"},
+		&tcase{src: "(cdr (assq 'b (list (cons 'a 1) (cons 'b 2))))", expect: "2"},
+		&tcase{src: "(assv 3 (list (cons 1 2)))", expect: "#f"},
+		&tcase{src: "(cdr (assoc \"b\" (list (cons \"b\" 2))))", expect: "2"},
+		&tcase{src: "(cdr (assoc 2 (list (cons 1 1) (cons 2 2)) (lambda (a b) (= a b))))", expect: "2"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnValues(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(call-with-values (lambda () (values 1 2)) +)", expect: "3"},
+		&tcase{src: "(call-with-values (lambda () 5) (lambda (x) x))", expect: "5"},
+		&tcase{src: "(values 1 2)", expect: "1 2"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnDynamicWind(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define l '()) (define (push x) (set! l (cons x l))) (dynamic-wind (lambda () (push 1)) (lambda () (push 2) 10) (lambda () (push 3)))", expect: "10"},
+		&tcase{src: "(define l '()) (define (push x) (set! l (cons x l))) (dynamic-wind (lambda () (push 1)) (lambda () (push 2)) (lambda () (push 3))) l", expect: "(3 2 1)"},
+		&tcase{src: "(dynamic-wind (lambda () 1) (lambda () (car 1)) (lambda () 3))", expectErr: true},
+		&tcase{src: "(dynamic-wind (lambda () 1) (lambda () 2))", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnMap(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (map + (list 1 2 3) (list 10 20)) (list 11 22))", expect: "#t"},
+		&tcase{src: "(define n 0) (for-each (lambda (x) (set! n (+ n x))) (list 1 2 3)) n", expect: "6"},
+		&tcase{src: "(procedure? car)", expect: "#t"},
+		&tcase{src: "(procedure? 'car)", expect: "#f"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestFnApply(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(apply + (list 1 2 3))", expect: "6"},
+		&tcase{src: "(apply + 1 2 (list 3 4))", expect: "10"},
+		&tcase{src: "(apply (lambda (a b) (- a b)) (list 5 2))", expect: "3"},
+		&tcase{src: "(apply (lambda args (length args)) '())", expect: "0"},
+		&tcase{src: "(call/cc (lambda (k) (apply k (list 7)) 5))", expect: "7"},
+		&tcase{src: "(equal? (map apply (list + -) (list (list 1 2) (list 3 4))) (list 3 -1))", expect: "#t"},
+		&tcase{src: "(apply + 1)", expectErr: true},
+		&tcase{src: "(apply +)", expectErr: true},
+		&tcase{src: "(apply car (list 1 2))", expectErr: true},
+		&tcase{src: "(apply (lambda (a) a) (list 1 2))", expectErr: true},
+		&tcase{src: "(apply 1 '())", expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestMatch(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(match 1 (1 'one) (_ 'other))`, expect: "one"},
+		&tcase{src: `(match 2 (1 'one) (_ 'other))`, expect: "other"},
+		&tcase{src: `(match "ab" ("ab" #t))`, expect: "#t"},
+		&tcase{src: `(match 'x ('x 1) ('y 2))`, expect: "1"},
+		&tcase{src: `(match '() (() 'empty) (_ 'other))`, expect: "empty"},
+		&tcase{src: `(match 5 (x (+ x 1)))`, expect: "6"},
+		&tcase{src: `(match (list 1 2 3) ((a b c) (+ a b c)))`, expect: "6"},
+		&tcase{src: `(match (list 1 2) ((a b c) 'three) ((a b) 'two))`, expect: "two"},
+		&tcase{src: `(match (list 1 (list 2 3)) ((a (b c)) (* a b c)))`, expect: "6"},
+		&tcase{src: `(match (list 1 2 3) ((a . rest) rest))`, expect: "(2 3)"},
+		&tcase{src: `(match (cons 1 2) ((a . b) (+ a b)))`, expect: "3"},
+		&tcase{src: `(match (list 1 1) ((a a) 'same) (_ 'different))`, expect: "same"},
+		&tcase{src: `(match (list 1 2) ((a a) 'same) (_ 'different))`, expect: "different"},
+		// ellipsis
+		&tcase{src: `(match (list 1 2 3) ((x ...) (apply + x)))`, expect: "6"},
+		&tcase{src: `(match '() ((x ...) x))`, expect: "()"},
+		&tcase{src: `(match (list 1 2 3 4) ((a b ... c) (list a b c)))`, expect: "(1 (2 3) 4)"},
+		&tcase{src: `(match (list 1) ((a b ... c) 'ok) (_ 'too-short))`, expect: "too-short"},
+		&tcase{src: `(match '((a 1) (b 2)) (((k v) ...) v))`, expect: "(1 2)"},
+		&tcase{src: `(match '((1 2) (3)) (((x ...) ...) x))`, expect: "((1 2) (3))"},
+		&tcase{src: `(match (list (list 1) 2) (((? pair? p) ...) p) (_ 'not-pairs))`, expect: "not-pairs"},
+		// vectors
+		&tcase{src: `(match (vector 1 2) (#(a b) (- a b)))`, expect: "-1"},
+		&tcase{src: `(match (vector 1 2 3) (#(a rest ...) rest))`, expect: "(2 3)"},
+		&tcase{src: `(match (list 1 2) (#(a b) 'vector) (_ 'other))`, expect: "other"},
+		// predicates and accessors
+		&tcase{src: `(match 5 ((? vector?) 'vector) ((? (lambda (x) (> x 3)) n) (* n 2)))`, expect: "10"},
+		&tcase{src: `(match (list 1 2) ((= length 2) 'two))`, expect: "two"},
+		&tcase{src: `(match (list 1 2) ((= car x) x))`, expect: "1"},
+		&tcase{src: `(match (list 1) ((and (? pair?) x) (car x)))`, expect: "1"},
+		&tcase{src: `(match 'b ((or 'a 'b) 'a-or-b))`, expect: "a-or-b"},
+		&tcase{src: `(match (list 2) ((or (1) (x)) x))`, expect: "2"},
+		&tcase{src: `(match (list 1) ((or (1) (x)) x))`, expect: "#f"},
+		&tcase{src: `(match 3 ((not 1) 'not-one))`, expect: "not-one"},
+		&tcase{src: `(match 1 ((not 1) 'not-one) (_ 'one))`, expect: "one"},
+		// quasi-patterns
+		&tcase{src: "(match '(add 1 2) ((quasiquote (add (unquote a) (unquote b))) (+ a b)))", expect: "3"},
+		&tcase{src: "(match '(sub 1 2) ((quasiquote (add (unquote a) (unquote b))) (+ a b)) (_ 'other))", expect: "other"},
+		&tcase{src: "(match '(add 1 (2 3)) (`(add ,a (,b ,c)) (+ a b c)))", expect: "6"},
+		&tcase{src: "(match '(add 1 2) (`(add ,@a) a))", expectErr: true},
+		// guards
+		&tcase{src: `(match 5 (x (guard (< x 3)) 'small) (x 'large))`, expect: "large"},
+		&tcase{src: `(match 1 (x (guard (< x 3) (> x 0)) 'small) (x 'large))`, expect: "small"},
+		&tcase{src: `(match 1 (_ (guard #f) 'no) (_ 'yes))`, expect: "yes"},
+		// scope of the pattern variables
+		&tcase{src: `(define (f x) (match (list x 1) ((a b) (lambda () (+ a b x))))) ((f 2))`, expect: "5"},
+		&tcase{src: `(define (sum l) (match l (() 0) ((x . rest) (+ x (sum rest))))) (sum (list 1 2 3))`, expect: "6"},
+		&tcase{src: `(define (loop n) (match n (0 'done) (_ (loop (- n 1))))) (loop 100)`, expect: "done", option: Option{CallInfoSize: 10}},
+		// match in tail position using the variables of the enclosing function
+		&tcase{src: `(define (f x y) (match x ((a) (+ a y)))) (f (list 1) 10)`, expect: "11"},
+		&tcase{src: `(define (f y x) (match x ((a b) (list a b y)))) (f 10 (list 1 2))`, expect: "(1 2 10)"},
+		&tcase{src: `(define (f y x) (match x ((a b) (lambda () (list a b y))))) ((f 10 (list 1 2)))`, expect: "(1 2 10)"},
+		&tcase{src: `(match 1 (2 'two))`, expectErr: true},
+		&tcase{src: `(match 1 (x))`, expectErr: true},
+		&tcase{src: `(match (list 1) ((... a) a))`, expectErr: true},
+		&tcase{src: `(match (list 1) ((= car) 1))`, expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestMatchError(t *testing.T) {
+	s := NewState(Option{})
+	err := s.ExecString(`(match 42 ("x" 1))`)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), "42") {
+		t.Fatalf("expected the unmatched value in the error, but got %v", err)
+	}
+}
+
+func TestDefineRecordType(t *testing.T) {
+	header := `(define-record-type <point> (make-point x y) point? (x point-x set-point-x!) (y point-y))`
+	tcases := []*tcase{
+		&tcase{src: header + `(point-x (make-point 1 2))`, expect: "1"},
+		&tcase{src: header + `(point? (make-point 1 2))`, expect: "#t"},
+		&tcase{src: header + `(point? (vector 1 2))`, expect: "#f"},
+		&tcase{src: header + `(define p (make-point 1 2)) (set-point-x! p 3) (point-x p)`, expect: "3"},
+		&tcase{src: header + `(match (make-point 1 2) (($ <point> a b) (- a b)))`, expect: "-1"},
+		&tcase{src: header + `(match (make-point 1 2) (($ <point> a) a))`, expect: "1"},
+		&tcase{src: header + `(define-record-type <pair> (kons a b) kons? (a kar) (b kdr)) (match (kons 1 2) (($ <point> a b) 'point) (_ 'other))`, expect: "other"},
+		&tcase{src: `(define-record-type node #f node? (value node-value)) (node? 1)`, expect: "#f"},
+		&tcase{src: `(define-record-type node make-node #f (a node-a) (b node-b)) (node-b (make-node 1 2))`, expect: "2"},
+		&tcase{src: `(define-record-type node (make-node b) #f (a node-a) (b node-b)) (node-a (make-node 2))`, expect: "#f"},
+		&tcase{src: header + `(point-x 1)`, expectErr: true},
+		&tcase{src: header + `(make-point 1)`, expectErr: true},
+		&tcase{src: header + `(match 1 (($ 1 a) a))`, expectErr: true},
+		&tcase{src: `(define-record-type node (make-node c) #f (a node-a))`, expectErr: true},
+		&tcase{src: `(define-record-type node)`, expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestPortRead(t *testing.T) {
+	stdin := func(str string) Option {
+		return Option{Stdin: strings.NewReader(str)}
+	}
+	tcases := []*tcase{
+		&tcase{src: `(read-char)`, expect: "a", option: stdin("ab")},
+		&tcase{src: `(read-char) (read-char)`, expect: "b", option: stdin("ab")},
+		&tcase{src: `(peek-char) (peek-char)`, expect: "a", option: stdin("ab")},
+		&tcase{src: `(read-char (current-input-port))`, expect: "あ", option: stdin("あい")},
+		&tcase{src: `(eof-object? (read-char))`, expect: "#t", option: stdin("")},
+		&tcase{src: `(eof-object? (peek-char))`, expect: "#t", option: stdin("")},
+		&tcase{src: `(read-line) (read-line)`, expect: "line2", option: stdin("line1\r\nline2")},
+		&tcase{src: `(read-line) (eof-object? (read-line))`, expect: "#t", option: stdin("line1\n")},
+		&tcase{src: `(read-string 3)`, expect: "abc", option: stdin("abcde")},
+		&tcase{src: `(read-string 3) (read-string 3)`, expect: "de", option: stdin("abcde")},
+		&tcase{src: `(read-string 3) (eof-object? (read-string 3))`, expect: "#t", option: stdin("abc")},
+		&tcase{src: `(read-u8)`, expectErr: true, option: stdin("a")},
+		&tcase{src: `(read-char (current-output-port))`, expectErr: true},
+		&tcase{src: `(read-char 1)`, expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestPortPredicates(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(port? (current-input-port))`, expect: "#t"},
+		&tcase{src: `(port? 1)`, expect: "#f"},
+		&tcase{src: `(input-port? (current-input-port))`, expect: "#t"},
+		&tcase{src: `(input-port? (current-output-port))`, expect: "#f"},
+		&tcase{src: `(output-port? (current-error-port))`, expect: "#t"},
+		&tcase{src: `(textual-port? (current-output-port))`, expect: "#t"},
+		&tcase{src: `(binary-port? (current-output-port))`, expect: "#f"},
+		&tcase{src: `(input-port-open? (current-input-port))`, expect: "#t"},
+		&tcase{src: `(close-port (current-input-port)) (input-port-open? (current-input-port))`, expect: "#f"},
+		&tcase{src: `(output-port-open? (current-input-port))`, expect: "#f"},
+		&tcase{src: `(close-port (current-input-port)) (read-char)`, expectErr: true},
+		&tcase{src: `(close-port 1)`, expectErr: true},
+	}
+	testTcases(t, tcases)
+}
+
+func TestPortWrite(t *testing.T) {
+	tcases := []struct {
+		src    string
+		stdout string
+		stderr string
+	}{
+		{src: `(display "abc") (newline)`, stdout: "abc\n"},
+		{src: `(write "abc") (write #\a)`, stdout: `"abc"#\a`},
+		{src: `(display #\a) (display 1.5)`, stdout: "a1.5"},
+		{src: `(write-char #\あ) (write-string "hello" (current-output-port) 1 3)`, stdout: "あel"},
+		{src: `(display "error" (current-error-port)) (flush-output-port (current-error-port))`, stderr: "error"},
+	}
+	for i, tc := range tcases {
+		var stdout, stderr bytes.Buffer
+		s := NewState(Option{Stdout: &stdout, Stderr: &stderr})
+		if err := s.ExecString(tc.src); err != nil {
+			t.Fatalf("case %d: unexpected error %v", i, err)
+		}
+		if stdout.String() != tc.stdout || stderr.String() != tc.stderr {
+			t.Fatalf("case %d: expected %q and %q, but got %q and %q", i, tc.stdout, tc.stderr, stdout.String(), stderr.String())
+		}
+	}
+	errcases := []string{
+		`(write-u8 1)`,
+		`(write-char "a")`,
+		`(write-string "abc" (current-output-port) 2 1)`,
+		`(display 1 (current-input-port))`,
+		`(close-port (current-output-port)) (display 1)`,
+	}
+	for i, src := range errcases {
+		s := NewState(Option{Stdout: &bytes.Buffer{}})
+		if err := s.ExecString(src); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestStringPort(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define p (open-input-string "ab")) (read-char p) (read-char p)`, expect: "b"},
+		&tcase{src: `(define p (open-input-string "")) (eof-object? (read-char p))`, expect: "#t"},
+		&tcase{src: "(read-line (open-input-string \"x\ny\"))", expect: "x"},
+		&tcase{src: `(define p (open-output-string)) (write-string "ab" p) (write-char #\c p) (get-output-string p)`, expect: "abc"},
+		&tcase{src: `(define p (open-output-string)) (display "a" p) (get-output-string p) (display "b" p) (get-output-string p)`, expect: "ab"},
+		&tcase{src: `(get-output-string (open-output-string))`, expect: ""},
+		&tcase{src: `(call-with-output-string (lambda (p) (display "x" p) (write "y" p)))`, expect: `x"y"`},
+		&tcase{src: `(with-output-to-string (lambda () (display "a") (newline)))`, expect: "a\n"},
+		&tcase{src: `(with-output-to-string (lambda () (display (with-output-to-string (lambda () (display 1)))) (display 2)))`, expect: "12"},
+		&tcase{src: `(define p (open-input-bytevector (bytevector 1 2))) (read-u8 p) (peek-u8 p)`, expect: "2"},
+		&tcase{src: `(define p (open-input-bytevector (bytevector 1 2))) (bytevector-u8-ref (read-bytevector 3 p) 1)`, expect: "2"},
+		&tcase{src: `(eof-object? (read-u8 (open-input-bytevector (bytevector))))`, expect: "#t"},
+		&tcase{src: `(define p (open-output-bytevector)) (write-u8 1 p) (write-bytevector (bytevector 2 3 4) p 1) (equal? (get-output-bytevector p) (bytevector 1 3 4))`, expect: "#t"},
+		&tcase{src: `(binary-port? (open-output-bytevector))`, expect: "#t"},
+		&tcase{src: `(get-output-string (current-output-port))`, expectErr: true},
+		&tcase{src: `(get-output-bytevector (open-output-string))`, expectErr: true},
+		&tcase{src: `(write-u8 1 (open-output-string))`, expectErr: true},
+		&tcase{src: `(with-output-to-string (lambda () (car 1)))`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestWithOutputToStringRestore(t *testing.T) {
+	var out bytes.Buffer
+	s := NewState(Option{Stdout: &out})
+	if err := s.ExecString(`(with-output-to-string (lambda () (car 1)))`); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := s.ExecString(`(display "ok")`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "ok" {
+		t.Fatalf("expected the current output port to be restored, but got %q", out.String())
+	}
+}
+
+func TestRead(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(car (cdr (read (open-input-string "(1 2) foo"))))`, expect: "2"},
+		&tcase{src: `(define p (open-input-string "(1 2) foo")) (read p) (read p)`, expect: "foo"},
+		&tcase{src: `(define p (open-input-string "1 ; comment")) (read p) (eof-object? (read p))`, expect: "#t"},
+		&tcase{src: `(eof-object? (read (open-input-string "")))`, expect: "#t"},
+		&tcase{src: `(read (open-input-string "'a"))`, expect: "(quote a)"},
+		&tcase{src: `(vector-ref (read (open-input-string "#(1 x)")) 1)`, expect: "x"},
+		&tcase{src: `(define p (open-input-string "abc def")) (read p) (read-char p) (read-char p)`, expect: "d"},
+		&tcase{src: `(define p (open-input-string "x(y)")) (read p) (car (read p))`, expect: "y"},
+		&tcase{src: `(read (open-input-string "(1 2"))`, expectErr: true},
+		&tcase{src: `(read (open-input-string ")"))`, expectErr: true},
+		&tcase{src: `(read (open-output-string))`, expectErr: true},
+		&tcase{src: `(read (open-input-bytevector (bytevector 1)))`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestReadError(t *testing.T) {
+	s := NewState(Option{Stdin: strings.NewReader("(a b)\n(c\n  #q)")})
+	if err := s.ExecString(`(read)`); err != nil {
+		t.Fatal(err)
+	}
+	err := s.ExecString(`(read)`)
+	e, ok := err.(*types.Error)
+	if !ok || e.ErrorType() != types.ErrRead {
+		t.Fatalf("expected read error, but got %v", err)
+	}
+	if pos := e.Position(); pos.Line != 3 || pos.Column != 3 {
+		t.Fatalf("expected 3:3, but got %v (%v)", pos, err)
+	}
+	pred, _ := s.GetGlobal("read-error?")
+	if v, _ := s.Call(pred, e); v != types.Boolean(true) {
+		t.Fatalf("expected (read-error? e) to be #t")
+	}
+}
+
+func TestWriteDisplay(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(call-with-output-string (lambda (p) (write '(a "b" #\c (d . e) #(1)) p)))`, expect: `(a "b" #\c (d . e) #(1))`},
+		&tcase{src: `(call-with-output-string (lambda (p) (display '(a "b" #\c) p)))`, expect: "(a b c)"},
+		&tcase{src: `(call-with-output-string (lambda (p) (write ''a p) (display ''a p)))`, expect: "'a(quote a)"},
+		&tcase{src: `(call-with-output-string (lambda (p) (write "x\ny\"" p)))`, expect: `"x\ny\""`},
+		&tcase{src: `(string-length "a\x3bb;\
+		   c")`, expect: "3"},
+		&tcase{src: `(define s "t\ta\\") (equal? s (read (open-input-string (call-with-output-string (lambda (p) (write s p))))))`, expect: "#t"},
+		&tcase{src: `(define (f) 1) (call-with-output-string (lambda (p) (write f p)))`, expect: "#<procedure f>"},
+		&tcase{src: `(define g (lambda (x) x)) (call-with-output-string (lambda (p) (display g p)))`, expect: "#<procedure g>"},
+		&tcase{src: `(call-with-output-string (lambda (p) (write (lambda () 1) p) (write car p)))`, expect: "#<procedure>#<procedure car>"},
+		&tcase{src: `(define-record-type <point> (make-point x y) point? (x point-x) (y point-y)) (call-with-output-string (lambda (p) (write (make-point 1 "a") p)))`, expect: `#<point x: 1 y: "a">`},
+	}
+	testTcases(t, tcases)
+}
+
+func TestDatumLabel(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define x (read (open-input-string "#0=(a b . #0#)"))) (eq? x (cddr x))`, expect: "#t"},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (call-with-output-string (lambda (p) (write x p)))`, expect: "#0=(1 2 . #0#)"},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (call-with-output-string (lambda (p) (display x p)))`, expect: "#0=(1 2 . #0#)"},
+		&tcase{src: `(define x (list 1)) (call-with-output-string (lambda (p) (write (list x x) p)))`, expect: "((1) (1))"},
+		&tcase{src: `(define x (list 1)) (call-with-output-string (lambda (p) (write-shared (list x x) p)))`, expect: "(#0=(1) #0#)"},
+		&tcase{src: `(call-with-output-string (lambda (p) (write-simple '(1 "a") p)))`, expect: `(1 "a")`},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (list? x)`, expect: "#f"},
+		&tcase{src: `(car (cddr '#0=(1 2 . #0#)))`, expect: "1"},
+		&tcase{src: `(cdr '(1 . 2))`, expect: "2"},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (length x)`, expectErr: true},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (list-copy x)`, expectErr: true},
+		&tcase{src: `(define x (list 1 2)) (set-cdr! (cdr x) x) (match x ((a ...) a) (_ 'no))`, expect: "no"},
+		&tcase{src: `(equal? '#0=(1 . #0#) '#1=(1 . #1#))`, expect: "#t"},
+		&tcase{src: `(equal? '#0=(1 . #0#) '#1=(1 2 . #1#))`, expect: "#f"},
+		&tcase{src: `(define (cycle . elems) ((lambda (x) (set-cdr! (list-tail x (- (length x) 1)) x) x) (list-copy elems))) (list (equal? (cycle 1 2) (cycle 1 2 1 2)) (equal? (cycle 1 2) (cycle 1 2 1)))`, expect: "(#t #f)"},
+		&tcase{src: `(equal? '#0=#(1 #0#) '#1=#(1 #1#))`, expect: "#t"},
 	}
 	testTcases(t, tcases)
 }
@@ -0,0 +1,361 @@
+package tama
+
+import (
+	"encoding/binary"
+	"github.com/hyusuk/tama/types"
+	"math"
+	"unicode/utf8"
+)
+
+// OpenBytevector registers the bytevector library.
+// It provides the bytevector procedures of R7RS and the endian-aware accessors of R6RS.
+func (s *State) OpenBytevector() *State {
+	s.RegisterFunc("bytevector?", 1, 1, fnIsBv)
+	s.RegisterFunc("make-bytevector", 1, 2, fnMakeBv)
+	s.RegisterFunc("bytevector", 0, -1, fnBv)
+	s.RegisterFunc("bytevector-length", 1, 1, fnBvLen)
+	s.RegisterFunc("bytevector-u8-ref", 2, 2, fnBvU8Ref)
+	s.RegisterFunc("bytevector-u8-set!", 3, 3, fnBvU8Set)
+	s.RegisterFunc("bytevector-copy", 1, 3, fnBvCopy)
+	s.RegisterFunc("bytevector-copy!", 3, 5, fnBvCopyTo)
+	s.RegisterFunc("bytevector-append", 0, -1, fnBvAppend)
+	s.RegisterFunc("utf8->string", 1, 3, fnUtf8ToStr)
+	s.RegisterFunc("string->utf8", 1, 3, fnStrToUtf8)
+
+	// R6RS
+	s.RegisterFunc("native-endianness", 0, 0, fnNativeEndianness)
+	s.RegisterFunc("bytevector-u16-ref", 3, 3, genFnBvIntRef(2, false))
+	s.RegisterFunc("bytevector-s16-ref", 3, 3, genFnBvIntRef(2, true))
+	s.RegisterFunc("bytevector-u32-ref", 3, 3, genFnBvIntRef(4, false))
+	s.RegisterFunc("bytevector-s32-ref", 3, 3, genFnBvIntRef(4, true))
+	s.RegisterFunc("bytevector-u64-ref", 3, 3, genFnBvIntRef(8, false))
+	s.RegisterFunc("bytevector-s64-ref", 3, 3, genFnBvIntRef(8, true))
+	s.RegisterFunc("bytevector-u16-set!", 4, 4, genFnBvIntSet(2, false))
+	s.RegisterFunc("bytevector-s16-set!", 4, 4, genFnBvIntSet(2, true))
+	s.RegisterFunc("bytevector-u32-set!", 4, 4, genFnBvIntSet(4, false))
+	s.RegisterFunc("bytevector-s32-set!", 4, 4, genFnBvIntSet(4, true))
+	s.RegisterFunc("bytevector-u64-set!", 4, 4, genFnBvIntSet(8, false))
+	s.RegisterFunc("bytevector-s64-set!", 4, 4, genFnBvIntSet(8, true))
+	s.RegisterFunc("bytevector-ieee-single-ref", 3, 3, genFnBvFloatRef(4))
+	s.RegisterFunc("bytevector-ieee-double-ref", 3, 3, genFnBvFloatRef(8))
+	s.RegisterFunc("bytevector-ieee-single-set!", 4, 4, genFnBvFloatSet(4))
+	s.RegisterFunc("bytevector-ieee-double-set!", 4, 4, genFnBvFloatSet(8))
+	return s
+}
+
+// toByte converts obj to a byte.
+func toByte(obj types.Object) (byte, error) {
+	if err := types.AssertType(types.TyNumber, obj); err != nil {
+		return 0, err
+	}
+	num := obj.(types.Number)
+	if num < 0 || num > 255 || num != types.Number(int(num)) {
+		return 0, types.NewTypeError("byte required, but got %v", num)
+	}
+	return byte(num), nil
+}
+
+func fnIsBv(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyBytevector), nil
+}
+
+func fnMakeBv(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	var fill byte
+	if len(args) > 1 {
+		if fill, err = toByte(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	b := make([]byte, k)
+	for i := range b {
+		b[i] = fill
+	}
+	return types.NewBytevector(b), nil
+}
+
+func fnBv(s *State, args []types.Object) (types.Object, error) {
+	b := make([]byte, len(args))
+	for i, arg := range args {
+		var err error
+		if b[i], err = toByte(arg); err != nil {
+			return nil, err
+		}
+	}
+	return types.NewBytevector(b), nil
+}
+
+func fnBvLen(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	return types.Number(args[0].(*types.Bytevector).Len()), nil
+}
+
+func fnBvU8Ref(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bv := args[0].(*types.Bytevector)
+	k, err := toIndex(args[1], bv.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(bv.Bytes()[k]), nil
+}
+
+func fnBvU8Set(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bv := args[0].(*types.Bytevector)
+	k, err := toIndex(args[1], bv.Len()-1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toByte(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if err := bv.Set(k, b); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnBvCopy(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bv := args[0].(*types.Bytevector)
+	start, end, err := toRange(args[1:], bv.Len())
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, end-start)
+	copy(b, bv.Bytes()[start:end])
+	return types.NewBytevector(b), nil
+}
+
+// (bytevector-copy! to at from [start [end]])
+func fnBvCopyTo(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0], args[2]); err != nil {
+		return nil, err
+	}
+	to := args[0].(*types.Bytevector)
+	from := args[2].(*types.Bytevector)
+	at, err := toIndex(args[1], to.Len())
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[3:], from.Len())
+	if err != nil {
+		return nil, err
+	}
+	if to.Len()-at < end-start {
+		return nil, types.NewInternalError("not enough room to copy %d bytes", end-start)
+	}
+	if err := to.CheckMutable(); err != nil {
+		return nil, err
+	}
+	copy(to.Bytes()[at:], from.Bytes()[start:end])
+	return types.UndefinedObject, nil
+}
+
+func fnBvAppend(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args...); err != nil {
+		return nil, err
+	}
+	b := []byte{}
+	for _, arg := range args {
+		b = append(b, arg.(*types.Bytevector).Bytes()...)
+	}
+	return types.NewBytevector(b), nil
+}
+
+func fnUtf8ToStr(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, err
+	}
+	bv := args[0].(*types.Bytevector)
+	start, end, err := toRange(args[1:], bv.Len())
+	if err != nil {
+		return nil, err
+	}
+	b := bv.Bytes()[start:end]
+	if !utf8.Valid(b) {
+		return nil, types.NewInternalError("invalid utf-8 sequence")
+	}
+	return types.NewString(string(b)), nil
+}
+
+func fnStrToUtf8(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[1:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBytevector([]byte(str.Substring(start, end))), nil
+}
+
+// R6RS
+
+func fnNativeEndianness(s *State, args []types.Object) (types.Object, error) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) == 1 {
+		return types.NewSymbol("little"), nil
+	}
+	return types.NewSymbol("big"), nil
+}
+
+// toByteOrder converts the endianness symbol obj to a byte order.
+func toByteOrder(obj types.Object) (binary.ByteOrder, error) {
+	if err := types.AssertType(types.TySymbol, obj); err != nil {
+		return nil, err
+	}
+	switch obj.(*types.Symbol).Name {
+	case "big":
+		return binary.BigEndian, nil
+	case "little":
+		return binary.LittleEndian, nil
+	}
+	return nil, types.NewInternalError("unknown endianness %v", obj)
+}
+
+// bvSlot returns the size bytes of the bytevector args[0] at the index args[1],
+// and the byte order args[bo].
+func bvSlot(args []types.Object, size int, bo int) (*types.Bytevector, []byte, binary.ByteOrder, error) {
+	if err := types.AssertType(types.TyBytevector, args[0]); err != nil {
+		return nil, nil, nil, err
+	}
+	bv := args[0].(*types.Bytevector)
+	k, err := toIndex(args[1], bv.Len()-size)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	order, err := toByteOrder(args[bo])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return bv, bv.Bytes()[k : k+size], order, nil
+}
+
+func readUint(b []byte, order binary.ByteOrder) uint64 {
+	switch len(b) {
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	default:
+		return order.Uint64(b)
+	}
+}
+
+func writeUint(b []byte, order binary.ByteOrder, v uint64) {
+	switch len(b) {
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	default:
+		order.PutUint64(b, v)
+	}
+}
+
+// genFnBvIntRef generates (bytevector-[us]<size>-ref bytevector k endianness).
+// It is an error if the magnitude of the value is greater than 2^53, since the
+// number could not be represented exactly.
+func genFnBvIntRef(size int, signed bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		_, b, order, err := bvSlot(args, size, 2)
+		if err != nil {
+			return nil, err
+		}
+		v := readUint(b, order)
+		if signed {
+			shift := uint(64 - size*8)
+			n := int64(v<<shift) >> shift
+			if n > 1<<53 || n < -(1<<53) {
+				return nil, types.NewInternalError("value %d cannot be represented exactly", n)
+			}
+			return types.Number(n), nil
+		}
+		if v > 1<<53 {
+			return nil, types.NewInternalError("value %d cannot be represented exactly", v)
+		}
+		return types.Number(v), nil
+	}
+}
+
+// genFnBvIntSet generates (bytevector-[us]<size>-set! bytevector k n endianness).
+func genFnBvIntSet(size int, signed bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		bv, b, order, err := bvSlot(args, size, 3)
+		if err != nil {
+			return nil, err
+		}
+		if err := types.AssertType(types.TyNumber, args[2]); err != nil {
+			return nil, err
+		}
+		n := float64(args[2].(types.Number))
+		bits := float64(size * 8)
+		lo, hi := 0.0, math.Exp2(bits)
+		if signed {
+			lo, hi = -math.Exp2(bits-1), math.Exp2(bits-1)
+		}
+		if n != math.Trunc(n) || n < lo || n >= hi {
+			return nil, types.NewInternalError("value out of range: %v", args[2])
+		}
+		if err := bv.CheckMutable(); err != nil {
+			return nil, err
+		}
+		if signed {
+			writeUint(b, order, uint64(int64(n)))
+		} else {
+			writeUint(b, order, uint64(n))
+		}
+		return types.UndefinedObject, nil
+	}
+}
+
+// genFnBvFloatRef generates (bytevector-ieee-{single,double}-ref bytevector k endianness).
+func genFnBvFloatRef(size int) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		_, b, order, err := bvSlot(args, size, 2)
+		if err != nil {
+			return nil, err
+		}
+		if size == 4 {
+			return types.Number(math.Float32frombits(order.Uint32(b))), nil
+		}
+		return types.Number(math.Float64frombits(order.Uint64(b))), nil
+	}
+}
+
+// genFnBvFloatSet generates (bytevector-ieee-{single,double}-set! bytevector k x endianness).
+func genFnBvFloatSet(size int) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		bv, b, order, err := bvSlot(args, size, 3)
+		if err != nil {
+			return nil, err
+		}
+		if err := types.AssertType(types.TyNumber, args[2]); err != nil {
+			return nil, err
+		}
+		if err := bv.CheckMutable(); err != nil {
+			return nil, err
+		}
+		x := float64(args[2].(types.Number))
+		if size == 4 {
+			order.PutUint32(b, math.Float32bits(float32(x)))
+		} else {
+			order.PutUint64(b, math.Float64bits(x))
+		}
+		return types.UndefinedObject, nil
+	}
+}
@@ -0,0 +1,92 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"testing"
+)
+
+func TestFnBv(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(bytevector? #u8(1 2))", expect: "#t"},
+		&tcase{src: "(bytevector? #(1 2))", expect: "#f"},
+		&tcase{src: "(bytevector-length (make-bytevector 3 7))", expect: "3"},
+		&tcase{src: "(bytevector-u8-ref (make-bytevector 3 7) 2)", expect: "7"},
+		&tcase{src: "(bytevector-u8-ref (bytevector 1 2 255) 2)", expect: "255"},
+		&tcase{src: "(bytevector 256)", expectErr: true},
+		&tcase{src: "(bytevector-u8-ref #u8(1 2) 2)", expectErr: true},
+		&tcase{src: "(define b (bytevector 1 2)) (bytevector-u8-set! b 0 9) (bytevector-u8-ref b 0)", expect: "9"},
+		&tcase{src: "(bytevector-u8-set! #u8(1 2) 0 9)", expectErr: true},
+		&tcase{src: "(bytevector-u8-set! (bytevector 1 2) 0 -1)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestFnBvCopy(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define b (bytevector-copy #u8(1 2 3))) (bytevector-u8-set! b 0 9) (bytevector-u8-ref b 0)", expect: "9"},
+		&tcase{src: "(bytevector-length (bytevector-copy #u8(1 2 3) 1))", expect: "2"},
+		&tcase{src: "(define b (make-bytevector 4 0)) (bytevector-copy! b 1 #u8(1 2 3) 1) (bytevector-u8-ref b 2)", expect: "3"},
+		&tcase{src: "(bytevector-copy! (make-bytevector 1) 0 #u8(1 2))", expectErr: true},
+		&tcase{src: "(bytevector-u8-ref (bytevector-append #u8(1) #u8(2 3)) 2)", expect: "3"},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestFnUtf8(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(utf8->string #u8(104 195 169))", expect: "hé"},
+		&tcase{src: "(utf8->string #u8(104 195 169 33) 1 3)", expect: "é"},
+		&tcase{src: "(utf8->string #u8(195))", expectErr: true},
+		&tcase{src: "(bytevector-length (string->utf8 \"héllo\"))", expect: "6"},
+		&tcase{src: "(bytevector-length (string->utf8 \"héllo\" 1 2))", expect: "2"},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestFnBvEndian(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(bytevector-u16-ref #u8(1 2) 0 'big)", expect: "258"},
+		&tcase{src: "(bytevector-u16-ref #u8(1 2) 0 'little)", expect: "513"},
+		&tcase{src: "(bytevector-s16-ref #u8(255 254) 0 'big)", expect: "-2"},
+		&tcase{src: "(bytevector-u32-ref #u8(0 0 0 1 0) 1 'little)", expect: "65536"},
+		&tcase{src: "(bytevector-s32-ref #u8(255 255 255 255) 0 'big)", expect: "-1"},
+		&tcase{src: "(bytevector-s64-ref #u8(255 255 255 255 255 255 255 255) 0 'big)", expect: "-1"},
+		&tcase{src: "(= (bytevector-u64-ref #u8(0 32 0 0 0 0 0 0) 0 'big) 9007199254740992)", expect: "#t"},
+		&tcase{src: "(= (bytevector-s64-ref #u8(255 224 0 0 0 0 0 0) 0 'big) -9007199254740992)", expect: "#t"},
+		&tcase{src: "(bytevector-u64-ref #u8(0 32 0 0 0 0 0 1) 0 'big)", expectErr: true},
+		&tcase{src: "(bytevector-s64-ref #u8(255 223 255 255 255 255 255 255) 0 'big)", expectErr: true},
+		&tcase{src: "(bytevector-u64-ref #u8(255 255 255 255 255 255 255 255) 0 'big)", expectErr: true},
+		&tcase{src: "(bytevector-u32-ref #u8(0 0 0 1) 1 'big)", expectErr: true},
+		&tcase{src: "(bytevector-u16-ref #u8(0 0) 0 'middle)", expectErr: true},
+		&tcase{src: "(define b (make-bytevector 4 0)) (bytevector-u32-set! b 0 305419896 'little) (bytevector-u8-ref b 0)", expect: "120"},
+		&tcase{src: "(define b (make-bytevector 2 0)) (bytevector-s16-set! b 0 -2 'big) (bytevector-u16-ref b 0 'big)", expect: "65534"},
+		&tcase{src: "(bytevector-u16-set! (make-bytevector 2) 0 65536 'big)", expectErr: true},
+		&tcase{src: "(bytevector-s16-set! (make-bytevector 2) 0 -32769 'big)", expectErr: true},
+		&tcase{src: "(define b (make-bytevector 8 0)) (bytevector-ieee-double-set! b 0 1.5 'big) (bytevector-ieee-double-ref b 0 'big)", expect: "1.5"},
+		&tcase{src: "(define b (make-bytevector 4 0)) (bytevector-ieee-single-set! b 0 -0.25 'little) (bytevector-ieee-single-ref b 0 'little)", expect: "-0.25"},
+		&tcase{src: "(bytevector-ieee-single-ref #u8(63 128 0 0) 0 'big)", expect: "1"},
+	}
+	testTcases(t, tcases, (*State).OpenBytevector)
+}
+
+func TestBvGoFunc(t *testing.T) {
+	s := NewState(Option{}).OpenBytevector()
+	payload := []byte{1, 2, 3}
+	s.RegisterFunc("payload", 0, 0, func(s *State, args []types.Object) (types.Object, error) {
+		return types.NewBytevector(payload), nil
+	})
+	var received []byte
+	s.RegisterFunc("receive", 1, 1, func(s *State, args []types.Object) (types.Object, error) {
+		received = args[0].(*types.Bytevector).Bytes()
+		return types.UndefinedObject, nil
+	})
+	if err := s.ExecString("(define b (payload)) (bytevector-u8-set! b 0 9) (receive b)"); err != nil {
+		t.Fatal(err)
+	}
+	if payload[0] != 9 {
+		t.Fatalf("expected %d, but got %d", 9, payload[0])
+	}
+	if &received[0] != &payload[0] {
+		t.Fatalf("expected the same storage")
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 type Compiler struct {
 	global map[string]types.Object
+	spans  types.SourceMap
 }
 
 type varType int
@@ -21,14 +22,14 @@ type reg struct {
 }
 
 type nameStorage struct {
-	names    []types.String
+	names    []string
 	len      int
 	capacity int
 }
 
 func newNameStorage(cap int) *nameStorage {
 	return &nameStorage{
-		names:    make([]types.String, cap),
+		names:    make([]string, cap),
 		len:      0,
 		capacity: cap,
 	}
@@ -42,11 +43,11 @@ func (ns *nameStorage) Capacity() int {
 	return ns.capacity
 }
 
-func (ns *nameStorage) Name(index int) types.String {
+func (ns *nameStorage) Name(index int) string {
 	return ns.names[index]
 }
 
-func (ns *nameStorage) Find(name types.String) int {
+func (ns *nameStorage) Find(name string) int {
 	for i, nm := range ns.names {
 		if nm == name {
 			return i
@@ -58,13 +59,13 @@ func (ns *nameStorage) Find(name types.String) int {
 func (ns *nameStorage) grow() {
 	if ns.len >= ns.capacity {
 		ns.capacity = (ns.capacity + 1) * 2
-		newOne := make([]types.String, ns.capacity)
+		newOne := make([]string, ns.capacity)
 		copy(newOne, ns.names)
 		ns.names = newOne
 	}
 }
 
-func (ns *nameStorage) Register(name types.String) int {
+func (ns *nameStorage) Register(name string) int {
 	i := ns.Find(name)
 	if i >= 0 {
 		return i
@@ -82,11 +83,12 @@ type funcState struct {
 	prev          *funcState          // enclosing function
 	locVars       *nameStorage
 	upVals        *nameStorage
-	closeRequired bool // whether upvalues inside the function need to be closed or
+	closeRequired bool      // whether upvalues inside the function need to be closed or
+	pos           types.Pos // source position of the form being compiled
 }
 
 func newFuncState(prev *funcState) *funcState {
-	return &funcState{
+	fs := &funcState{
 		proto:         types.NewClosureProto(),
 		nreg:          0,
 		prev:          prev,
@@ -94,6 +96,10 @@ func newFuncState(prev *funcState) *funcState {
 		upVals:        newNameStorage(16),
 		closeRequired: false,
 	}
+	if prev != nil {
+		fs.pos = prev.pos
+	}
+	return fs
 }
 
 func (fs *funcState) newReg() *reg {
@@ -104,6 +110,7 @@ func (fs *funcState) newReg() *reg {
 
 func (fs *funcState) add(inst uint32) {
 	fs.proto.Insts = append(fs.proto.Insts, inst)
+	fs.proto.LineInfo = append(fs.proto.LineInfo, fs.pos)
 }
 
 func (fs *funcState) addABx(op int, a int, bx int) {
@@ -127,22 +134,28 @@ func (fs *funcState) constIndex(v types.Object) int {
 		if cs == v {
 			return i
 		}
+		// symbols are not interned, so compare them by name
+		sym1, ok1 := cs.(*types.Symbol)
+		sym2, ok2 := v.(*types.Symbol)
+		if ok1 && ok2 && sym1.Name == sym2.Name {
+			return i
+		}
 	}
 	fs.proto.Consts = append(fs.proto.Consts, v)
 	return len(fs.proto.Consts) - 1
 }
 
-func (fs *funcState) bindLocVar(name types.String) int {
+func (fs *funcState) bindLocVar(name string) int {
 	fs.locVars.Register(name)
 	fs.nreg++
 	return fs.nreg - 1
 }
 
-func (fs *funcState) findLocVar(name types.String) int {
+func (fs *funcState) findLocVar(name string) int {
 	return fs.locVars.Find(name)
 }
 
-func (fs *funcState) upValueIndex(name types.String) int {
+func (fs *funcState) upValueIndex(name string) int {
 	i := fs.upVals.Find(name)
 	if i < 0 {
 		return fs.upVals.Register(name)
@@ -170,8 +183,40 @@ func (fs *funcState) currentPc() int {
 	return len(fs.proto.Insts) - 1
 }
 
+// freeze makes obj and the objects inside it immutable,
+// so that mutating a literal cannot modify the constants of the closure prototype.
+// Objects already immutable are not traversed again, so cyclic literals terminate.
+func freeze(obj types.Object) {
+	for {
+		switch o := obj.(type) {
+		case *types.String:
+			o.Freeze()
+		case *types.Pair:
+			if o.IsImmutable() {
+				return
+			}
+			o.Freeze()
+			freeze(o.Car())
+			obj = o.Cdr()
+			continue
+		case *types.Bytevector:
+			o.Freeze()
+		case *types.Vector:
+			if o.IsImmutable() {
+				return
+			}
+			o.Freeze()
+			for _, elem := range o.Elems() {
+				freeze(elem)
+			}
+		}
+		return
+	}
+}
+
 func (c *Compiler) compileConst(fs *funcState, obj types.Object) *reg {
 	r := fs.newReg()
+	freeze(obj)
 	fs.addABx(OP_LOADK, r.n, fs.constIndex(obj))
 	return r
 }
@@ -183,7 +228,7 @@ func (c *Compiler) compileSymbol(fs *funcState, sym *types.Symbol) *reg {
 		return &reg{n: index}
 	case varGlobal:
 		r := fs.newReg()
-		fs.addABx(OP_GETGLOBAL, r.n, fs.constIndex(sym.Name))
+		fs.addABx(OP_GETGLOBAL, r.n, fs.constIndex(sym))
 		return r
 	case varUpValue:
 		r := fs.newReg()
@@ -199,7 +244,7 @@ func (c *Compiler) compileGlobalAssign(fs *funcState, varname *types.Symbol, val
 	if err != nil {
 		return nil, err
 	}
-	fs.addABx(OP_SETGLOBAL, valueR.n, fs.constIndex(varname.Name))
+	fs.addABx(OP_SETGLOBAL, valueR.n, fs.constIndex(varname))
 	return valueR, nil
 }
 
@@ -227,30 +272,12 @@ func (c *Compiler) compileDefine(fs *funcState, args []types.Object) (*reg, erro
 		}
 		varname = sym
 		lambdaExpr := []types.Object{types.NewSymbol("lambda")}
-		if first.Len() == 3 {
-			second, _ := first.Second()
-			sym, ok := second.(*types.Symbol)
-			if !ok {
-				return nil, types.NewSyntaxError("define: invalid syntax")
-			}
-			// convert
-			// (define (variable . formal) body)
-			// =>
-			// (define variable
-			//   (lambda formal body))
-			if sym.Name == "." {
-				cddar, _ := first.Cddar()
-				lambdaExpr = append(lambdaExpr, cddar)
-				lambdaExpr = append(lambdaExpr, args[1:]...)
-				expr = types.List(lambdaExpr...)
-				break
-			}
-		}
 		// convert
 		// (define (variable formals) body)
 		// =>
 		// (define variable
 		//   (lambda (formals) body))
+		// (define (variable . formal) body) is converted to (lambda formal body) likewise.
 		lambdaExpr = append(lambdaExpr, first.Cdr())
 		lambdaExpr = append(lambdaExpr, args[1:]...)
 		expr = types.List(lambdaExpr...)
@@ -261,11 +288,43 @@ func (c *Compiler) compileDefine(fs *funcState, args []types.Object) (*reg, erro
 	if err != nil {
 		return nil, err
 	}
+	if isLambdaForm(expr) {
+		// the last prototype is the one of the lambda expression
+		fs.proto.Protos[len(fs.proto.Protos)-1].Name = varname.Name
+	}
 	r := fs.newReg()
 	fs.addABC(OP_LOADUNDEF, r.n, r.n, 0)
 	return r, nil
 }
 
+// isLambdaForm reports whether obj is a (lambda ...) expression.
+func isLambdaForm(obj types.Object) bool {
+	pair, ok := obj.(*types.Pair)
+	if !ok {
+		return false
+	}
+	sym, ok := pair.Car().(*types.Symbol)
+	return ok && sym.Name == "lambda"
+}
+
+// dottedSlice returns the elements of the list p. The tail of an improper list
+// follows the symbol ".", so (a b . c) gives [a b . c].
+func dottedSlice(p *types.Pair) ([]types.Object, error) {
+	if p.Len() < 0 {
+		return nil, types.NewSyntaxError("%v is a circular list", p)
+	}
+	elems := []types.Object{}
+	var obj types.Object = p
+	for pair, ok := obj.(*types.Pair); ok; pair, ok = obj.(*types.Pair) {
+		elems = append(elems, pair.Car())
+		obj = pair.Cdr()
+	}
+	if obj != types.NilObject {
+		elems = append(elems, types.NewSymbol("."), obj)
+	}
+	return elems, nil
+}
+
 func (c *Compiler) lambdaForm(formals types.Object) ([]*types.Symbol, types.ArgMode, error) {
 	var argSyms []*types.Symbol
 
@@ -278,7 +337,7 @@ func (c *Compiler) lambdaForm(formals types.Object) ([]*types.Symbol, types.ArgM
 		mode = types.VArgMode
 		argSyms = []*types.Symbol{args}
 	case *types.Pair:
-		argsArr, err := args.Slice()
+		argsArr, err := dottedSlice(args)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -340,7 +399,8 @@ func (c *Compiler) compileLambda(fs *funcState, lambdaArgs []types.Object) (*reg
 		return nil, err
 	}
 	if child.closeRequired {
-		child.addABC(OP_CLOSE, child.locVars.Len()-1, 0, 0)
+		// close the upvalues of all the local variables, not only the last one
+		child.addABC(OP_CLOSE, 0, 0, 0)
 	}
 	child.addABC(OP_RETURN, resultR.n, 2, 0)
 
@@ -420,9 +480,7 @@ func (c *Compiler) compileQuote(fs *funcState, argsArr []types.Object) (*reg, er
 	if len(argsArr) != 1 {
 		return nil, types.NewSyntaxError("quote: invalid syntax")
 	}
-	r := fs.newReg()
-	fs.addABx(OP_LOADK, r.n, fs.constIndex(argsArr[0]))
-	return r, nil
+	return c.compileConst(fs, argsArr[0]), nil
 }
 
 func (c *Compiler) compileIf(fs *funcState, argsArr []types.Object) (*reg, error) {
@@ -482,6 +540,165 @@ func (c *Compiler) compileCallCC(fs *funcState, argsArr []types.Object) (*reg, e
 	return lambdaR, nil
 }
 
+// compileDelay compiles delay and delay-force syntax.
+//
+// (delay expression)
+// (delay-force expression)
+func (c *Compiler) compileDelay(fs *funcState, name string, args []types.Object, lazy bool) (*reg, error) {
+	if len(args) != 1 {
+		return nil, types.NewSyntaxError("%s: invalid syntax", name)
+	}
+	thunkR, err := c.compileLambda(fs, []types.Object{types.NilObject, args[0]})
+	if err != nil {
+		return nil, err
+	}
+	flag := 0
+	if lazy {
+		flag = 1
+	}
+	r := fs.newReg()
+	fs.addABC(OP_PROMISE, r.n, thunkR.n, flag)
+	return r, nil
+}
+
+// compileStreamCons compiles stream-cons syntax. (SRFI-41)
+//
+// (stream-cons object stream)
+func (c *Compiler) compileStreamCons(fs *funcState, args []types.Object) (*reg, error) {
+	if len(args) != 2 {
+		return nil, types.NewSyntaxError("stream-cons: invalid syntax")
+	}
+	carR, err := c.compileDelay(fs, "stream-cons", args[:1], false)
+	if err != nil {
+		return nil, err
+	}
+	cdrR, err := c.compileDelay(fs, "stream-cons", args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	r := fs.newReg()
+	fs.addABC(OP_STREAMCONS, r.n, carR.n, cdrR.n)
+	return r, nil
+}
+
+// compileStreamLambda compiles stream-lambda syntax. (SRFI-41)
+//
+// convert
+// (stream-lambda formals body)
+// =>
+// (lambda formals (delay-force (begin body)))
+func (c *Compiler) compileStreamLambda(fs *funcState, args []types.Object) (*reg, error) {
+	if len(args) < 2 {
+		return nil, types.NewSyntaxError("stream-lambda: invalid syntax")
+	}
+	body := types.Cons(types.NewSymbol("begin"), types.List(args[1:]...))
+	return c.compileLambda(fs, []types.Object{args[0], types.List(types.NewSymbol("delay-force"), body)})
+}
+
+// compileDefineStream compiles define-stream syntax. (SRFI-41)
+//
+// convert
+// (define-stream (variable formals) body)
+// =>
+// (define variable (stream-lambda (formals) body))
+func (c *Compiler) compileDefineStream(fs *funcState, args []types.Object) (*reg, error) {
+	if len(args) < 2 {
+		return nil, types.NewSyntaxError("define-stream: invalid syntax")
+	}
+	first, ok := args[0].(*types.Pair)
+	if !ok {
+		return nil, types.NewSyntaxError("define-stream: invalid syntax")
+	}
+	varname, ok := first.Car().(*types.Symbol)
+	if !ok {
+		return nil, types.NewSyntaxError("define-stream: invalid syntax")
+	}
+	formals := first.Cdr()
+	lambdaExpr := types.Cons(types.NewSymbol("stream-lambda"), types.Cons(formals, types.List(args[1:]...)))
+	if _, err := c.compileGlobalAssign(fs, varname, lambdaExpr); err != nil {
+		return nil, err
+	}
+	r := fs.newReg()
+	fs.addABC(OP_LOADUNDEF, r.n, r.n, 0)
+	return r, nil
+}
+
+// compileDefineRecordType compiles define-record-type syntax.
+//
+// convert
+// (define-record-type <point> (make-point x y) point? (x point-x set-point-x!) (y point-y))
+// =>
+// (begin
+// (define <point> (make-record-type '<point> '(x y)))
+// (define make-point (record-constructor <point> '(x y)))
+// (define point? (record-predicate <point>))
+// (define point-x (record-accessor <point> 'x))
+// (define set-point-x! (record-modifier <point> 'x))
+// (define point-y (record-accessor <point> 'y)))
+//
+// The constructor can be #f for no constructor, or a symbol for the constructor of all the fields.
+// The predicate can be #f for no predicate.
+func (c *Compiler) compileDefineRecordType(fs *funcState, args []types.Object) (*reg, error) {
+	if len(args) < 3 {
+		return nil, types.NewSyntaxError("define-record-type: invalid syntax")
+	}
+	typeName, ok := args[0].(*types.Symbol)
+	if !ok {
+		return nil, types.NewSyntaxError("define-record-type: invalid syntax")
+	}
+	quote := func(obj types.Object) types.Object {
+		return types.List(types.NewSymbol("quote"), obj)
+	}
+	define := func(name types.Object, proc string, procArgs ...types.Object) types.Object {
+		expr := types.Cons(types.NewSymbol(proc), types.List(procArgs...))
+		return types.List(types.NewSymbol("define"), name, expr)
+	}
+
+	fieldNames := []types.Object{}
+	accessors := []types.Object{}
+	for _, spec := range args[3:] {
+		pair, ok := spec.(*types.Pair)
+		if !ok {
+			return nil, types.NewSyntaxError("define-record-type: invalid field %v", spec)
+		}
+		elems, err := pair.Slice()
+		if err != nil || len(elems) > 3 {
+			return nil, types.NewSyntaxError("define-record-type: invalid field %v", spec)
+		}
+		for _, elem := range elems {
+			if _, ok := elem.(*types.Symbol); !ok {
+				return nil, types.NewSyntaxError("define-record-type: invalid field %v", spec)
+			}
+		}
+		fieldNames = append(fieldNames, elems[0])
+		if len(elems) > 1 {
+			accessors = append(accessors, define(elems[1], "record-accessor", typeName, quote(elems[0])))
+		}
+		if len(elems) > 2 {
+			accessors = append(accessors, define(elems[2], "record-modifier", typeName, quote(elems[0])))
+		}
+	}
+
+	defs := []types.Object{define(typeName, "make-record-type", quote(typeName), quote(types.List(fieldNames...)))}
+	switch ctor := args[1].(type) {
+	case types.Boolean: // no constructor
+	case *types.Symbol:
+		defs = append(defs, define(ctor, "record-constructor", typeName, quote(types.List(fieldNames...))))
+	case *types.Pair:
+		elems, err := ctor.Slice()
+		if err != nil {
+			return nil, types.NewSyntaxError("define-record-type: invalid constructor %v", ctor)
+		}
+		defs = append(defs, define(elems[0], "record-constructor", typeName, quote(types.List(elems[1:]...))))
+	default:
+		return nil, types.NewSyntaxError("define-record-type: invalid constructor %v", ctor)
+	}
+	if pred, ok := args[2].(*types.Symbol); ok {
+		defs = append(defs, define(pred, "record-predicate", typeName))
+	}
+	return c.compileBegin(fs, append(defs, accessors...))
+}
+
 func (c *Compiler) compileCall(fs *funcState, proc types.Object, args []types.Object, tail bool) (*reg, error) {
 	procR, err := c.compileObject(fs, proc)
 	if err != nil {
@@ -513,7 +730,24 @@ func (c *Compiler) compileCall(fs *funcState, proc types.Object, args []types.Ob
 	return newProcR, nil
 }
 
+// compilePair compiles the form pair. If the span of pair is known, the instructions
+// are attributed to it and the errors without positions are reported at it.
 func (c *Compiler) compilePair(fs *funcState, pair *types.Pair, tail bool) (*reg, error) {
+	span, ok := c.spans[pair]
+	if !ok {
+		return c.compileForm(fs, pair, tail)
+	}
+	outer := fs.pos
+	fs.pos = span.Start
+	r, err := c.compileForm(fs, pair, tail)
+	fs.pos = outer
+	if e, ok := err.(*types.Error); ok && !e.Position().IsValid() {
+		e.SetPosition(span.Start)
+	}
+	return r, err
+}
+
+func (c *Compiler) compileForm(fs *funcState, pair *types.Pair, tail bool) (*reg, error) {
 	if pair.Len() == 0 {
 		return nil, types.NewSyntaxError("invalid syntax %s", pair.String())
 	}
@@ -544,6 +778,20 @@ func (c *Compiler) compilePair(fs *funcState, pair *types.Pair, tail bool) (*reg
 			return c.compileIf(fs, argsArr)
 		case "call/cc":
 			return c.compileCallCC(fs, argsArr)
+		case "delay":
+			return c.compileDelay(fs, "delay", argsArr, false)
+		case "delay-force":
+			return c.compileDelay(fs, "delay-force", argsArr, true)
+		case "stream-cons":
+			return c.compileStreamCons(fs, argsArr)
+		case "stream-lambda":
+			return c.compileStreamLambda(fs, argsArr)
+		case "define-stream":
+			return c.compileDefineStream(fs, argsArr)
+		case "define-record-type":
+			return c.compileDefineRecordType(fs, argsArr)
+		case "match":
+			return c.compileMatch(fs, argsArr, tail)
 		default: // (procedure-name args...)
 			return c.compileCall(fs, first, argsArr, tail)
 		}
@@ -555,7 +803,7 @@ func (c *Compiler) compilePair(fs *funcState, pair *types.Pair, tail bool) (*reg
 
 func (c *Compiler) compileTailObject(fs *funcState, obj types.Object) (*reg, error) {
 	switch o := obj.(type) {
-	case types.Number, types.Boolean, types.String, types.Vector:
+	case types.Number, types.Boolean, types.Char, *types.String, *types.Vector, *types.Bytevector:
 		return c.compileConst(fs, o), nil
 	case *types.Symbol:
 		return c.compileSymbol(fs, o), nil
@@ -568,7 +816,7 @@ func (c *Compiler) compileTailObject(fs *funcState, obj types.Object) (*reg, err
 
 func (c *Compiler) compileObject(fs *funcState, obj types.Object) (*reg, error) {
 	switch o := obj.(type) {
-	case types.Number, types.Boolean, types.String, types.Vector:
+	case types.Number, types.Boolean, types.Char, *types.String, *types.Vector, *types.Bytevector:
 		return c.compileConst(fs, o), nil
 	case *types.Symbol:
 		return c.compileSymbol(fs, o), nil
@@ -609,8 +857,10 @@ func (c *Compiler) compileObjects(fs *funcState, objs []types.Object) ([]*reg, e
 	return regs, nil
 }
 
-func Compile(global map[string]types.Object, objs []types.Object) (*types.Closure, error) {
-	c := Compiler{global: global}
+// Compile compiles objs into a closure. spans are the source positions of the lists
+// in objs, which may be nil.
+func Compile(global map[string]types.Object, objs []types.Object, spans types.SourceMap) (*types.Closure, error) {
+	c := Compiler{global: global, spans: spans}
 	fs := newFuncState(nil)
 	regs, err := c.compileObjects(fs, objs)
 	if err != nil {
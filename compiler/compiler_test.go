@@ -8,7 +8,7 @@ import (
 func TestCompileNumber(t *testing.T) {
 	num := types.Number(1)
 	objs := []types.Object{num}
-	cl, err := Compile(map[string]types.Object{}, objs)
+	cl, err := Compile(map[string]types.Object{}, objs, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -56,3 +56,80 @@ func TestNameStorage(t *testing.T) {
 		t.Fatalf("expected %d, but got %d", 1, ns.Len())
 	}
 }
+
+func TestCompileLiteral(t *testing.T) {
+	v1 := types.NewVector([]types.Object{types.NewString("a")})
+	v2 := types.NewVector([]types.Object{})
+	objs := []types.Object{v1, types.List(types.NewSymbol("quote"), types.List(v2))}
+	cl, err := Compile(map[string]types.Object{}, objs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cl.Proto.Consts) != 2 {
+		t.Fatalf("expected %d, but got %d", 2, len(cl.Proto.Consts))
+	}
+	if !v1.IsImmutable() || !v2.IsImmutable() {
+		t.Fatalf("expected immutable vectors")
+	}
+	if !v1.Ref(0).(*types.String).IsImmutable() {
+		t.Fatalf("expected immutable string")
+	}
+}
+
+func TestCompileMatch(t *testing.T) {
+	// (match x ((a 1) 'ok))
+	pattern := types.List(types.NewSymbol("a"), types.Number(1))
+	clause := types.List(pattern, types.List(types.NewSymbol("quote"), types.NewSymbol("ok")))
+	expr := types.List(types.NewSymbol("match"), types.NewSymbol("x"), clause)
+	cl, err := Compile(map[string]types.Object{}, []types.Object{expr}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pattern is compiled to the decision code without procedure calls
+	counts := map[int]int{}
+	for _, inst := range cl.Proto.Insts {
+		counts[GetOpCode(inst)]++
+	}
+	if counts[OP_CALL] != 1 || counts[OP_CLOSURE] != 1 {
+		t.Fatalf("expected only the call of the clause body, but got %d calls", counts[OP_CALL])
+	}
+	if counts[OP_MATCH] == 0 || counts[OP_EQUAL] != 1 || counts[OP_TEST] == 0 {
+		t.Fatalf("expected the decision code, but got %v", counts)
+	}
+}
+
+func TestCompileLineInfo(t *testing.T) {
+	inner := types.List(types.NewSymbol("car"), types.NewSymbol("x"))
+	outer := types.List(types.NewSymbol("display"), inner).(*types.Pair)
+	outerPos := types.Pos{File: "a.scm", Line: 1, Column: 1}
+	innerPos := types.Pos{File: "a.scm", Line: 2, Column: 3}
+	spans := types.SourceMap{
+		outer:               {Start: outerPos},
+		inner.(*types.Pair): {Start: innerPos},
+	}
+	cl, err := Compile(map[string]types.Object{}, []types.Object{outer}, spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto := cl.Proto
+	if len(proto.LineInfo) != len(proto.Insts) {
+		t.Fatalf("expected %d positions, but got %d", len(proto.Insts), len(proto.LineInfo))
+	}
+	for i, inst := range proto.Insts {
+		if GetOpCode(inst) == OP_GETGLOBAL && proto.Consts[GetArgBx(inst)].String() == "car" {
+			if proto.LineInfo[i] != innerPos {
+				t.Fatalf("expected %v, but got %v", innerPos, proto.LineInfo[i])
+			}
+		}
+	}
+	if last := proto.LineInfo[len(proto.LineInfo)-1]; last.IsValid() {
+		t.Fatalf("expected no position for the final return, but got %v", last)
+	}
+
+	bad := types.List(types.NewSymbol("if")).(*types.Pair)
+	_, err = Compile(map[string]types.Object{}, []types.Object{bad}, types.SourceMap{bad: {Start: innerPos}})
+	e, ok := err.(*types.Error)
+	if !ok || e.Position() != innerPos {
+		t.Fatalf("expected syntax error at %v, but got %v", innerPos, err)
+	}
+}
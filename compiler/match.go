@@ -0,0 +1,522 @@
+package compiler
+
+import (
+	"github.com/hyusuk/tama/types"
+)
+
+// This file compiles the pattern matching of Wright and Shinn into decision code.
+//
+// A pattern is compiled to the instructions which test the value with OP_MATCH,
+// OP_EQUAL etc. and jump to the next clause with OP_TEST and OP_JMP if the
+// value does not match. The body of a clause is compiled as a closure whose
+// arguments are the pattern variables, because local variables are only bound
+// by lambda.
+
+// patternBinding is a pattern variable and the register of its value.
+type patternBinding struct {
+	name string
+	r    *reg
+}
+
+// matchState is the state of compiling a pattern.
+type matchState struct {
+	fails []int // pcs of the jumps which must be taken when the value does not match
+	binds []patternBinding
+}
+
+func (m *matchState) lookup(name string) *reg {
+	for _, b := range m.binds {
+		if b.name == name {
+			return b.r
+		}
+	}
+	return nil
+}
+
+// failIfFalse emits the jump to the failure which is taken if R(r) is #f.
+func (m *matchState) failIfFalse(fs *funcState, r *reg) {
+	fs.addABC(OP_TEST, r.n, 0, 0)
+	m.fail(fs)
+}
+
+// fail emits the jump to the failure.
+func (m *matchState) fail(fs *funcState) {
+	m.fails = append(m.fails, fs.nextPc())
+	fs.addASbx(OP_JMP, 0, 0) // sbx will be set later
+}
+
+// patchJumps sets the destination of the jumps at pcs to target.
+func (fs *funcState) patchJumps(pcs []int, target int) {
+	for _, pc := range pcs {
+		fs.rewriteSbx(pc, target-pc-1)
+	}
+}
+
+func (fs *funcState) addMatch(op int, r *reg) *reg {
+	dst := fs.newReg()
+	fs.addABC(OP_MATCH, dst.n, r.n, op)
+	return dst
+}
+
+func isSymbol(obj types.Object, name string) bool {
+	sym, ok := obj.(*types.Symbol)
+	return ok && sym.Name == name
+}
+
+// patternForm returns the keyword and the elements of the pattern (keyword args...).
+func patternForm(pat *types.Pair) (string, []types.Object, error) {
+	elems, err := dottedSlice(pat)
+	if err != nil {
+		return "", nil, types.NewSyntaxError("match: invalid pattern %v", pat)
+	}
+	sym, ok := elems[0].(*types.Symbol)
+	if !ok {
+		return "", elems, nil
+	}
+	valid := true
+	switch sym.Name {
+	case "quote", "quasiquote", "not":
+		valid = len(elems) == 2
+	case "=":
+		valid = len(elems) == 3
+	case "?", "$":
+		valid = len(elems) >= 2
+	case "and", "or":
+	default:
+		return "", elems, nil
+	}
+	if !valid {
+		return "", nil, types.NewSyntaxError("match: invalid pattern %v", pat)
+	}
+	return sym.Name, elems[1:], nil
+}
+
+// quasiPattern converts the quasi-pattern qp to the pattern.
+// Datums are matched literally except (unquote pattern).
+func quasiPattern(qp types.Object) (types.Object, error) {
+	switch o := qp.(type) {
+	case *types.Symbol:
+		if o.Name == "..." || o.Name == "." {
+			return o, nil
+		}
+		return types.List(types.NewSymbol("quote"), o), nil
+	case *types.Vector:
+		elems := make([]types.Object, o.Len())
+		for i, elem := range o.Elems() {
+			pat, err := quasiPattern(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = pat
+		}
+		return types.NewVector(elems), nil
+	case *types.Pair:
+		elems, err := dottedSlice(o)
+		if err != nil {
+			return nil, types.NewSyntaxError("match: invalid pattern %v", o)
+		}
+		if isSymbol(elems[0], "unquote") && len(elems) == 2 {
+			return elems[1], nil
+		}
+		if isSymbol(elems[0], "unquote-splicing") {
+			return nil, types.NewSyntaxError("match: unquote-splicing is not supported in %v", o)
+		}
+		for i, elem := range elems {
+			if elems[i], err = quasiPattern(elem); err != nil {
+				return nil, err
+			}
+		}
+		return types.List(elems...), nil
+	}
+	return qp, nil
+}
+
+// patternVars returns the names of the pattern variables in pat in the order of appearance.
+func patternVars(pat types.Object) ([]string, error) {
+	vars := []string{}
+	var walk func(p types.Object) error
+	walk = func(p types.Object) error {
+		switch o := p.(type) {
+		case *types.Symbol:
+			if o.Name == "_" || o.Name == "..." || o.Name == "." {
+				return nil
+			}
+			for _, v := range vars {
+				if v == o.Name {
+					return nil
+				}
+			}
+			vars = append(vars, o.Name)
+		case *types.Vector:
+			for _, elem := range o.Elems() {
+				if err := walk(elem); err != nil {
+					return err
+				}
+			}
+		case *types.Pair:
+			keyword, args, err := patternForm(o)
+			if err != nil {
+				return err
+			}
+			switch keyword {
+			case "quote", "not":
+				return nil
+			case "quasiquote":
+				qp, err := quasiPattern(args[0])
+				if err != nil {
+					return err
+				}
+				return walk(qp)
+			case "?", "=", "$":
+				args = args[1:]
+			}
+			for _, arg := range args {
+				if err := walk(arg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(pat); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// compileMatch compiles match syntax.
+//
+// (match expression (pattern body ...) ...)
+// (match expression (pattern (guard test ...) body ...) ...)
+//
+// Patterns:
+//
+//	_                  matches anything
+//	variable           binds the value (the same variable must match equal? values)
+//	literal, 'datum    matches an equal? value
+//	(pat ...)          matches a list. pat followed by ... matches zero or more elements
+//	(pat ... . pat)    matches an improper list
+//	#(pat ...)         matches a vector
+//	($ type pat ...)   matches a record of the record type and its fields
+//	(? pred pat ...)   matches if (pred value) is true and all pats match
+//	(= proc pat)       matches if pat matches (proc value)
+//	(and pat ...), (or pat ...), (not pat)
+//	`qp                quasi-pattern, where ,pat is a pattern
+func (c *Compiler) compileMatch(fs *funcState, args []types.Object, tail bool) (*reg, error) {
+	if len(args) < 1 {
+		return nil, types.NewSyntaxError("match: invalid syntax")
+	}
+	valR, err := c.compileObject(fs, args[0])
+	if err != nil {
+		return nil, err
+	}
+	resultR := fs.newReg()
+	endJmpPcs := []int{}
+	for _, clause := range args[1:] {
+		pair, ok := clause.(*types.Pair)
+		if !ok || pair.Len() < 2 {
+			return nil, types.NewSyntaxError("match: invalid clause %v", clause)
+		}
+		elems, err := pair.Slice()
+		if err != nil {
+			return nil, types.NewSyntaxError("match: invalid clause %v", clause)
+		}
+		m := &matchState{}
+		if err := c.compilePattern(fs, elems[0], valR, m); err != nil {
+			return nil, err
+		}
+		body := elems[1:]
+		if guard, ok := body[0].(*types.Pair); ok && isSymbol(guard.Car(), "guard") && len(body) > 1 {
+			tests, err := guard.Slice()
+			if err != nil {
+				return nil, types.NewSyntaxError("match: invalid guard %v", guard)
+			}
+			for _, test := range tests[1:] {
+				testR, err := c.compileClauseBody(fs, m.binds, []types.Object{test}, false)
+				if err != nil {
+					return nil, err
+				}
+				m.failIfFalse(fs, testR)
+			}
+			body = body[1:]
+		}
+		bodyR, err := c.compileClauseBody(fs, m.binds, body, tail)
+		if err != nil {
+			return nil, err
+		}
+		fs.addABC(OP_MOVE, resultR.n, bodyR.n, 0)
+		endJmpPcs = append(endJmpPcs, fs.nextPc())
+		fs.addASbx(OP_JMP, 0, 0) // jump to the end. sbx will be set later
+		fs.patchJumps(m.fails, fs.nextPc())
+	}
+	fs.addABC(OP_MATCH, resultR.n, valR.n, MatchError)
+	fs.patchJumps(endJmpPcs, fs.nextPc())
+	return resultR, nil
+}
+
+// compileClauseBody compiles body where the pattern variables binds are bound.
+//
+// convert
+// body
+// =>
+// ((lambda (variable ...) body) value ...)
+func (c *Compiler) compileClauseBody(fs *funcState, binds []patternBinding, body []types.Object, tail bool) (*reg, error) {
+	if len(binds) == 0 {
+		var regs []*reg
+		var err error
+		if tail {
+			regs, err = c.compileTailObjects(fs, body)
+		} else {
+			regs, err = c.compileObjects(fs, body)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return regs[len(regs)-1], nil
+	}
+	formals := make([]types.Object, len(binds))
+	for i, b := range binds {
+		formals[i] = types.NewSymbol(b.name)
+	}
+	lambdaR, err := c.compileLambda(fs, append([]types.Object{types.List(formals...)}, body...))
+	if err != nil {
+		return nil, err
+	}
+	procR := fs.newReg()
+	fs.addABC(OP_MOVE, procR.n, lambdaR.n, 0)
+	for _, b := range binds {
+		argR := fs.newReg()
+		fs.addABC(OP_MOVE, argR.n, b.r.n, 0)
+	}
+	op := OP_CALL
+	if tail {
+		op = OP_TAILCALL
+	}
+	fs.addABC(op, procR.n, 1+len(binds), 2)
+	return procR, nil
+}
+
+// compileApplyReg emits the call of R(procR) with R(argR) and returns the register of the result.
+func (c *Compiler) compileApplyReg(fs *funcState, procR *reg, argR *reg) *reg {
+	r := fs.newReg()
+	fs.addABC(OP_MOVE, r.n, procR.n, 0)
+	a := fs.newReg()
+	fs.addABC(OP_MOVE, a.n, argR.n, 0)
+	fs.addABC(OP_CALL, r.n, 2, 2)
+	return r
+}
+
+// bindPattern binds the pattern variable name to R(r).
+func (c *Compiler) bindPattern(fs *funcState, m *matchState, name string, r *reg) {
+	if prev := m.lookup(name); prev != nil {
+		// a variable which appears twice matches only the equal values
+		eqR := fs.newReg()
+		fs.addABC(OP_EQUAL, eqR.n, prev.n, r.n)
+		m.failIfFalse(fs, eqR)
+		return
+	}
+	m.binds = append(m.binds, patternBinding{name: name, r: r})
+}
+
+// compilePattern emits the code which matches R(valR) against pat.
+func (c *Compiler) compilePattern(fs *funcState, pat types.Object, valR *reg, m *matchState) error {
+	switch p := pat.(type) {
+	case *types.Symbol:
+		switch p.Name {
+		case "_":
+		case "...", ".":
+			return types.NewSyntaxError("match: misplaced %s", p.Name)
+		default:
+			c.bindPattern(fs, m, p.Name, valR)
+		}
+		return nil
+	case *types.Nil:
+		m.failIfFalse(fs, fs.addMatch(MatchNull, valR))
+		return nil
+	case *types.Vector:
+		listR := fs.addMatch(MatchVector, valR)
+		m.failIfFalse(fs, listR)
+		return c.compileListPattern(fs, p.Elems(), types.NilObject, listR, m)
+	case *types.Pair:
+		keyword, args, err := patternForm(p)
+		if err != nil {
+			return err
+		}
+		switch keyword {
+		case "quote":
+			c.compileLiteralPattern(fs, args[0], valR, m)
+			return nil
+		case "quasiquote":
+			qp, err := quasiPattern(args[0])
+			if err != nil {
+				return err
+			}
+			return c.compilePattern(fs, qp, valR, m)
+		case "?":
+			predR, err := c.compileObject(fs, args[0])
+			if err != nil {
+				return err
+			}
+			m.failIfFalse(fs, c.compileApplyReg(fs, predR, valR))
+			return c.compileAndPattern(fs, args[1:], valR, m)
+		case "=":
+			procR, err := c.compileObject(fs, args[0])
+			if err != nil {
+				return err
+			}
+			return c.compilePattern(fs, args[1], c.compileApplyReg(fs, procR, valR), m)
+		case "and":
+			return c.compileAndPattern(fs, args, valR, m)
+		case "or":
+			return c.compileOrPattern(fs, p, args, valR, m)
+		case "not":
+			sub := &matchState{}
+			if err := c.compilePattern(fs, args[0], valR, sub); err != nil {
+				return err
+			}
+			m.fail(fs)
+			fs.patchJumps(sub.fails, fs.nextPc())
+			return nil
+		case "$":
+			typeR, err := c.compileObject(fs, args[0])
+			if err != nil {
+				return err
+			}
+			fieldsR := fs.newReg()
+			fs.addABC(OP_RECORDFIELDS, fieldsR.n, valR.n, typeR.n)
+			m.failIfFalse(fs, fieldsR)
+			// the fields which have no patterns are ignored
+			return c.compileListPattern(fs, args[1:], types.NewSymbol("_"), fieldsR, m)
+		}
+		elems, tail := args, types.Object(types.NilObject)
+		for i, elem := range elems {
+			if isSymbol(elem, ".") {
+				if i == 0 || i != len(elems)-2 {
+					return types.NewSyntaxError("match: invalid pattern %v", p)
+				}
+				elems, tail = elems[:i], elems[i+1]
+				break
+			}
+		}
+		return c.compileListPattern(fs, elems, tail, valR, m)
+	}
+	c.compileLiteralPattern(fs, pat, valR, m)
+	return nil
+}
+
+func (c *Compiler) compileLiteralPattern(fs *funcState, datum types.Object, valR *reg, m *matchState) {
+	constR := c.compileConst(fs, datum)
+	eqR := fs.newReg()
+	fs.addABC(OP_EQUAL, eqR.n, valR.n, constR.n)
+	m.failIfFalse(fs, eqR)
+}
+
+func (c *Compiler) compileAndPattern(fs *funcState, pats []types.Object, valR *reg, m *matchState) error {
+	for _, pat := range pats {
+		if err := c.compilePattern(fs, pat, valR, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileOrPattern compiles (or pat ...).
+// The variables which are not bound by the matched pattern are bound to #f.
+func (c *Compiler) compileOrPattern(fs *funcState, pat types.Object, alts []types.Object, valR *reg, m *matchState) error {
+	if len(alts) == 0 {
+		m.fail(fs)
+		return nil
+	}
+	vars, err := patternVars(pat)
+	if err != nil {
+		return err
+	}
+	shared := make([]*reg, len(vars))
+	for i := range vars {
+		shared[i] = fs.newReg()
+	}
+	endJmpPcs := []int{}
+	for i, alt := range alts {
+		sub := &matchState{}
+		if err := c.compilePattern(fs, alt, valR, sub); err != nil {
+			return err
+		}
+		for j, v := range vars {
+			r := sub.lookup(v)
+			if r == nil {
+				r = c.compileConst(fs, types.Boolean(false))
+			}
+			fs.addABC(OP_MOVE, shared[j].n, r.n, 0)
+		}
+		if i == len(alts)-1 {
+			m.fails = append(m.fails, sub.fails...)
+			break
+		}
+		endJmpPcs = append(endJmpPcs, fs.nextPc())
+		fs.addASbx(OP_JMP, 0, 0) // jump to the end. sbx will be set later
+		fs.patchJumps(sub.fails, fs.nextPc())
+	}
+	fs.patchJumps(endJmpPcs, fs.nextPc())
+	for i, v := range vars {
+		c.bindPattern(fs, m, v, shared[i])
+	}
+	return nil
+}
+
+// compileListPattern matches R(valR) against the list of the patterns elems whose last cdr is tail.
+func (c *Compiler) compileListPattern(fs *funcState, elems []types.Object, tail types.Object, valR *reg, m *matchState) error {
+	cur := valR
+	for i, elem := range elems {
+		if isSymbol(elem, "...") {
+			return types.NewSyntaxError("match: misplaced ...")
+		}
+		if i+1 < len(elems) && isSymbol(elems[i+1], "...") {
+			return c.compileEllipsisPattern(fs, elem, elems[i+2:], tail, cur, m)
+		}
+		m.failIfFalse(fs, fs.addMatch(MatchPair, cur))
+		if err := c.compilePattern(fs, elem, fs.addMatch(MatchCar, cur), m); err != nil {
+			return err
+		}
+		cur = fs.addMatch(MatchCdr, cur)
+	}
+	return c.compilePattern(fs, tail, cur, m)
+}
+
+// compileEllipsisPattern matches R(valR) against (pat ... rest ... . tail).
+// Each variable in pat is bound to the list of the values matched in the iterations.
+func (c *Compiler) compileEllipsisPattern(fs *funcState, pat types.Object, rest []types.Object, tail types.Object, valR *reg, m *matchState) error {
+	vars, err := patternVars(pat)
+	if err != nil {
+		return err
+	}
+	headR, restR := fs.newReg(), fs.newReg()
+	fs.addABC(OP_SPLIT, headR.n, valR.n, len(rest))
+	m.failIfFalse(fs, headR)
+	accs := make([]*reg, len(vars))
+	for i := range vars {
+		accs[i] = c.compileConst(fs, types.NilObject)
+	}
+
+	// loop over the elements in headR
+	loopPc := fs.nextPc()
+	fs.addABC(OP_TEST, fs.addMatch(MatchPair, headR).n, 0, 0)
+	exitJmpPc := fs.nextPc()
+	fs.addASbx(OP_JMP, 0, 0) // jump to the exit. sbx will be set later
+	inner := &matchState{}
+	if err := c.compilePattern(fs, pat, fs.addMatch(MatchCar, headR), inner); err != nil {
+		return err
+	}
+	m.fails = append(m.fails, inner.fails...)
+	for i, v := range vars {
+		fs.addABC(OP_CONS, accs[i].n, inner.lookup(v).n, accs[i].n)
+	}
+	fs.addABC(OP_MATCH, headR.n, headR.n, MatchCdr)
+	fs.addASbx(OP_JMP, 0, loopPc-fs.nextPc()-1)
+	fs.patchJumps([]int{exitJmpPc}, fs.nextPc())
+
+	for i, v := range vars {
+		fs.addABC(OP_MATCH, accs[i].n, accs[i].n, MatchReverse)
+		c.bindPattern(fs, m, v, accs[i])
+	}
+	return c.compileListPattern(fs, rest, tail, restR, m)
+}
@@ -25,6 +25,35 @@ const (
 	OP_TAILCALL
 	// CALLCC A    call the closure at register R(A) with the continuation at register R(A+1)
 	OP_CALLCC
+	// PROMISE A B C    R(A) := promise of the thunk R(B)
+	// The thunk returns a promise if C is 1. (delay-force)
+	OP_PROMISE
+	// STREAMCONS A B C    R(A) := stream of the promises R(B) and R(C)
+	OP_STREAMCONS
+	// MATCH A B C    R(A) := the result of the match operation C on R(B)
+	// The operations are listed below. (Match*)
+	OP_MATCH
+	// CONS A B C    R(A) := (R(B) . R(C))
+	OP_CONS
+	// EQUAL A B C    R(A) := (equal? R(B) R(C))
+	OP_EQUAL
+	// SPLIT A B C    R(A) := the list of the elements of R(B) except the last C elements
+	//                R(A+1) := the rest of R(B)
+	// R(A) := #f if R(B) has less than C elements.
+	OP_SPLIT
+	// RECORDFIELDS A B C    R(A) := the list of the fields of R(B) if it is a record of the type R(C), otherwise #f
+	OP_RECORDFIELDS
+)
+
+// Operations of OP_MATCH
+const (
+	MatchPair    int = iota // (pair? x)
+	MatchNull               // (null? x)
+	MatchCar                // (car x) of the pair x
+	MatchCdr                // (cdr x) of the pair x
+	MatchVector             // (vector->list x) if x is a vector, otherwise #f
+	MatchReverse            // (reverse x) of the list x
+	MatchError              // raises the error that no pattern matches x
 )
 
 type opType int
@@ -56,6 +85,13 @@ var opProps = []opProp{
 	opProp{"LOADUNDEF", opTypeABC},
 	opProp{"TAILCALL", opTypeABC},
 	opProp{"CALLCC", opTypeABC},
+	opProp{"PROMISE", opTypeABC},
+	opProp{"STREAMCONS", opTypeABC},
+	opProp{"MATCH", opTypeABC},
+	opProp{"CONS", opTypeABC},
+	opProp{"EQUAL", opTypeABC},
+	opProp{"SPLIT", opTypeABC},
+	opProp{"RECORDFIELDS", opTypeABC},
 }
 
 const (
@@ -0,0 +1,214 @@
+package tama
+
+import (
+	"errors"
+	"github.com/hyusuk/tama/types"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// WriteFS is a writable file system used together with Option.FS.
+// Names are slash-separated paths accepted by fs.ValidPath.
+type WriteFS interface {
+	// Create creates or truncates the named file.
+	Create(name string) (io.WriteCloser, error)
+	// Remove removes the named file.
+	Remove(name string) error
+}
+
+type rootWriteFS struct {
+	root *os.Root
+}
+
+// RootWriteFS returns a WriteFS which writes within root.
+// root.FS() gives the corresponding file system for Option.FS.
+func RootWriteFS(root *os.Root) WriteFS {
+	return &rootWriteFS{root: root}
+}
+
+func (r *rootWriteFS) Create(name string) (io.WriteCloser, error) {
+	return r.root.Create(name)
+}
+
+func (r *rootWriteFS) Remove(name string) error {
+	return r.root.Remove(name)
+}
+
+// OpenFile opens the file library. Files are read through Option.FS
+// and written through Option.WriteFS.
+func (s *State) OpenFile() *State {
+	s.RegisterFunc("open-input-file", 1, 1, genFnOpenInputFile(false))
+	s.RegisterFunc("open-binary-input-file", 1, 1, genFnOpenInputFile(true))
+	s.RegisterFunc("open-output-file", 1, 1, genFnOpenOutputFile(false))
+	s.RegisterFunc("open-binary-output-file", 1, 1, genFnOpenOutputFile(true))
+	s.RegisterFunc("call-with-input-file", 2, 2, fnCallWithInputFile)
+	s.RegisterFunc("call-with-output-file", 2, 2, fnCallWithOutputFile)
+	s.RegisterFunc("with-input-from-file", 2, 2, fnWithInputFromFile)
+	s.RegisterFunc("with-output-to-file", 2, 2, fnWithOutputToFile)
+	s.RegisterFunc("file-exists?", 1, 1, fnFileExists)
+	s.RegisterFunc("delete-file", 1, 1, fnDeleteFile)
+	return s
+}
+
+// toFileName converts obj to a name in the file system.
+// Names referring outside of the file system, such as absolute paths
+// or paths containing "..", are reported as file errors.
+func toFileName(obj types.Object) (string, error) {
+	if err := types.AssertType(types.TyString, obj); err != nil {
+		return "", err
+	}
+	str := obj.(*types.String).String()
+	name := path.Clean(str)
+	if !fs.ValidPath(name) {
+		return "", types.NewFileError("%q is outside of the file system", str)
+	}
+	return name, nil
+}
+
+func (s *State) openInputFile(obj types.Object, binary bool) (*types.Port, error) {
+	name, err := toFileName(obj)
+	if err != nil {
+		return nil, err
+	}
+	if s.fsys == nil {
+		return nil, types.NewFileError("reading files is not allowed")
+	}
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, types.NewFileError("%v", err)
+	}
+	return types.NewFileInputPort(f, binary), nil
+}
+
+func (s *State) openOutputFile(obj types.Object, binary bool) (*types.Port, error) {
+	name, err := toFileName(obj)
+	if err != nil {
+		return nil, err
+	}
+	if s.wfs == nil {
+		return nil, types.NewFileError("writing files is not allowed")
+	}
+	f, err := s.wfs.Create(name)
+	if err != nil {
+		return nil, types.NewFileError("%v", err)
+	}
+	return types.NewFileOutputPort(f, binary), nil
+}
+
+// genFnOpenInputFile generates (open-input-file name) and (open-binary-input-file name).
+func genFnOpenInputFile(binary bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		return s.openInputFile(args[0], binary)
+	}
+}
+
+// genFnOpenOutputFile generates (open-output-file name) and (open-binary-output-file name).
+func genFnOpenOutputFile(binary bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		return s.openOutputFile(args[0], binary)
+	}
+}
+
+// callWithPort calls fn with p and closes p afterwards, even on error.
+func (s *State) callWithPort(p *types.Port, fn types.Object) (types.Object, error) {
+	result, err := s.Call(fn, p)
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// (call-with-input-file name proc)
+func fnCallWithInputFile(s *State, args []types.Object) (types.Object, error) {
+	p, err := s.openInputFile(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	return s.callWithPort(p, args[1])
+}
+
+// (call-with-output-file name proc)
+func fnCallWithOutputFile(s *State, args []types.Object) (types.Object, error) {
+	p, err := s.openOutputFile(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	return s.callWithPort(p, args[1])
+}
+
+// (with-input-from-file name thunk)
+// thunk is called with the current input port bound to the file.
+func fnWithInputFromFile(s *State, args []types.Object) (types.Object, error) {
+	p, err := s.openInputFile(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	stdin := s.stdin
+	s.stdin = p
+	result, err := s.Call(args[1])
+	s.stdin = stdin
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// (with-output-to-file name thunk)
+// thunk is called with the current output port bound to the file.
+func fnWithOutputToFile(s *State, args []types.Object) (types.Object, error) {
+	p, err := s.openOutputFile(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	stdout := s.stdout
+	s.stdout = p
+	result, err := s.Call(args[1])
+	s.stdout = stdout
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func fnFileExists(s *State, args []types.Object) (types.Object, error) {
+	name, err := toFileName(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if s.fsys == nil {
+		return nil, types.NewFileError("reading files is not allowed")
+	}
+	_, err = fs.Stat(s.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return types.Boolean(false), nil
+	}
+	if err != nil {
+		return nil, types.NewFileError("%v", err)
+	}
+	return types.Boolean(true), nil
+}
+
+func fnDeleteFile(s *State, args []types.Object) (types.Object, error) {
+	name, err := toFileName(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if s.wfs == nil {
+		return nil, types.NewFileError("writing files is not allowed")
+	}
+	if err := s.wfs.Remove(name); err != nil {
+		return nil, types.NewFileError("%v", err)
+	}
+	return types.UndefinedObject, nil
+}
@@ -0,0 +1,115 @@
+package tama
+
+import (
+	"errors"
+	"github.com/hyusuk/tama/types"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileRead(t *testing.T) {
+	option := Option{FS: fstest.MapFS{
+		"a.txt":     &fstest.MapFile{Data: []byte("hello\nworld\n")},
+		"dir/b.bin": &fstest.MapFile{Data: []byte{1, 2, 3}},
+	}}
+	tcases := []*tcase{
+		&tcase{src: `(read-line (open-input-file "a.txt"))`, expect: "hello", option: option},
+		&tcase{src: `(define p (open-input-file "./dir/../a.txt")) (read-line p) (read-line p)`, expect: "world", option: option},
+		&tcase{src: `(= (read-u8 (open-binary-input-file "dir/b.bin")) 1)`, expect: "#t", option: option},
+		&tcase{src: `(call-with-input-file "a.txt" (lambda (p) (read-string 3 p)))`, expect: "hel", option: option},
+		&tcase{src: `(define p #f) (call-with-input-file "a.txt" (lambda (x) (set! p x))) (input-port-open? p)`, expect: "#f", option: option},
+		&tcase{src: `(with-input-from-file "a.txt" (lambda () (read-char) (read-char)))`, expect: "e", option: option},
+		&tcase{src: `(with-input-from-file "a.txt" (lambda () 1)) (eq? (current-input-port) (current-input-port))`, expect: "#t", option: option},
+		&tcase{src: `(file-exists? "a.txt")`, expect: "#t", option: option},
+		&tcase{src: `(file-exists? "dir")`, expect: "#t", option: option},
+		&tcase{src: `(file-exists? "c.txt")`, expect: "#f", option: option},
+		&tcase{src: `(open-input-file "c.txt")`, expectErr: true, option: option},
+		&tcase{src: `(open-input-file 1)`, expectErr: true, option: option},
+		&tcase{src: `(open-output-file "c.txt")`, expectErr: true, option: option},
+		&tcase{src: `(open-input-file "a.txt")`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenFile)
+}
+
+func TestFileWrite(t *testing.T) {
+	root, err := os.OpenRoot(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	option := Option{FS: root.FS(), WriteFS: RootWriteFS(root)}
+	tcases := []*tcase{
+		&tcase{src: `(define p (open-output-file "a.txt")) (write-string "abc" p) (close-port p) (call-with-input-file "a.txt" read-line)`, expect: "abc", option: option},
+		&tcase{src: `(call-with-output-file "a.txt" (lambda (p) (display "x" p) (newline p) 1))`, expect: "1", option: option},
+		&tcase{src: `(with-output-to-file "a.txt" (lambda () (display "out"))) (call-with-input-file "a.txt" read-line)`, expect: "out", option: option},
+		&tcase{src: `(define p (open-binary-output-file "b.bin")) (write-u8 7 p) (close-port p) (= (read-u8 (open-binary-input-file "b.bin")) 7)`, expect: "#t", option: option},
+		&tcase{src: `(with-output-to-file "c.txt" (lambda () 1)) (delete-file "c.txt") (file-exists? "c.txt")`, expect: "#f", option: option},
+		&tcase{src: `(delete-file "c.txt")`, expectErr: true, option: option},
+		&tcase{src: `(delete-file "a.txt")`, expectErr: true, option: Option{FS: root.FS()}},
+	}
+	testTcases(t, tcases, (*State).OpenFile)
+}
+
+func TestFileOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "root"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(dir, "root", "link")); err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.OpenRoot(filepath.Join(dir, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	srcs := []string{
+		`(open-input-file "../secret")`,
+		`(open-input-file "/etc/passwd")`,
+		`(open-input-file "link")`,
+		`(file-exists? "../secret")`,
+		`(open-output-file "../out")`,
+		`(with-output-to-file "../out" (lambda () 1))`,
+		`(delete-file "../secret")`,
+	}
+	for _, src := range srcs {
+		s := NewState(Option{FS: root.FS(), WriteFS: RootWriteFS(root)}).OpenFile()
+		err := s.ExecString(src)
+		var e *types.Error
+		if !errors.As(err, &e) || e.ErrorType() != types.ErrFile {
+			t.Fatalf("expected file error, but got %v\nsrc: %s", err, src)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret")); err != nil {
+		t.Fatalf("secret is removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); err == nil {
+		t.Fatalf("out is created outside of the root")
+	}
+}
+
+func TestFnIsFileError(t *testing.T) {
+	s := NewState(Option{})
+	pred, _ := s.GetGlobal("file-error?")
+	for _, tc := range []struct {
+		obj    types.Object
+		expect bool
+	}{
+		{types.NewFileError("x"), true},
+		{types.NewTypeError("x"), false},
+		{types.Number(1), false},
+	} {
+		v, err := s.Call(pred, tc.obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != types.Boolean(tc.expect) {
+			t.Fatalf("(file-error? %v): expected %v, but got %v", tc.obj, tc.expect, v)
+		}
+	}
+}
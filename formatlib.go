@@ -0,0 +1,171 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/printer"
+	"github.com/hyusuk/tama/types"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// OpenFormat registers format. (SRFI-28, SRFI-48)
+func (s *State) OpenFormat() *State {
+	s.RegisterFunc("format", 2, -1, fnFormat)
+	return s
+}
+
+// (format dest fmt arg ...)
+// dest is #f to return the formatted string, #t to write it to the current output port,
+// or an output port.
+func fnFormat(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[1]); err != nil {
+		return nil, err
+	}
+	var port *types.Port
+	switch dest := args[0].(type) {
+	case types.Boolean:
+		if dest {
+			port = s.stdout
+		}
+	case *types.Port:
+		port = dest
+	default:
+		return nil, types.NewTypeError("boolean or output port required for the destination, but got %v", args[0])
+	}
+	out, err := formatString(args[1].(*types.String).String(), args[2:])
+	if err != nil {
+		return nil, err
+	}
+	if port == nil {
+		return types.NewString(out), nil
+	}
+	if err := port.WriteString(out); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// formatString formats args according to the directives in format.
+// The positions in error messages are the indices of characters, starting at 0.
+func formatString(format string, args []types.Object) (string, error) {
+	var b strings.Builder
+	runes := []rune(format)
+	next := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '~' {
+			b.WriteRune(runes[i])
+			continue
+		}
+		pos := i
+		// parameters: ~width,digitsF
+		params := []int{}
+		for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == ','); i++ {
+			if runes[i] == ',' {
+				if len(params) == 0 {
+					params = append(params, -1)
+				}
+				params = append(params, -1)
+				continue
+			}
+			if len(params) == 0 {
+				params = append(params, -1)
+			}
+			n := &params[len(params)-1]
+			if *n < 0 {
+				*n = 0
+			}
+			*n = *n*10 + int(runes[i]-'0')
+		}
+		if i >= len(runes) {
+			return "", types.NewInternalError("incomplete directive at position %d", pos)
+		}
+		directive := unicode.ToLower(runes[i])
+		if len(params) > 0 && directive != 'f' {
+			return "", types.NewInternalError("~%c does not take parameters at position %d", runes[i], pos)
+		}
+		if len(params) > 2 {
+			return "", types.NewInternalError("too many parameters for ~%c at position %d", runes[i], pos)
+		}
+		switch directive {
+		case '%', 'n':
+			b.WriteByte('\n')
+			continue
+		case '~':
+			b.WriteByte('~')
+			continue
+		case 'a', 's', 'w', 'd', 'x', 'b', 'o', 'f':
+		default:
+			return "", types.NewInternalError("unknown directive ~%c at position %d", runes[i], pos)
+		}
+		if next >= len(args) {
+			return "", types.NewInternalError("missing argument for ~%c at position %d", runes[i], pos)
+		}
+		arg := args[next]
+		next++
+		switch directive {
+		case 'a':
+			b.WriteString(printer.DisplayString(arg))
+		case 's', 'w':
+			b.WriteString(printer.WriteString(arg))
+		case 'd', 'x', 'b', 'o':
+			str, err := formatInteger(arg, directive)
+			if err != nil {
+				return "", types.NewInternalError("%v for ~%c at position %d", err, runes[i], pos)
+			}
+			b.WriteString(str)
+		case 'f':
+			width, digits := -1, -1
+			if len(params) > 0 {
+				width = params[0]
+			}
+			if len(params) > 1 {
+				digits = params[1]
+			}
+			b.WriteString(formatFixed(arg, width, digits))
+		}
+	}
+	if next < len(args) {
+		return "", types.NewInternalError("too many arguments: %d directives for %d arguments", next, len(args))
+	}
+	return b.String(), nil
+}
+
+// formatInteger formats the number obj in the radix of the directive.
+// ~d also accepts numbers which are not integers.
+func formatInteger(obj types.Object, directive rune) (string, error) {
+	num, ok := obj.(types.Number)
+	if !ok {
+		return "", types.NewTypeError("number required, but got %v", obj)
+	}
+	if directive == 'd' && !isInteger(float64(num)) {
+		return num.String(), nil
+	}
+	if !isInteger(float64(num)) {
+		return "", types.NewTypeError("integer required, but got %v", obj)
+	}
+	base := map[rune]int{'d': 10, 'x': 16, 'b': 2, 'o': 8}[directive]
+	if math.Abs(float64(num)) >= 1<<63 {
+		// too large for int64
+		n, _ := big.NewFloat(float64(num)).Int(nil)
+		return n.Text(base), nil
+	}
+	return strconv.FormatInt(int64(num), base), nil
+}
+
+// formatFixed formats obj right-justified in width characters.
+// Numbers are formatted with digits digits after the decimal point.
+// Negative width or digits means that it is not specified.
+func formatFixed(obj types.Object, width int, digits int) string {
+	var str string
+	if num, ok := obj.(types.Number); ok && digits >= 0 {
+		str = strconv.FormatFloat(float64(num), 'f', digits, 64)
+	} else {
+		str = obj.String()
+	}
+	if pad := width - len([]rune(str)); pad > 0 {
+		str = strings.Repeat(" ", pad) + str
+	}
+	return str
+}
@@ -0,0 +1,56 @@
+package tama
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFnFormat(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(format #f "Hello, ~a!" "world")`, expect: "Hello, world!"},
+		&tcase{src: `(format #f "~s ~s" "ab" #\a)`, expect: `"ab" #\a`},
+		&tcase{src: `(format #f "~w" #\space)`, expect: `#\space`},
+		&tcase{src: `(format #f "~d ~x ~b ~o" 10 255 5 8)`, expect: "10 ff 101 10"},
+		&tcase{src: `(format #f "~X" -255)`, expect: "-ff"},
+		&tcase{src: `(format #f "~d" 1.5)`, expect: "1.5"},
+		&tcase{src: `(format #f "~d ~x" 1e20 -1e20)`, expect: "100000000000000000000 -56bc75e2d63100000"},
+		&tcase{src: `(format #f "~b" 9223372036854775808)`, expect: "1" + strings.Repeat("0", 63)},
+		&tcase{src: `(format #f "[~8,2f]" 3.14159)`, expect: "[    3.14]"},
+		&tcase{src: `(format #f "~,3F" 2)`, expect: "2.000"},
+		&tcase{src: `(format #f "~5f" "ab")`, expect: "   ab"},
+		&tcase{src: `(format #f "100~~~%")`, expect: "100~\n"},
+		&tcase{src: `(format #f "~a")`, expectErr: true},
+		&tcase{src: `(format #f "~a" 1 2)`, expectErr: true},
+		&tcase{src: `(format #f "~q" 1)`, expectErr: true},
+		&tcase{src: `(format #f "~x" 1.5)`, expectErr: true},
+		&tcase{src: `(format #f "~" 1)`, expectErr: true},
+		&tcase{src: `(format 1 "~a" 1)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenFormat)
+}
+
+func TestFnFormatError(t *testing.T) {
+	s := NewState(Option{}).OpenFormat()
+	err := s.ExecString(`(format #f "ab~zc" 1)`)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), "~z at position 2") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFnFormatStdout(t *testing.T) {
+	var out bytes.Buffer
+	s := NewState(Option{Stdout: &out}).OpenFormat()
+	if err := s.ExecString(`(format #t "~a-~a~%" 1 2) (format (current-output-port) "~s" "x")`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1-2\n\"x\"" {
+		t.Fatalf("expected %q, but got %q", "1-2\n\"x\"", out.String())
+	}
+	if err := s.ExecString(`(format (current-input-port) "~a" 1)`); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,511 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"iter"
+	"math"
+)
+
+// OpenGenerator registers the generator and accumulator library. (SRFI-158)
+//
+// A generator is a procedure without arguments which returns the next value each time
+// it is called, and the end of file object when it is exhausted.
+// An accumulator is a procedure with one argument which accumulates the values passed to it,
+// and returns the accumulated result when it is called with the end of file object.
+func (s *State) OpenGenerator() *State {
+	s.RegisterFunc("generator", 0, -1, fnGenerator)
+	s.RegisterFunc("make-iota-generator", 1, 3, fnMakeIotaGenerator)
+	s.RegisterFunc("make-range-generator", 1, 3, fnMakeRangeGenerator)
+	s.RegisterFunc("list->generator", 1, 1, fnListToGenerator)
+	s.RegisterFunc("vector->generator", 1, 3, fnVecToGenerator)
+	s.RegisterFunc("string->generator", 1, 3, fnStrToGenerator)
+	s.RegisterFunc("gappend", 0, -1, fnGappend)
+	s.RegisterFunc("gmap", 2, -1, fnGmap)
+	s.RegisterFunc("gfilter", 2, 2, genFnGfilter(true))
+	s.RegisterFunc("gremove", 2, 2, genFnGfilter(false))
+	s.RegisterFunc("gtake", 2, 3, fnGtake)
+	s.RegisterFunc("gdrop", 2, 2, fnGdrop)
+	s.RegisterFunc("generator->list", 1, 2, fnGeneratorToList)
+	s.RegisterFunc("generator->reverse-list", 1, 2, fnGeneratorToReverseList)
+	s.RegisterFunc("generator->vector", 1, 2, fnGeneratorToVec)
+	s.RegisterFunc("generator-fold", 3, -1, fnGeneratorFold)
+	s.RegisterFunc("generator-for-each", 2, -1, fnGeneratorForEach)
+	s.RegisterFunc("make-accumulator", 3, 3, fnMakeAccumulator)
+	s.RegisterFunc("count-accumulator", 0, 0, fnCountAccumulator)
+	s.RegisterFunc("list-accumulator", 0, 0, fnListAccumulator)
+	s.RegisterFunc("reverse-list-accumulator", 0, 0, fnReverseListAccumulator)
+	s.RegisterFunc("vector-accumulator", 0, 0, fnVecAccumulator)
+	s.RegisterFunc("string-accumulator", 0, 0, fnStrAccumulator)
+	s.RegisterFunc("sum-accumulator", 0, 0, genFnNumAccumulator(0, func(a, b types.Number) types.Number { return a + b }))
+	s.RegisterFunc("product-accumulator", 0, 0, genFnNumAccumulator(1, func(a, b types.Number) types.Number { return a * b }))
+	return s
+}
+
+// newGenerator creates a generator which returns the values returned by next.
+// Once next returns the end of file object, it is never called again.
+func newGenerator(next func(s *State) (types.Object, error)) *types.Closure {
+	done := false
+	var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+		if done {
+			return types.EOFObject, nil
+		}
+		v, err := next(s)
+		if err != nil {
+			return nil, err
+		}
+		if v == types.EOFObject {
+			done = true
+		}
+		return v, nil
+	}
+	return types.NewGoClosure("generator", 0, 0, fn)
+}
+
+// NewGenerator creates a generator which returns the values of seq.
+// seq is pulled lazily, one value per call of the generator.
+// stop releases seq. It must be called unless the generator is exhausted.
+func NewGenerator(seq iter.Seq[types.Object]) (gen *types.Closure, stop func()) {
+	next, stop := iter.Pull(seq)
+	gen = newGenerator(func(s *State) (types.Object, error) {
+		v, ok := next()
+		if !ok {
+			return types.EOFObject, nil
+		}
+		return v, nil
+	})
+	return gen, stop
+}
+
+// newAccumulator creates an accumulator.
+// add is called with each value, and result is called with the end of file object.
+func newAccumulator(add func(s *State, v types.Object) error, result func(s *State) (types.Object, error)) *types.Closure {
+	var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+		if args[0] == types.EOFObject {
+			return result(s)
+		}
+		if err := add(s, args[0]); err != nil {
+			return nil, err
+		}
+		return types.UndefinedObject, nil
+	}
+	return types.NewGoClosure("accumulator", 1, 1, fn)
+}
+
+// (generator arg ...)
+func fnGenerator(s *State, args []types.Object) (types.Object, error) {
+	objs := make([]types.Object, len(args))
+	copy(objs, args)
+	return newSliceGenerator(objs), nil
+}
+
+func newSliceGenerator(objs []types.Object) *types.Closure {
+	i := 0
+	return newGenerator(func(s *State) (types.Object, error) {
+		if i >= len(objs) {
+			return types.EOFObject, nil
+		}
+		i++
+		return objs[i-1], nil
+	})
+}
+
+// (make-iota-generator count [start [step]])
+func fnMakeIotaGenerator(s *State, args []types.Object) (types.Object, error) {
+	count, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyNumber, args[1:]...); err != nil {
+		return nil, err
+	}
+	start, step := types.Number(0), types.Number(1)
+	if len(args) > 1 {
+		start = args[1].(types.Number)
+	}
+	if len(args) > 2 {
+		step = args[2].(types.Number)
+	}
+	i := 0
+	return newGenerator(func(s *State) (types.Object, error) {
+		if i >= count {
+			return types.EOFObject, nil
+		}
+		i++
+		return start + types.Number(i-1)*step, nil
+	}), nil
+}
+
+// (make-range-generator start [end [step]])
+// Without end, the generator is infinite.
+func fnMakeRangeGenerator(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyNumber, args...); err != nil {
+		return nil, err
+	}
+	start, end, step := args[0].(types.Number), types.Number(math.Inf(1)), types.Number(1)
+	if len(args) > 1 {
+		end = args[1].(types.Number)
+	}
+	if len(args) > 2 {
+		step = args[2].(types.Number)
+	}
+	i := 0
+	return newGenerator(func(s *State) (types.Object, error) {
+		v := start + types.Number(i)*step
+		if (step >= 0 && v >= end) || (step < 0 && v <= end) {
+			return types.EOFObject, nil
+		}
+		i++
+		return v, nil
+	}), nil
+}
+
+// (list->generator list)
+func fnListToGenerator(s *State, args []types.Object) (types.Object, error) {
+	list := args[0]
+	return newGenerator(func(s *State) (types.Object, error) {
+		pair, ok := list.(*types.Pair)
+		if !ok {
+			if !types.IsNull(list) {
+				return nil, types.NewTypeError("list required, but got %v", args[0])
+			}
+			return types.EOFObject, nil
+		}
+		list = pair.Cdr()
+		return pair.Car(), nil
+	}), nil
+}
+
+// (vector->generator vector [start [end]])
+func fnVecToGenerator(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[0]); err != nil {
+		return nil, err
+	}
+	vec := args[0].(*types.Vector)
+	start, end, err := toRange(args[1:], vec.Len())
+	if err != nil {
+		return nil, err
+	}
+	return newGenerator(func(s *State) (types.Object, error) {
+		if start >= end || start >= vec.Len() {
+			return types.EOFObject, nil
+		}
+		start++
+		return vec.Ref(start - 1), nil
+	}), nil
+}
+
+// (string->generator string [start [end]])
+func fnStrToGenerator(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[1:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	return newGenerator(func(s *State) (types.Object, error) {
+		if start >= end || start >= str.Len() {
+			return types.EOFObject, nil
+		}
+		start++
+		return str.Ref(start - 1), nil
+	}), nil
+}
+
+// (gappend gen ...)
+func fnGappend(s *State, args []types.Object) (types.Object, error) {
+	gens := make([]types.Object, len(args))
+	copy(gens, args)
+	return newGenerator(func(s *State) (types.Object, error) {
+		for len(gens) > 0 {
+			v, err := s.Call(gens[0])
+			if err != nil {
+				return nil, err
+			}
+			if v != types.EOFObject {
+				return v, nil
+			}
+			gens = gens[1:]
+		}
+		return types.EOFObject, nil
+	}), nil
+}
+
+// nextValues calls each generator of gens and returns their values.
+// It returns nil if any of them is exhausted.
+func (s *State) nextValues(gens []types.Object) ([]types.Object, error) {
+	vals := make([]types.Object, len(gens))
+	for i, gen := range gens {
+		v, err := s.Call(gen)
+		if err != nil {
+			return nil, err
+		}
+		if v == types.EOFObject {
+			return nil, nil
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// (gmap proc gen1 gen2 ...)
+// The generator is exhausted when any of the generators is exhausted.
+func fnGmap(s *State, args []types.Object) (types.Object, error) {
+	proc := args[0]
+	gens := make([]types.Object, len(args)-1)
+	copy(gens, args[1:])
+	return newGenerator(func(s *State) (types.Object, error) {
+		vals, err := s.nextValues(gens)
+		if err != nil || vals == nil {
+			return types.EOFObject, err
+		}
+		return s.Call(proc, vals...)
+	}), nil
+}
+
+// genFnGfilter generates (gfilter pred gen) and (gremove pred gen).
+func genFnGfilter(keep bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		pred, gen := args[0], args[1]
+		return newGenerator(func(s *State) (types.Object, error) {
+			for {
+				v, err := s.Call(gen)
+				if err != nil || v == types.EOFObject {
+					return v, err
+				}
+				ok, err := s.Call(pred, v)
+				if err != nil {
+					return nil, err
+				}
+				if types.IsTruthy(ok) == keep {
+					return v, nil
+				}
+			}
+		}), nil
+	}
+}
+
+// (gtake gen k [padding])
+// If gen is exhausted before k values, padding is returned for the rest if it is given.
+func fnGtake(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[1], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	gen := args[0]
+	var padding types.Object = types.EOFObject
+	if len(args) > 2 {
+		padding = args[2]
+	}
+	i := 0
+	return newGenerator(func(s *State) (types.Object, error) {
+		if i >= k {
+			return types.EOFObject, nil
+		}
+		i++
+		v, err := s.Call(gen)
+		if err != nil {
+			return nil, err
+		}
+		if v == types.EOFObject {
+			return padding, nil
+		}
+		return v, nil
+	}), nil
+}
+
+// (gdrop gen k)
+func fnGdrop(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[1], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	gen := args[0]
+	return newGenerator(func(s *State) (types.Object, error) {
+		for ; k > 0; k-- {
+			v, err := s.Call(gen)
+			if err != nil || v == types.EOFObject {
+				return v, err
+			}
+		}
+		return s.Call(gen)
+	}), nil
+}
+
+// takeValues returns the values of gen.
+// The optional argument is the maximum number of values.
+func (s *State) takeValues(gen types.Object, args []types.Object) ([]types.Object, error) {
+	n := math.MaxInt32
+	if len(args) > 0 {
+		var err error
+		if n, err = toIndex(args[0], math.MaxInt32); err != nil {
+			return nil, err
+		}
+	}
+	vals := []types.Object{}
+	for len(vals) < n {
+		v, err := s.Call(gen)
+		if err != nil {
+			return nil, err
+		}
+		if v == types.EOFObject {
+			break
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// (generator->list gen [n])
+func fnGeneratorToList(s *State, args []types.Object) (types.Object, error) {
+	vals, err := s.takeValues(args[0], args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return types.List(vals...), nil
+}
+
+// (generator->reverse-list gen [n])
+func fnGeneratorToReverseList(s *State, args []types.Object) (types.Object, error) {
+	vals, err := s.takeValues(args[0], args[1:])
+	if err != nil {
+		return nil, err
+	}
+	var list types.Object = types.NilObject
+	for _, v := range vals {
+		list = types.Cons(v, list)
+	}
+	return list, nil
+}
+
+// (generator->vector gen [n])
+func fnGeneratorToVec(s *State, args []types.Object) (types.Object, error) {
+	vals, err := s.takeValues(args[0], args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(vals), nil
+}
+
+// (generator-fold proc seed gen1 gen2 ...)
+// proc is called as (proc v1 v2 ... acc).
+func fnGeneratorFold(s *State, args []types.Object) (types.Object, error) {
+	acc := args[1]
+	for {
+		vals, err := s.nextValues(args[2:])
+		if err != nil {
+			return nil, err
+		}
+		if vals == nil {
+			return acc, nil
+		}
+		if acc, err = s.Call(args[0], append(vals, acc)...); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// (generator-for-each proc gen1 gen2 ...)
+func fnGeneratorForEach(s *State, args []types.Object) (types.Object, error) {
+	for {
+		vals, err := s.nextValues(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		if vals == nil {
+			return types.UndefinedObject, nil
+		}
+		if _, err := s.Call(args[0], vals...); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// (make-accumulator kons knil finalizer)
+// kons is called as (kons v state), and finalizer as (finalizer state).
+func fnMakeAccumulator(s *State, args []types.Object) (types.Object, error) {
+	kons, state, finalizer := args[0], args[1], args[2]
+	return newAccumulator(func(s *State, v types.Object) error {
+		next, err := s.Call(kons, v, state)
+		if err != nil {
+			return err
+		}
+		state = next
+		return nil
+	}, func(s *State) (types.Object, error) {
+		return s.Call(finalizer, state)
+	}), nil
+}
+
+func fnCountAccumulator(s *State, args []types.Object) (types.Object, error) {
+	count := 0
+	return newAccumulator(func(s *State, v types.Object) error {
+		count++
+		return nil
+	}, func(s *State) (types.Object, error) {
+		return types.Number(count), nil
+	}), nil
+}
+
+func fnListAccumulator(s *State, args []types.Object) (types.Object, error) {
+	vals := []types.Object{}
+	return newAccumulator(func(s *State, v types.Object) error {
+		vals = append(vals, v)
+		return nil
+	}, func(s *State) (types.Object, error) {
+		return types.List(vals...), nil
+	}), nil
+}
+
+func fnReverseListAccumulator(s *State, args []types.Object) (types.Object, error) {
+	var list types.Object = types.NilObject
+	return newAccumulator(func(s *State, v types.Object) error {
+		list = types.Cons(v, list)
+		return nil
+	}, func(s *State) (types.Object, error) {
+		return list, nil
+	}), nil
+}
+
+func fnVecAccumulator(s *State, args []types.Object) (types.Object, error) {
+	vals := []types.Object{}
+	return newAccumulator(func(s *State, v types.Object) error {
+		vals = append(vals, v)
+		return nil
+	}, func(s *State) (types.Object, error) {
+		elems := make([]types.Object, len(vals))
+		copy(elems, vals)
+		return types.NewVector(elems), nil
+	}), nil
+}
+
+// string-accumulator accumulates characters.
+func fnStrAccumulator(s *State, args []types.Object) (types.Object, error) {
+	runes := []rune{}
+	return newAccumulator(func(s *State, v types.Object) error {
+		if err := types.AssertType(types.TyChar, v); err != nil {
+			return err
+		}
+		runes = append(runes, rune(v.(types.Char)))
+		return nil
+	}, func(s *State) (types.Object, error) {
+		return types.NewStringFromRunes(append([]rune(nil), runes...)), nil
+	}), nil
+}
+
+// genFnNumAccumulator generates sum-accumulator and product-accumulator.
+func genFnNumAccumulator(init types.Number, op func(a, b types.Number) types.Number) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		acc := init
+		return newAccumulator(func(s *State, v types.Object) error {
+			if err := types.AssertType(types.TyNumber, v); err != nil {
+				return err
+			}
+			acc = op(acc, v.(types.Number))
+			return nil
+		}, func(s *State) (types.Object, error) {
+			return acc, nil
+		}), nil
+	}
+}
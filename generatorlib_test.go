@@ -0,0 +1,100 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"testing"
+)
+
+func TestFnGenerator(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (generator->list (generator 1 2 3)) (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(define g (generator 1)) (g) (eof-object? (g))", expect: "#t"},
+		&tcase{src: "(define g (generator)) (g) (eof-object? (g))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (make-iota-generator 3 1 2)) (list 1 3 5))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (make-range-generator 0 2 0.5)) (list 0 0.5 1 1.5))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (make-range-generator 3) 2) (list 3 4))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (list->generator (list 1 2))) (list 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (generator->vector (vector->generator #(1 2 3) 1)) #(2 3))", expect: "#t"},
+		&tcase{src: "(equal? (generator->reverse-list (string->generator \"abc\")) (list #\\c #\\b #\\a))", expect: "#t"},
+		&tcase{src: "(generator->list (list->generator (cons 1 2)))", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenGenerator)
+}
+
+func TestFnGeneratorOperations(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (generator->list (gmap + (generator 1 2 3) (generator 10 20))) (list 11 22))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gfilter (lambda (x) (< x 2)) (generator 1 2 0))) (list 1 0))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gremove (lambda (x) (< x 2)) (generator 1 2 0))) (list 2))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gtake (make-range-generator 0) 3)) (list 0 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gtake (generator 1) 3 0)) (list 1 0 0))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gdrop (generator 1 2 3) 2)) (list 3))", expect: "#t"},
+		&tcase{src: "(equal? (generator->list (gappend (generator 1) (generator) (generator 2))) (list 1 2))", expect: "#t"},
+		&tcase{src: "(generator-fold + 0 (make-iota-generator 5))", expect: "10"},
+		&tcase{src: "(define n 0) (generator-for-each (lambda (x y) (set! n (+ n (* x y)))) (generator 1 2) (generator 3 4)) n", expect: "11"},
+		&tcase{src: "(generator->list (gmap car (generator 1)))", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenGenerator)
+}
+
+func TestFnGeneratorLarge(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(= (generator-fold + 0 (gfilter (lambda (x) (< x 10)) (gmap (lambda (x) (* x 2)) (make-iota-generator 1000000)))) 20)", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenGenerator)
+}
+
+func TestFnAccumulator(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define a (count-accumulator)) (a 1) (a 2) (a (eof-object))", expect: "2"},
+		&tcase{src: "(define a (list-accumulator)) (a 1) (a 2) (equal? (a (eof-object)) (list 1 2))", expect: "#t"},
+		&tcase{src: "(define a (reverse-list-accumulator)) (a 1) (a 2) (equal? (a (eof-object)) (list 2 1))", expect: "#t"},
+		&tcase{src: "(define a (vector-accumulator)) (a 1) (a 2) (equal? (a (eof-object)) #(1 2))", expect: "#t"},
+		&tcase{src: "(define a (string-accumulator)) (a #\\a) (a #\\b) (a (eof-object))", expect: "ab"},
+		&tcase{src: "(define a (string-accumulator)) (a 1)", expectErr: true},
+		&tcase{src: "(define a (sum-accumulator)) (a 1) (a 2) (a (eof-object))", expect: "3"},
+		&tcase{src: "(define a (product-accumulator)) (a 2) (a 3) (a (eof-object))", expect: "6"},
+		&tcase{src: "(define a (make-accumulator + 0 (lambda (x) (* x 10)))) (a 1) (a 2) (a (eof-object))", expect: "30"},
+	}
+	testTcases(t, tcases, (*State).OpenGenerator)
+}
+
+func TestNewGenerator(t *testing.T) {
+	s := NewState(Option{}).OpenGenerator()
+	seq := func(yield func(types.Object) bool) {
+		for i := 0; ; i++ {
+			if !yield(types.Number(i)) {
+				return
+			}
+		}
+	}
+	gen, stop := NewGenerator(seq)
+	defer stop()
+	s.SetGlobal("naturals", gen)
+	if err := s.ExecString("(generator-fold + 0 (gtake naturals 5))"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.CallStack.Top(); v.String() != "10" {
+		t.Fatalf("expected %s, but got %s", "10", v.String())
+	}
+	v, err := s.Call(gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "5" {
+		t.Fatalf("expected %s, but got %s", "5", v.String())
+	}
+
+	gen, _ = NewGenerator(func(yield func(types.Object) bool) {
+		yield(types.Number(1))
+	})
+	for _, expect := range []string{"1", "#<eof>", "#<eof>"} {
+		v, err := s.Call(gen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != expect {
+			t.Fatalf("expected %s, but got %s", expect, v.String())
+		}
+	}
+}
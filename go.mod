@@ -1 +1,3 @@
 module github.com/hyusuk/tama
+
+go 1.24
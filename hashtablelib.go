@@ -0,0 +1,391 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+)
+
+// OpenHashTable registers the hash table library. (SRFI-69 and SRFI-125)
+func (s *State) OpenHashTable() *State {
+	s.RegisterFunc("make-hash-table", 0, 2, fnMakeHashTable)
+	s.RegisterFunc("alist->hash-table", 1, 3, fnAlistToHashTable)
+	s.RegisterFunc("hash-table?", 1, 1, fnIsHashTable)
+	s.RegisterFunc("hash-table-ref", 2, 4, fnHashTableRef)
+	s.RegisterFunc("hash-table-ref/default", 3, 3, fnHashTableRefDefault)
+	s.RegisterFunc("hash-table-set!", 1, -1, fnHashTableSet)
+	s.RegisterFunc("hash-table-delete!", 1, -1, fnHashTableDelete)
+	s.RegisterFunc("hash-table-contains?", 2, 2, fnHashTableContains)
+	s.RegisterFunc("hash-table-exists?", 2, 2, fnHashTableContains)
+	s.RegisterFunc("hash-table-update!", 3, 5, fnHashTableUpdate)
+	s.RegisterFunc("hash-table-update!/default", 4, 4, fnHashTableUpdateDefault)
+	s.RegisterFunc("hash-table-size", 1, 1, fnHashTableSize)
+	s.RegisterFunc("hash-table-keys", 1, 1, fnHashTableKeys)
+	s.RegisterFunc("hash-table-values", 1, 1, fnHashTableValues)
+	s.RegisterFunc("hash-table-walk", 2, 2, fnHashTableWalk)
+	s.RegisterFunc("hash-table->alist", 1, 1, fnHashTableToAlist)
+	s.RegisterFunc("hash-table-copy", 1, 2, fnHashTableCopy)
+	s.RegisterFunc("hash-table-clear!", 1, 1, fnHashTableClear)
+	s.RegisterFunc("hash", 1, 2, genFnHash(types.EqualHash))
+	s.RegisterFunc("string-hash", 1, 2, genFnHash(types.EqualHash))
+	s.RegisterFunc("string-ci-hash", 1, 2, genFnHash(stringCIHash))
+	s.RegisterFunc("hash-by-identity", 1, 2, genFnHash(types.EqvHash))
+	return s
+}
+
+func stringCIHash(obj types.Object) uint64 {
+	return types.EqualHash(types.NewString(foldString(obj.String())))
+}
+
+// hashTableFuncs returns the functions of a hash table whose keys are compared by equiv.
+// If equiv is one of the builtin equivalence predicates, the keys are compared and hashed
+// without calling scheme procedures. If hash is nil, a hash function consistent with
+// equal? is used for unknown predicates.
+func (s *State) hashTableFuncs(equiv, hash types.Object) (types.EquivFunc, types.HashFunc, error) {
+	var equivFn types.EquivFunc
+	var hashFn types.HashFunc
+	cl, ok := equiv.(*types.Closure)
+	if !ok {
+		return nil, nil, types.NewTypeError("procedure required, but got %v", equiv)
+	}
+	name := ""
+	if cl.IsGo {
+		name = cl.FnName
+	}
+	switch name {
+	case "eq?", "eqv?", "=":
+		equivFn = func(a, b types.Object) (bool, error) { return types.Eqv(a, b), nil }
+		hashFn = func(key types.Object) (uint64, error) { return types.EqvHash(key), nil }
+	case "equal?", "string=?":
+		equivFn = func(a, b types.Object) (bool, error) { return types.Equal(a, b), nil }
+		hashFn = func(key types.Object) (uint64, error) { return types.EqualHash(key), nil }
+	default:
+		equivFn = func(a, b types.Object) (bool, error) {
+			v, err := s.Call(equiv, a, b)
+			if err != nil {
+				return false, err
+			}
+			return types.IsTruthy(v), nil
+		}
+		hashFn = func(key types.Object) (uint64, error) { return types.EqualHash(key), nil }
+		if name == "string-ci=?" {
+			hashFn = func(key types.Object) (uint64, error) { return stringCIHash(key), nil }
+		}
+	}
+	if hash != nil {
+		hashFn = func(key types.Object) (uint64, error) {
+			v, err := s.Call(hash, key)
+			if err != nil {
+				return 0, err
+			}
+			n, ok := v.(types.Number)
+			if !ok || n < 0 || n != types.Number(uint64(n)) {
+				return 0, types.NewTypeError("hash function must return a non-negative integer, but got %v", v)
+			}
+			return uint64(n), nil
+		}
+	}
+	return equivFn, hashFn, nil
+}
+
+// (make-hash-table [equiv [hash]])
+// equiv defaults to equal?.
+func fnMakeHashTable(s *State, args []types.Object) (types.Object, error) {
+	if len(args) == 0 {
+		return types.NewEqualHashTable(), nil
+	}
+	var hash types.Object
+	if len(args) > 1 {
+		hash = args[1]
+	}
+	equivFn, hashFn, err := s.hashTableFuncs(args[0], hash)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewHashTable(equivFn, hashFn), nil
+}
+
+// (alist->hash-table alist [equiv [hash]])
+// If a key appears more than once, the first association takes precedence.
+func fnAlistToHashTable(s *State, args []types.Object) (types.Object, error) {
+	alist, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	obj, err := fnMakeHashTable(s, args[1:])
+	if err != nil {
+		return nil, err
+	}
+	h := obj.(*types.HashTable)
+	for _, assoc := range alist {
+		pair, ok := assoc.(*types.Pair)
+		if !ok {
+			return nil, types.NewTypeError("pair required, but got %v", assoc)
+		}
+		_, found, err := h.Get(pair.Car())
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			continue
+		}
+		if err := h.Set(pair.Car(), pair.Cdr()); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func fnIsHashTable(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyHashTable), nil
+}
+
+func toHashTable(obj types.Object) (*types.HashTable, error) {
+	if err := types.AssertType(types.TyHashTable, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.HashTable), nil
+}
+
+// hashTableRef looks up key in h. If key is found, success is called with the value.
+// Otherwise failure is called with no arguments. failure and success can be nil.
+func (s *State) hashTableRef(h *types.HashTable, key, failure, success types.Object) (types.Object, error) {
+	v, found, err := h.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if failure == nil {
+			return nil, types.NewInternalError("key not found: %v", key)
+		}
+		return s.Call(failure)
+	}
+	if success != nil {
+		return s.Call(success, v)
+	}
+	return v, nil
+}
+
+// (hash-table-ref hash-table key [failure [success]])
+func fnHashTableRef(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var failure, success types.Object
+	if len(args) > 2 {
+		failure = args[2]
+	}
+	if len(args) > 3 {
+		success = args[3]
+	}
+	return s.hashTableRef(h, args[1], failure, success)
+}
+
+func fnHashTableRefDefault(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v, found, err := h.Get(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return args[2], nil
+	}
+	return v, nil
+}
+
+// (hash-table-set! hash-table key1 value1 key2 value2 ...)
+func fnHashTableSet(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	kvs := args[1:]
+	if len(kvs)%2 != 0 {
+		return nil, types.NewInternalError("value for key %v is missing", kvs[len(kvs)-1])
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		if err := h.Set(kvs[i], kvs[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return types.UndefinedObject, nil
+}
+
+// (hash-table-delete! hash-table key1 key2 ...)
+// Returns the number of deleted entries.
+func fnHashTableDelete(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, key := range args[1:] {
+		deleted, err := h.Delete(key)
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			n++
+		}
+	}
+	return types.Number(n), nil
+}
+
+func fnHashTableContains(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	_, found, err := h.Get(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean(found), nil
+}
+
+// (hash-table-update! hash-table key updater [failure [success]])
+func fnHashTableUpdate(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var failure, success types.Object
+	if len(args) > 3 {
+		failure = args[3]
+	}
+	if len(args) > 4 {
+		success = args[4]
+	}
+	v, err := s.hashTableRef(h, args[1], failure, success)
+	if err != nil {
+		return nil, err
+	}
+	newV, err := s.Call(args[2], v)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Set(args[1], newV); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (hash-table-update!/default hash-table key updater default)
+func fnHashTableUpdateDefault(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v, found, err := h.Get(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		v = args[3]
+	}
+	newV, err := s.Call(args[2], v)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Set(args[1], newV); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnHashTableSize(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(h.Len()), nil
+}
+
+func fnHashTableKeys(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.List(h.Keys()...), nil
+}
+
+func fnHashTableValues(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	values := make([]types.Object, 0, h.Len())
+	h.Range(func(key, value types.Object) bool {
+		values = append(values, value)
+		return true
+	})
+	return types.List(values...), nil
+}
+
+// (hash-table-walk hash-table proc)
+// proc is called with each key and value.
+func fnHashTableWalk(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	h.Range(func(key, value types.Object) bool {
+		_, err = s.Call(args[1], key, value)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+func fnHashTableToAlist(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	alist := make([]types.Object, 0, h.Len())
+	h.Range(func(key, value types.Object) bool {
+		alist = append(alist, types.Cons(key, value))
+		return true
+	})
+	return types.List(alist...), nil
+}
+
+func fnHashTableCopy(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return h.Copy(), nil
+}
+
+func fnHashTableClear(s *State, args []types.Object) (types.Object, error) {
+	h, err := toHashTable(args[0])
+	if err != nil {
+		return nil, err
+	}
+	h.Clear()
+	return types.UndefinedObject, nil
+}
+
+// genFnHash generates (hash obj [bound]).
+// The result is in the range [0, bound). bound defaults to 2^32.
+func genFnHash(hash func(types.Object) uint64) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		bound := uint64(1 << 32)
+		if len(args) > 1 {
+			if err := types.AssertType(types.TyNumber, args[1]); err != nil {
+				return nil, err
+			}
+			n := args[1].(types.Number)
+			if n < 1 || n != types.Number(uint64(n)) {
+				return nil, types.NewTypeError("positive integer required, but got %v", n)
+			}
+			bound = uint64(n)
+		}
+		return types.Number(hash(args[0]) % bound), nil
+	}
+}
@@ -0,0 +1,88 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"testing"
+)
+
+func TestFnHashTable(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(hash-table? (make-hash-table))", expect: "#t"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h '(1 \"a\") 'x) (hash-table-ref h (cons 1 (cons \"a\" (quote ()))))", expect: "x"},
+		&tcase{src: "(define h (make-hash-table eq?)) (hash-table-set! h 'a 1 'b 2) (hash-table-ref h 'b)", expect: "2"},
+		&tcase{src: "(define h (make-hash-table eqv?)) (hash-table-set! h \"a\" 1) (hash-table-ref/default h \"a\" 'none)", expect: "none"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 1.0 'one) (hash-table-ref h 1)", expect: "one"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h #(1 #\\a) 'v) (hash-table-ref h (vector 1 #\\a))", expect: "v"},
+		&tcase{src: "(hash-table-ref (make-hash-table) 'missing)", expectErr: true},
+		&tcase{src: "(hash-table-ref (make-hash-table) 'missing (lambda () 'failed))", expect: "failed"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-ref h 'a (lambda () 0) (lambda (v) (+ v 10)))", expect: "11"},
+		&tcase{src: "(hash-table-set! (make-hash-table) 'a)", expectErr: true},
+		&tcase{src: "(hash-table-ref 'a 'a)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenHashTable)
+}
+
+func TestFnHashTableCustom(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define h (make-hash-table (lambda (a b) (= (car a) (car b))) (lambda (k) (car k)))) (hash-table-set! h '(1 a) 'x) (hash-table-ref h '(1 b))", expect: "x"},
+		&tcase{src: "(define h (make-hash-table string-ci=?)) (hash-table-set! h \"Key\" 1) (hash-table-ref h \"KEY\")", expect: "1"},
+		&tcase{src: "(define h (make-hash-table equal? (lambda (k) -1))) (hash-table-set! h 'a 1)", expectErr: true},
+		&tcase{src: "(define h (make-hash-table (lambda (a b) (car a)))) (hash-table-set! h 1 1) (hash-table-set! h 1 2)", expectErr: true},
+		&tcase{src: "(make-hash-table 1)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenHashTable, (*State).OpenString)
+}
+
+func TestFnHashTableUpdate(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-update! h 'a (lambda (v) (+ v 1))) (hash-table-ref h 'a)", expect: "2"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-update! h 'a (lambda (v) (+ v 1)) (lambda () 10)) (hash-table-ref h 'a)", expect: "11"},
+		&tcase{src: "(hash-table-update! (make-hash-table) 'a (lambda (v) v))", expectErr: true},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-update!/default h 'a (lambda (v) (+ v 1)) 0) (hash-table-update!/default h 'a (lambda (v) (+ v 1)) 0) (hash-table-ref h 'a)", expect: "2"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (hash-table-delete! h 'a 'c)", expect: "1"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (hash-table-delete! h 'a) (hash-table-contains? h 'a)", expect: "#f"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (hash-table-clear! h) (hash-table-size h)", expect: "0"},
+	}
+	testTcases(t, tcases, (*State).OpenHashTable)
+}
+
+func TestFnHashTableIteration(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2 'c 3) (hash-table-delete! h 'b) (car (cdr (hash-table-keys h)))", expect: "c"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (car (cdr (hash-table-values h)))", expect: "2"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (cdr (car (hash-table->alist h)))", expect: "1"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1 'b 2) (define n 0) (hash-table-walk h (lambda (k v) (set! n (+ n v)))) n", expect: "3"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1) (hash-table-walk h (lambda (k) k))", expectErr: true},
+		&tcase{src: "(define h (alist->hash-table (cons (cons 'a 1) (cons (cons 'a 2) '())))) (hash-table-ref h 'a)", expect: "1"},
+		&tcase{src: "(define h (make-hash-table)) (hash-table-set! h 'a 1) (define c (hash-table-copy h)) (hash-table-set! c 'a 2) (hash-table-ref h 'a)", expect: "1"},
+	}
+	testTcases(t, tcases, (*State).OpenHashTable)
+}
+
+func TestFnHash(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(= (hash (cons 1 \"a\")) (hash (cons 1 \"a\")))", expect: "#t"},
+		&tcase{src: "(< (hash 'abc 10) 10)", expect: "#t"},
+		&tcase{src: "(= (string-ci-hash \"ABC\") (string-ci-hash \"abc\"))", expect: "#t"},
+		&tcase{src: "(= (string-ci-hash \"Straße\") (string-ci-hash \"STRASSE\"))", expect: "#t"},
+		&tcase{src: "(hash 'a 0)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenHashTable)
+}
+
+func TestHashTableGo(t *testing.T) {
+	s := NewState(Option{}).OpenHashTable()
+	h := types.NewEqualHashTable()
+	h.Set(types.NewString("answer"), types.Number(42))
+	s.SetGlobal("table", h)
+	if err := s.ExecString(`(hash-table-set! table 'from-scheme (hash-table-ref table "answer"))`); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, _ := h.Get(types.NewSymbol("from-scheme"))
+	if !ok {
+		t.Fatalf("expected the key to exist")
+	}
+	if v.String() != "42" {
+		t.Fatalf("expected %s, but got %s", "42", v.String())
+	}
+}
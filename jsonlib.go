@@ -0,0 +1,425 @@
+package tama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/hyusuk/tama/types"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OpenJSON registers the JSON library.
+//
+// JSON values are converted to scheme objects as follows:
+//
+//	object         alist with symbol keys, or hash table with the 'hash-table mode
+//	array          vector
+//	string         string
+//	number         number (integers must be in [-2^53, 2^53])
+//	true, false    #t, #f
+//	null           json-null
+//
+// When converting back, object keys can be symbols or strings, and '() is an empty object.
+func (s *State) OpenJSON() *State {
+	s.SetGlobal("json-null", types.JSONNullObject)
+	s.RegisterFunc("json-null?", 1, 1, fnIsJSONNull)
+	s.RegisterFunc("json-read", 0, 2, fnJSONRead)
+	s.RegisterFunc("json-write", 1, 2, fnJSONWrite)
+	s.RegisterFunc("json->scheme", 1, 2, fnJSONToScheme)
+	s.RegisterFunc("scheme->json", 1, 1, fnSchemeToJSON)
+	return s
+}
+
+func fnIsJSONNull(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0] == types.JSONNullObject), nil
+}
+
+// toJSONObjectMode converts the optional mode argument at args[i].
+// It reports whether JSON objects are converted to hash tables.
+func toJSONObjectMode(args []types.Object, i int) (bool, error) {
+	if len(args) <= i {
+		return false, nil
+	}
+	if sym, ok := args[i].(*types.Symbol); ok {
+		switch sym.Name {
+		case "alist":
+			return false, nil
+		case "hash-table":
+			return true, nil
+		}
+	}
+	return false, types.NewTypeError("'alist or 'hash-table required, but got %v", args[i])
+}
+
+// (json-read [port [mode]])
+// mode is 'alist (default) or 'hash-table. It returns the eof object at the end.
+func fnJSONRead(s *State, args []types.Object) (types.Object, error) {
+	port, err := optionalPort(args, 0, s.stdin)
+	if err != nil {
+		return nil, err
+	}
+	hashTable, err := toJSONObjectMode(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	d := newJSONDecoder(port, hashTable)
+	v, err := d.decode()
+	if err == io.EOF {
+		return types.EOFObject, nil
+	}
+	return v, err
+}
+
+// (json->scheme string [mode])
+func fnJSONToScheme(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	hashTable, err := toJSONObjectMode(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	port := types.NewInputPort(strings.NewReader(args[0].(*types.String).String()), false)
+	d := newJSONDecoder(port, hashTable)
+	v, err := d.decode()
+	if err == io.EOF {
+		return nil, d.error("unexpected end of JSON input")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, d.error("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// (json-write obj [port])
+func fnJSONWrite(s *State, args []types.Object) (types.Object, error) {
+	port, err := optionalPort(args, 1, s.stdout)
+	if err != nil {
+		return nil, err
+	}
+	e := &jsonEncoder{p: port, path: map[types.Object]bool{}}
+	if err := e.encode(args[0]); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (scheme->json obj)
+func fnSchemeToJSON(s *State, args []types.Object) (types.Object, error) {
+	port := types.NewOutputStringPort()
+	e := &jsonEncoder{p: port, path: map[types.Object]bool{}}
+	if err := e.encode(args[0]); err != nil {
+		return nil, err
+	}
+	str, _ := port.OutputString()
+	return types.NewString(str), nil
+}
+
+// jsonPortReader feeds json.Decoder with one character at a time,
+// so that the decoder does not consume the port beyond the value.
+type jsonPortReader struct {
+	p *types.Port
+}
+
+func (r *jsonPortReader) Read(b []byte) (int, error) {
+	ch, err := r.p.ReadChar()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < utf8.RuneLen(ch) {
+		r.p.UnreadChar()
+		return 0, io.ErrShortBuffer
+	}
+	return utf8.EncodeRune(b, ch), nil
+}
+
+type jsonDecoder struct {
+	p         *types.Port
+	dec       *json.Decoder
+	hashTable bool // convert objects to hash tables instead of alists
+}
+
+func newJSONDecoder(p *types.Port, hashTable bool) *jsonDecoder {
+	dec := json.NewDecoder(&jsonPortReader{p: p})
+	dec.UseNumber()
+	return &jsonDecoder{p: p, dec: dec, hashTable: hashTable}
+}
+
+func (d *jsonDecoder) error(format string, v ...interface{}) error {
+	line, column := d.p.Position()
+	return types.NewReadError(types.Pos{Line: line, Column: column}, "json: "+format, v...)
+}
+
+// decode reads a value. It returns io.EOF if the port has no more values.
+func (d *jsonDecoder) decode() (types.Object, error) {
+	tok, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.value(tok)
+	if err != nil {
+		return nil, err
+	}
+	// a number at the top level is terminated by reading the next character
+	if n, _ := d.dec.Buffered().Read(make([]byte, 1)); n > 0 {
+		d.p.UnreadChar()
+	}
+	return v, nil
+}
+
+// token reads the next token, converting the errors of the decoder.
+func (d *jsonDecoder) token() (json.Token, error) {
+	tok, err := d.dec.Token()
+	if err == nil || err == io.EOF {
+		return tok, err
+	}
+	var e *types.Error
+	if errors.As(err, &e) {
+		return nil, e
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, d.error("unexpected end of JSON input")
+	}
+	return nil, d.error("%v", err)
+}
+
+func (d *jsonDecoder) value(tok json.Token) (types.Object, error) {
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			return d.array()
+		}
+		return d.object()
+	case string:
+		return types.NewString(t), nil
+	case json.Number:
+		return d.number(t)
+	case bool:
+		return types.Boolean(t), nil
+	case nil:
+		return types.JSONNullObject, nil
+	}
+	return nil, types.NewInternalError("unknown JSON token %v", tok)
+}
+
+// number converts num to a number. Integers written without a fraction or an exponent
+// must be exact, so their magnitude must not be greater than 2^53.
+func (d *jsonDecoder) number(num json.Number) (types.Object, error) {
+	if !strings.ContainsAny(string(num), ".eE") {
+		i, err := strconv.ParseInt(string(num), 10, 64)
+		if err != nil || i > 1<<53 || i < -(1<<53) {
+			return nil, d.error("integer %s cannot be represented exactly", num)
+		}
+		return types.Number(i), nil
+	}
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil {
+		return nil, d.error("number %s is out of range", num)
+	}
+	return types.Number(f), nil
+}
+
+// array reads the elements after '['.
+func (d *jsonDecoder) array() (types.Object, error) {
+	elems := []types.Object{}
+	for {
+		tok, err := d.element()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim(']') {
+			return types.NewVector(elems), nil
+		}
+		v, err := d.value(tok)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+	}
+}
+
+// object reads the members after '{'.
+func (d *jsonDecoder) object() (types.Object, error) {
+	var keys, values []types.Object
+	for {
+		tok, err := d.element()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim('}') {
+			break
+		}
+		// the decoder guarantees that keys are strings
+		keys = append(keys, types.NewSymbol(tok.(string)))
+		if tok, err = d.element(); err != nil {
+			return nil, err
+		}
+		v, err := d.value(tok)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	if d.hashTable {
+		ht := types.NewEqualHashTable()
+		for i, key := range keys {
+			if err := ht.Set(key, values[i]); err != nil {
+				return nil, err
+			}
+		}
+		return ht, nil
+	}
+	alist := make([]types.Object, len(keys))
+	for i, key := range keys {
+		alist[i] = types.Cons(key, values[i])
+	}
+	return types.List(alist...), nil
+}
+
+// element reads a token inside an array or an object, where the end of input is an error.
+func (d *jsonDecoder) element() (json.Token, error) {
+	tok, err := d.token()
+	if err == io.EOF {
+		return nil, d.error("unexpected end of JSON input")
+	}
+	return tok, err
+}
+
+// jsonEncoder writes scheme objects as JSON to a port piece by piece.
+type jsonEncoder struct {
+	p    *types.Port
+	path map[types.Object]bool // containers enclosing the current object
+}
+
+func (e *jsonEncoder) encode(obj types.Object) error {
+	switch o := obj.(type) {
+	case *types.JSONNull:
+		return e.p.WriteString("null")
+	case types.Boolean:
+		if o {
+			return e.p.WriteString("true")
+		}
+		return e.p.WriteString("false")
+	case types.Number:
+		f := float64(o)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return types.NewTypeError("%v cannot be converted to JSON", obj)
+		}
+		if math.Abs(f) > 1<<53 {
+			// written with an exponent so that it is not read as an inexact integer
+			return e.p.WriteString(strconv.FormatFloat(f, 'e', -1, 64))
+		}
+		b, _ := json.Marshal(f)
+		return e.p.WriteString(string(b))
+	case *types.String:
+		return e.p.WriteString(jsonQuote(o.String()))
+	case *types.Vector:
+		return e.container(obj, func() error {
+			if err := e.p.WriteString("["); err != nil {
+				return err
+			}
+			for i, elem := range o.Elems() {
+				if i > 0 {
+					if err := e.p.WriteString(","); err != nil {
+						return err
+					}
+				}
+				if err := e.encode(elem); err != nil {
+					return err
+				}
+			}
+			return e.p.WriteString("]")
+		})
+	case *types.Nil:
+		return e.p.WriteString("{}")
+	case *types.Pair:
+		return e.container(obj, func() error {
+			alist, err := toSlice(o)
+			if err != nil {
+				return err
+			}
+			return e.object(func(member func(key, value types.Object) error) error {
+				for _, elem := range alist {
+					pair, ok := elem.(*types.Pair)
+					if !ok {
+						return types.NewTypeError("pair required in alist, but got %v", elem)
+					}
+					if err := member(pair.Car(), pair.Cdr()); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+	case *types.HashTable:
+		return e.container(obj, func() error {
+			return e.object(func(member func(key, value types.Object) error) error {
+				var err error
+				o.Range(func(key, value types.Object) bool {
+					err = member(key, value)
+					return err == nil
+				})
+				return err
+			})
+		})
+	}
+	return types.NewTypeError("%v cannot be converted to JSON", obj)
+}
+
+// container encodes obj by fn, reporting circular structures.
+func (e *jsonEncoder) container(obj types.Object, fn func() error) error {
+	if e.path[obj] {
+		return types.NewTypeError("circular structure cannot be converted to JSON")
+	}
+	e.path[obj] = true
+	defer delete(e.path, obj)
+	return fn()
+}
+
+// object writes a JSON object whose members are given by calling member in each.
+func (e *jsonEncoder) object(each func(member func(key, value types.Object) error) error) error {
+	if err := e.p.WriteString("{"); err != nil {
+		return err
+	}
+	first := true
+	err := each(func(key, value types.Object) error {
+		var name string
+		switch k := key.(type) {
+		case *types.Symbol:
+			name = k.Name
+		case *types.String:
+			name = k.String()
+		default:
+			return types.NewTypeError("symbol or string key required, but got %v", key)
+		}
+		sep := ","
+		if first {
+			sep = ""
+			first = false
+		}
+		if err := e.p.WriteString(sep + jsonQuote(name) + ":"); err != nil {
+			return err
+		}
+		return e.encode(value)
+	})
+	if err != nil {
+		return err
+	}
+	return e.p.WriteString("}")
+}
+
+// jsonQuote returns the JSON string literal of str without escaping HTML characters.
+func jsonQuote(str string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	return strings.TrimSuffix(b.String(), "\n")
+}
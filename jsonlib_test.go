@@ -0,0 +1,80 @@
+package tama
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONToScheme(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(json->scheme "{\"a\": 1, \"b\": [true, false, null]}")`, expect: "((a . 1) (b . #(#t #f #<json-null>)))"},
+		&tcase{src: `(json->scheme " \"x\\ny\" ")`, expect: "x\ny"},
+		&tcase{src: `(json->scheme "{}")`, expect: "()"},
+		&tcase{src: `(json->scheme "[]")`, expect: "#()"},
+		&tcase{src: `(= (json->scheme "1.0") 1)`, expect: "#t"},
+		&tcase{src: `(= (json->scheme "-2.5e1") -25)`, expect: "#t"},
+		&tcase{src: `(= (json->scheme "9007199254740992") 9007199254740992)`, expect: "#t"},
+		&tcase{src: `(= (json->scheme "-9007199254740992") -9007199254740992)`, expect: "#t"},
+		&tcase{src: `(json->scheme "9007199254740993")`, expectErr: true},
+		&tcase{src: `(json->scheme "-99999999999999999999")`, expectErr: true},
+		&tcase{src: `(json-null? (json->scheme "null"))`, expect: "#t"},
+		&tcase{src: `(json-null? '())`, expect: "#f"},
+		&tcase{src: `(define h (json->scheme "{\"a\": {\"b\": 2}}" 'hash-table)) (hash-table-ref (hash-table-ref h 'a) 'b)`, expect: "2"},
+		&tcase{src: `(json->scheme "[1, 2")`, expectErr: true},
+		&tcase{src: `(json->scheme "")`, expectErr: true},
+		&tcase{src: `(json->scheme "1 2")`, expectErr: true},
+		&tcase{src: `(json->scheme "{\"a\" 1}")`, expectErr: true},
+		&tcase{src: `(json->scheme "1e400")`, expectErr: true},
+		&tcase{src: `(json->scheme "{}" 'vector)`, expectErr: true},
+		&tcase{src: `(json->scheme "[1,]")`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenJSON, (*State).OpenHashTable)
+}
+
+func TestJSONRead(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define p (open-input-string "[1] {\"a\": \"b\"}")) (json-read p) (json-read p)`, expect: "((a . b))"},
+		&tcase{src: `(define p (open-input-string "12)")) (json-read p) (read-char p)`, expect: ")"},
+		&tcase{src: `(define p (open-input-string "[1] x")) (json-read p) (read p)`, expect: "x"},
+		&tcase{src: `(eof-object? (json-read (open-input-string "  ")))`, expect: "#t"},
+		&tcase{src: `(vector-ref (json-read (open-input-string "[\"é\"]")) 0)`, expect: "é"},
+		&tcase{src: `(json-read (open-input-string "[1,"))`, expectErr: true},
+		&tcase{src: `(json-read (open-input-string "x"))`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenJSON)
+}
+
+func TestSchemeToJSON(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(scheme->json '((a . 1) ("b" . #(#t #f 1.5))))`, expect: `{"a":1,"b":[true,false,1.5]}`},
+		&tcase{src: `(scheme->json (vector json-null "<a>\n" '()))`, expect: `[null,"<a>\n",{}]`},
+		&tcase{src: `(scheme->json 100000000000000000000)`, expect: "1e+20"},
+		&tcase{src: `(scheme->json 9007199254740992)`, expect: "9007199254740992"},
+		&tcase{src: `(= (json->scheme (scheme->json 100000000000000000000)) 100000000000000000000)`, expect: "#t"},
+		&tcase{src: `(define h (make-hash-table)) (hash-table-set! h 'x 1) (hash-table-set! h 'y #()) (scheme->json h)`, expect: `{"x":1,"y":[]}`},
+		&tcase{src: `(scheme->json (json->scheme "{\"a\": [1, {\"b\": null}]}"))`, expect: `{"a":[1,{"b":null}]}`},
+		&tcase{src: `(scheme->json 'a)`, expectErr: true},
+		&tcase{src: `(scheme->json '(1 2))`, expectErr: true},
+		&tcase{src: `(scheme->json '((1 . 2)))`, expectErr: true},
+		&tcase{src: `(scheme->json (sqrt -1))`, expectErr: true},
+		&tcase{src: `(define v (vector 1)) (vector-set! v 0 v) (scheme->json v)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenJSON, (*State).OpenHashTable, (*State).OpenMath)
+}
+
+func TestJSONWrite(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define p (open-output-string)) (json-write '((a . #(1 2))) p) (get-output-string p)`, expect: `{"a":[1,2]}`},
+		&tcase{src: `(with-output-to-string (lambda () (json-write "x")))`, expect: `"x"`},
+	}
+	testTcases(t, tcases, (*State).OpenJSON)
+
+	var b strings.Builder
+	s := NewState(Option{Stdout: &b}).OpenJSON()
+	if err := s.ExecString(`(json-write (vector 1 "a" json-null))`); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `[1,"a",null]` {
+		t.Fatalf("expected %q, but got %q", `[1,"a",null]`, b.String())
+	}
+}
@@ -0,0 +1,246 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"math"
+)
+
+// OpenList registers the list library. (SRFI-1)
+// The list procedures of R7RS are in the base library.
+func (s *State) OpenList() *State {
+	s.RegisterFunc("iota", 1, 3, fnIota)
+	s.RegisterFunc("filter", 2, 2, fnFilter)
+	s.RegisterFunc("remove", 2, 2, fnRemove)
+	s.RegisterFunc("partition", 2, 2, fnPartition)
+	s.RegisterFunc("fold", 3, -1, fnFold)
+	s.RegisterFunc("fold-right", 3, -1, fnFoldRight)
+	s.RegisterFunc("reduce", 3, 3, fnReduce)
+	s.RegisterFunc("delete", 2, 3, fnDelete)
+	s.RegisterFunc("delete-duplicates", 1, 2, fnDeleteDuplicates)
+	s.RegisterFunc("any", 2, -1, fnAny)
+	s.RegisterFunc("every", 2, -1, fnEvery)
+	return s
+}
+
+// (iota count [start [step]])
+func fnIota(s *State, args []types.Object) (types.Object, error) {
+	count, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyNumber, args[1:]...); err != nil {
+		return nil, err
+	}
+	start, step := types.Number(0), types.Number(1)
+	if len(args) > 1 {
+		start = args[1].(types.Number)
+	}
+	if len(args) > 2 {
+		step = args[2].(types.Number)
+	}
+	elems := make([]types.Object, count)
+	for i := range elems {
+		elems[i] = start + types.Number(i)*step
+	}
+	return types.List(elems...), nil
+}
+
+// partitionList splits list into the elements which satisfy pred and the others.
+func (s *State) partitionList(pred, list types.Object) (in []types.Object, out []types.Object, err error) {
+	err = mapLists([]types.Object{list}, func(elems []types.Object) error {
+		v, err := s.Call(pred, elems[0])
+		if err != nil {
+			return err
+		}
+		if types.IsTruthy(v) {
+			in = append(in, elems[0])
+		} else {
+			out = append(out, elems[0])
+		}
+		return nil
+	})
+	return
+}
+
+// (filter pred list)
+func fnFilter(s *State, args []types.Object) (types.Object, error) {
+	in, _, err := s.partitionList(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.List(in...), nil
+}
+
+// (remove pred list)
+func fnRemove(s *State, args []types.Object) (types.Object, error) {
+	_, out, err := s.partitionList(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.List(out...), nil
+}
+
+// (partition pred list)
+// Returns two values, the elements which satisfy pred and the others.
+func fnPartition(s *State, args []types.Object) (types.Object, error) {
+	in, out, err := s.partitionList(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewValues([]types.Object{types.List(in...), types.List(out...)}), nil
+}
+
+// (fold kons knil list1 list2 ...)
+// kons is called as (kons elem1 elem2 ... acc) from left to right.
+func fnFold(s *State, args []types.Object) (types.Object, error) {
+	acc := args[1]
+	err := mapLists(args[2:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], append(elems, acc)...)
+		if err != nil {
+			return err
+		}
+		acc = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// (fold-right kons knil list1 list2 ...)
+// kons is called as (kons elem1 elem2 ... acc) from right to left.
+func fnFoldRight(s *State, args []types.Object) (types.Object, error) {
+	rows := [][]types.Object{}
+	err := mapLists(args[2:], func(elems []types.Object) error {
+		rows = append(rows, elems)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	acc := args[1]
+	for i := len(rows) - 1; i >= 0; i-- {
+		if acc, err = s.Call(args[0], append(rows[i], acc)...); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// (reduce f ridentity list)
+// Same as (fold f (car list) (cdr list)), but returns ridentity if list is empty.
+func fnReduce(s *State, args []types.Object) (types.Object, error) {
+	pair, ok := args[2].(*types.Pair)
+	if !ok {
+		if types.IsNull(args[2]) {
+			return args[1], nil
+		}
+		return nil, types.NewTypeError("list required, but got %v", args[2])
+	}
+	return fnFold(s, []types.Object{args[0], pair.Car(), pair.Cdr()})
+}
+
+// (delete x list [=])
+// Deletes the elements of list which are equal? to x.
+func fnDelete(s *State, args []types.Object) (types.Object, error) {
+	equiv := s.equivalence("member", args[2:])
+	kept := []types.Object{}
+	err := mapLists([]types.Object{args[1]}, func(elems []types.Object) error {
+		same, err := equiv(args[0], elems[0])
+		if err != nil {
+			return err
+		}
+		if !same {
+			kept = append(kept, elems[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.List(kept...), nil
+}
+
+// (delete-duplicates list [=])
+// The first occurrence of each element is kept.
+// Without =, the elements are compared by equal? in linear time.
+func fnDeleteDuplicates(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	kept := []types.Object{}
+	if len(args) == 1 {
+		seen := types.NewEqualHashTable()
+		for _, elem := range elems {
+			if _, found, _ := seen.Get(elem); found {
+				continue
+			}
+			seen.Set(elem, types.Boolean(true))
+			kept = append(kept, elem)
+		}
+		return types.List(kept...), nil
+	}
+	equiv := s.equivalence("member", args[1:])
+	for _, elem := range elems {
+		dup := false
+		for _, k := range kept {
+			if dup, err = equiv(k, elem); err != nil {
+				return nil, err
+			}
+			if dup {
+				break
+			}
+		}
+		if !dup {
+			kept = append(kept, elem)
+		}
+	}
+	return types.List(kept...), nil
+}
+
+// errStopIteration stops mapLists without an error.
+var errStopIteration = types.NewInternalError("stop iteration")
+
+// (any pred list1 list2 ...)
+// Returns the first true value returned by pred, or #f.
+func fnAny(s *State, args []types.Object) (types.Object, error) {
+	var result types.Object = types.Boolean(false)
+	err := mapLists(args[1:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], elems...)
+		if err != nil {
+			return err
+		}
+		if types.IsTruthy(v) {
+			result = v
+			return errStopIteration
+		}
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		return nil, err
+	}
+	return result, nil
+}
+
+// (every pred list1 list2 ...)
+// Returns the last value returned by pred if all the values are true, or #f.
+func fnEvery(s *State, args []types.Object) (types.Object, error) {
+	var result types.Object = types.Boolean(true)
+	err := mapLists(args[1:], func(elems []types.Object) error {
+		v, err := s.Call(args[0], elems...)
+		if err != nil {
+			return err
+		}
+		result = v
+		if types.IsFalse(v) {
+			return errStopIteration
+		}
+		return nil
+	})
+	if err != nil && err != errStopIteration {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestFnIota(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (iota 3) (list 0 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (iota 3 1 2) (list 1 3 5))", expect: "#t"},
+		&tcase{src: "(iota -1)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
+
+func TestFnFilter(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (filter (lambda (x) (< x 3)) (list 1 5 2 4)) (list 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (remove (lambda (x) (< x 3)) (list 1 5 2 4)) (list 5 4))", expect: "#t"},
+		&tcase{src: "(call-with-values (lambda () (partition (lambda (x) (< x 3)) (list 1 5 2))) (lambda (in out) (+ (length in) (* 10 (length out)))))", expect: "12"},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
+
+func TestFnFold(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(fold + 0 (list 1 2 3))", expect: "6"},
+		&tcase{src: "(equal? (fold cons '() (list 1 2 3)) (list 3 2 1))", expect: "#t"},
+		&tcase{src: "(fold (lambda (a b acc) (+ acc (* a b))) 0 (list 1 2) (list 3 4))", expect: "11"},
+		&tcase{src: "(equal? (fold-right cons '() (list 1 2 3)) (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(reduce + 0 (list 1 2 3))", expect: "6"},
+		&tcase{src: "(reduce + 0 '())", expect: "0"},
+		&tcase{src: "(reduce - 0 (list 1 2 3))", expect: "2"},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
+
+func TestFnDelete(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (delete 2 (list 1 2 3 2)) (list 1 3))", expect: "#t"},
+		&tcase{src: "(equal? (delete 2 (list 1 2 3) <) (list 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (delete-duplicates (list 1 2 1 (list 3) (list 3))) (list 1 2 (list 3)))", expect: "#t"},
+		&tcase{src: "(equal? (delete-duplicates (list 1 2 3 4) (lambda (a b) (= (+ a b) 5))) (list 1 2))", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
+
+func TestFnAny(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(any (lambda (x) (if (> x 1) x #f)) (list 1 2 3))", expect: "2"},
+		&tcase{src: "(any < (list 3 2) (list 1 1))", expect: "#f"},
+		&tcase{src: "(every (lambda (x) (> x 0)) (list 1 2))", expect: "#t"},
+		&tcase{src: "(every (lambda (x) (> x 1)) (list 1 2))", expect: "#f"},
+		&tcase{src: "(every car '())", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
+
+func TestFnListLarge(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(= (length (map (lambda (x) (+ x 1)) (iota 1000000))) 1000000)", expect: "#t"},
+		&tcase{src: "(= (fold + 0 (iota 1000000)) 499999500000)", expect: "#t"},
+		&tcase{src: "(= (fold-right (lambda (x acc) (+ acc 1)) 0 (iota 1000000)) 1000000)", expect: "#t"},
+		&tcase{src: "(length (filter (lambda (x) (< x 10)) (iota 1000000)))", expect: "10"},
+		&tcase{src: "(= (length (list-copy (reverse (iota 1000000)))) 1000000)", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenList)
+}
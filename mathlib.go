@@ -0,0 +1,256 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"math"
+)
+
+// OpenMath registers the math library. (R7RS 6.2.6)
+// All numbers are inexact, so the procedures on exact integers accept
+// numbers which have integral values.
+// Procedures whose result would be a complex number return NaN.
+func (s *State) OpenMath() *State {
+	s.RegisterFunc("exact-integer?", 1, 1, fnIsExactInt)
+	s.RegisterFunc("nan?", 1, 1, genFnNumPred(math.IsNaN))
+	s.RegisterFunc("infinite?", 1, 1, genFnNumPred(func(x float64) bool { return math.IsInf(x, 0) }))
+	s.RegisterFunc("finite?", 1, 1, genFnNumPred(func(x float64) bool { return !math.IsInf(x, 0) && !math.IsNaN(x) }))
+	s.RegisterFunc("zero?", 1, 1, genFnNumPred(func(x float64) bool { return x == 0 }))
+	s.RegisterFunc("positive?", 1, 1, genFnNumPred(func(x float64) bool { return x > 0 }))
+	s.RegisterFunc("negative?", 1, 1, genFnNumPred(func(x float64) bool { return x < 0 }))
+	s.RegisterFunc("odd?", 1, 1, genFnIntPred(func(x float64) bool { return math.Mod(x, 2) != 0 }))
+	s.RegisterFunc("even?", 1, 1, genFnIntPred(func(x float64) bool { return math.Mod(x, 2) == 0 }))
+	s.RegisterFunc("max", 1, -1, genFnMinMax(math.Max))
+	s.RegisterFunc("min", 1, -1, genFnMinMax(math.Min))
+	s.RegisterFunc("abs", 1, 1, genFnMath1(math.Abs))
+	s.RegisterFunc("floor/", 2, 2, genFnDiv(floorDiv, 2))
+	s.RegisterFunc("floor-quotient", 2, 2, genFnDiv(floorDiv, 0))
+	s.RegisterFunc("floor-remainder", 2, 2, genFnDiv(floorDiv, 1))
+	s.RegisterFunc("truncate/", 2, 2, genFnDiv(truncateDiv, 2))
+	s.RegisterFunc("truncate-quotient", 2, 2, genFnDiv(truncateDiv, 0))
+	s.RegisterFunc("truncate-remainder", 2, 2, genFnDiv(truncateDiv, 1))
+	s.RegisterFunc("quotient", 2, 2, genFnDiv(truncateDiv, 0))
+	s.RegisterFunc("remainder", 2, 2, genFnDiv(truncateDiv, 1))
+	s.RegisterFunc("modulo", 2, 2, genFnDiv(floorDiv, 1))
+	s.RegisterFunc("gcd", 0, -1, fnGcd)
+	s.RegisterFunc("lcm", 0, -1, fnLcm)
+	s.RegisterFunc("floor", 1, 1, genFnMath1(math.Floor))
+	s.RegisterFunc("ceiling", 1, 1, genFnMath1(math.Ceil))
+	s.RegisterFunc("truncate", 1, 1, genFnMath1(math.Trunc))
+	s.RegisterFunc("round", 1, 1, genFnMath1(math.RoundToEven))
+	s.RegisterFunc("exp", 1, 1, genFnMath1(math.Exp))
+	s.RegisterFunc("log", 1, 2, fnLog)
+	s.RegisterFunc("sin", 1, 1, genFnMath1(math.Sin))
+	s.RegisterFunc("cos", 1, 1, genFnMath1(math.Cos))
+	s.RegisterFunc("tan", 1, 1, genFnMath1(math.Tan))
+	s.RegisterFunc("asin", 1, 1, genFnMath1(math.Asin))
+	s.RegisterFunc("acos", 1, 1, genFnMath1(math.Acos))
+	s.RegisterFunc("atan", 1, 2, fnAtan)
+	s.RegisterFunc("square", 1, 1, genFnMath1(func(x float64) float64 { return x * x }))
+	s.RegisterFunc("sqrt", 1, 1, genFnMath1(math.Sqrt))
+	s.RegisterFunc("exact-integer-sqrt", 1, 1, fnExactIntSqrt)
+	s.RegisterFunc("expt", 2, 2, fnExpt)
+	return s
+}
+
+func isInteger(x float64) bool {
+	return x == math.Trunc(x) && !math.IsInf(x, 0)
+}
+
+// toInteger converts obj to a float64 which has an integral value.
+func toInteger(obj types.Object) (float64, error) {
+	if err := types.AssertType(types.TyNumber, obj); err != nil {
+		return 0, err
+	}
+	x := float64(obj.(types.Number))
+	if !isInteger(x) {
+		return 0, types.NewTypeError("integer required, but got %v", obj)
+	}
+	return x, nil
+}
+
+func fnIsExactInt(s *State, args []types.Object) (types.Object, error) {
+	num, ok := args[0].(types.Number)
+	return types.Boolean(ok && isInteger(float64(num))), nil
+}
+
+// genFnNumPred generates a predicate on a number.
+func genFnNumPred(pred func(x float64) bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyNumber, args[0]); err != nil {
+			return nil, err
+		}
+		return types.Boolean(pred(float64(args[0].(types.Number)))), nil
+	}
+}
+
+// genFnIntPred generates a predicate on an integer.
+func genFnIntPred(pred func(x float64) bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		x, err := toInteger(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return types.Boolean(pred(x)), nil
+	}
+}
+
+// genFnMath1 generates a procedure which applies fn to a number.
+func genFnMath1(fn func(x float64) float64) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyNumber, args[0]); err != nil {
+			return nil, err
+		}
+		return types.Number(fn(float64(args[0].(types.Number)))), nil
+	}
+}
+
+// genFnMinMax generates max and min.
+func genFnMinMax(pick func(x, y float64) float64) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyNumber, args...); err != nil {
+			return nil, err
+		}
+		result := float64(args[0].(types.Number))
+		for _, arg := range args[1:] {
+			result = pick(result, float64(arg.(types.Number)))
+		}
+		return types.Number(result), nil
+	}
+}
+
+// floorDiv returns the quotient rounded toward negative infinity and the remainder.
+func floorDiv(n1, n2 float64) (float64, float64) {
+	q, r := truncateDiv(n1, n2)
+	if r != 0 && (r < 0) != (n2 < 0) {
+		q--
+		r += n2
+	}
+	return q, r
+}
+
+// truncateDiv returns the quotient rounded toward zero and the remainder.
+func truncateDiv(n1, n2 float64) (float64, float64) {
+	r := math.Mod(n1, n2)
+	return (n1 - r) / n2, r
+}
+
+// genFnDiv generates the integer division procedures.
+// result selects the quotient (0), the remainder (1) or both of them (2).
+func genFnDiv(div func(n1, n2 float64) (float64, float64), result int) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		n1, err := toInteger(args[0])
+		if err != nil {
+			return nil, err
+		}
+		n2, err := toInteger(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if n2 == 0 {
+			return nil, types.NewInternalError("division by zero")
+		}
+		q, r := div(n1, n2)
+		switch result {
+		case 0:
+			return types.Number(q), nil
+		case 1:
+			return types.Number(r), nil
+		}
+		return types.NewValues([]types.Object{types.Number(q), types.Number(r)}), nil
+	}
+}
+
+func gcd(a, b float64) float64 {
+	a, b = math.Abs(a), math.Abs(b)
+	for b != 0 {
+		a, b = b, math.Mod(a, b)
+	}
+	return a
+}
+
+func fnGcd(s *State, args []types.Object) (types.Object, error) {
+	result := 0.0
+	for _, arg := range args {
+		x, err := toInteger(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = gcd(result, x)
+	}
+	return types.Number(result), nil
+}
+
+func fnLcm(s *State, args []types.Object) (types.Object, error) {
+	result := 1.0
+	for _, arg := range args {
+		x, err := toInteger(arg)
+		if err != nil {
+			return nil, err
+		}
+		if x == 0 {
+			result = 0
+			continue
+		}
+		if result != 0 {
+			result = math.Abs(result*x) / gcd(result, x)
+		}
+	}
+	return types.Number(result), nil
+}
+
+// (log z [base])
+func fnLog(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyNumber, args...); err != nil {
+		return nil, err
+	}
+	z := math.Log(float64(args[0].(types.Number)))
+	if len(args) == 1 {
+		return types.Number(z), nil
+	}
+	return types.Number(z / math.Log(float64(args[1].(types.Number)))), nil
+}
+
+// (atan z) or (atan y x)
+func fnAtan(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyNumber, args...); err != nil {
+		return nil, err
+	}
+	y := float64(args[0].(types.Number))
+	if len(args) == 1 {
+		return types.Number(math.Atan(y)), nil
+	}
+	return types.Number(math.Atan2(y, float64(args[1].(types.Number)))), nil
+}
+
+// (exact-integer-sqrt k)
+// Returns s and r such that k = s^2 + r and k < (s+1)^2.
+// k must not be greater than 2^53, above which integers are not exact.
+func fnExactIntSqrt(s *State, args []types.Object) (types.Object, error) {
+	k, err := toInteger(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if k < 0 {
+		return nil, types.NewInternalError("non-negative integer required, but got %v", args[0])
+	}
+	if k > 1<<53 {
+		return nil, types.NewInternalError("integer out of range: %v", args[0])
+	}
+	n := uint64(k)
+	root := uint64(math.Sqrt(k))
+	// correct rounding errors of large values
+	for root*root > n {
+		root--
+	}
+	for (root+1)*(root+1) <= n {
+		root++
+	}
+	return types.NewValues([]types.Object{types.Number(root), types.Number(n - root*root)}), nil
+}
+
+func fnExpt(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyNumber, args...); err != nil {
+		return nil, err
+	}
+	return types.Number(math.Pow(float64(args[0].(types.Number)), float64(args[1].(types.Number)))), nil
+}
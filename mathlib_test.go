@@ -0,0 +1,98 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestFnNumPred(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(exact-integer? 3)", expect: "#t"},
+		&tcase{src: "(exact-integer? 3.5)", expect: "#f"},
+		&tcase{src: "(exact-integer? \"3\")", expect: "#f"},
+		&tcase{src: "(nan? (sqrt -1))", expect: "#t"},
+		&tcase{src: "(infinite? (- (exp 1000)))", expect: "#t"},
+		&tcase{src: "(finite? (exp 1000))", expect: "#f"},
+		&tcase{src: "(zero? 0)", expect: "#t"},
+		&tcase{src: "(positive? -1)", expect: "#f"},
+		&tcase{src: "(negative? -1)", expect: "#t"},
+		&tcase{src: "(odd? -3)", expect: "#t"},
+		&tcase{src: "(even? 0)", expect: "#t"},
+		&tcase{src: "(even? 1.5)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
+
+func TestFnMinMax(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(max 1 3 2)", expect: "3"},
+		&tcase{src: "(min 1 -3 2)", expect: "-3"},
+		&tcase{src: "(abs -7)", expect: "7"},
+		&tcase{src: "(max 'a)", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
+
+func TestFnIntDiv(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(floor/ 5 2)", expect: "2 1"},
+		&tcase{src: "(floor/ -5 2)", expect: "-3 1"},
+		&tcase{src: "(floor/ 5 -2)", expect: "-3 -1"},
+		&tcase{src: "(truncate/ -5 2)", expect: "-2 -1"},
+		&tcase{src: "(truncate/ 5 -2)", expect: "-2 1"},
+		&tcase{src: "(modulo -7 2)", expect: "1"},
+		&tcase{src: "(remainder -7 2)", expect: "-1"},
+		&tcase{src: "(quotient 7 2)", expect: "3"},
+		&tcase{src: "(floor/ 1 0)", expectErr: true},
+		&tcase{src: "(floor/ 1.5 1)", expectErr: true},
+		&tcase{src: "(gcd 32 -36)", expect: "4"},
+		&tcase{src: "(gcd)", expect: "0"},
+		&tcase{src: "(lcm 32 -36)", expect: "288"},
+		&tcase{src: "(lcm)", expect: "1"},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
+
+func TestFnRound(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(floor -4.3)", expect: "-5"},
+		&tcase{src: "(ceiling -4.3)", expect: "-4"},
+		&tcase{src: "(truncate -4.7)", expect: "-4"},
+		&tcase{src: "(round -4.3)", expect: "-4"},
+		&tcase{src: "(round 3.5)", expect: "4"},
+		&tcase{src: "(round 2.5)", expect: "2"},
+		&tcase{src: "(round -2.5)", expect: "-2"},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
+
+func TestFnTranscendental(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(exp 0)", expect: "1"},
+		&tcase{src: "(log 1)", expect: "0"},
+		&tcase{src: "(log 8 2)", expect: "3"},
+		&tcase{src: "(sin 0)", expect: "0"},
+		&tcase{src: "(cos 0)", expect: "1"},
+		&tcase{src: "(tan 0)", expect: "0"},
+		&tcase{src: "(asin 0)", expect: "0"},
+		&tcase{src: "(acos 1)", expect: "0"},
+		&tcase{src: "(atan 1 0)", expect: "1.5707963267948966"},
+		&tcase{src: "(atan 0)", expect: "0"},
+		&tcase{src: "(nan? (sqrt -1))", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
+
+func TestFnSqrt(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(sqrt 9)", expect: "3"},
+		&tcase{src: "(square 5)", expect: "25"},
+		&tcase{src: "(exact-integer-sqrt 17)", expect: "4 1"},
+		&tcase{src: "(exact-integer-sqrt 16)", expect: "4 0"},
+		&tcase{src: "(exact-integer-sqrt -1)", expectErr: true},
+		&tcase{src: "(call-with-values (lambda () (exact-integer-sqrt 9007199254740992)) (lambda (s r) (list (= s 94906265) (= (+ (* s s) r) 9007199254740992))))", expect: "(#t #t)"},
+		&tcase{src: "(exact-integer-sqrt 1e300)", expectErr: true},
+		&tcase{src: "(expt 2 10)", expect: "1024"},
+		&tcase{src: "(expt 4 0.5)", expect: "2"},
+	}
+	testTcases(t, tcases, (*State).OpenMath)
+}
@@ -3,149 +3,361 @@ package parser
 import (
 	"github.com/hyusuk/tama/scanner"
 	"github.com/hyusuk/tama/types"
+	"io"
 	"strconv"
+	"unicode/utf8"
 )
 
 type File struct {
-	Objs []types.Object // top-level expressions
+	Objs  []types.Object  // top-level expressions
+	Spans types.SourceMap // spans of the lists in Objs
 }
 
+// Parser parses data from the tokens of the scanner.
+// A token is scanned only when it is required, so ParseObject never
+// reads beyond the end of the datum.
 type Parser struct {
 	scanner scanner.Scanner
-	tok     scanner.Token // Next token
-	lit     string        // Next token literal
+	tok     scanner.Token           // Next token
+	lit     string                  // Next token literal
+	peeked  bool                    // tok is scanned, but not consumed
+	labels  map[string]types.Object // datum labels of the current datum
+	spans   types.SourceMap         // spans of the parsed lists
+}
+
+// placeholder stands for a labeled datum referred to before the datum is completed,
+// such as #0# in #0=(a . #0#). It is replaced once the datum is parsed.
+type placeholder struct {
+	value types.Object
+}
+
+func (ph *placeholder) String() string {
+	return "#<placeholder>"
+}
+
+func (ph *placeholder) Type() types.ObjectType {
+	return types.TyUndefined
 }
 
 func (p *Parser) Init(src []byte) error {
-	p.scanner.Init(src)
-	if err := p.next(); err != nil {
-		return err
-	}
+	return p.InitFile("", src)
+}
+
+// InitFile initializes the parser to parse src named filename.
+func (p *Parser) InitFile(filename string, src []byte) error {
+	p.scanner.InitFile(filename, src)
+	p.peeked = false
+	p.spans = types.SourceMap{}
 	return nil
 }
 
-func (p *Parser) next() error {
-	var err error
-	if p.tok, p.lit, err = p.scanner.Scan(); err != nil {
-		return err
+// InitReader initializes the parser to read from r.
+// line and column are the position of the first character of r.
+func (p *Parser) InitReader(r io.RuneScanner, line, column int) {
+	p.scanner.InitReader(r, line, column)
+	p.peeked = false
+	p.spans = types.SourceMap{}
+}
+
+// Spans returns the spans of the lists parsed so far.
+func (p *Parser) Spans() types.SourceMap {
+	return p.spans
+}
+
+// setSpan records the span of obj from start to the end of the last token if obj is a list.
+func (p *Parser) setSpan(obj types.Object, start types.Pos) {
+	if pair, ok := obj.(*types.Pair); ok && p.spans != nil {
+		p.spans[pair] = types.Span{Start: start, End: p.scanner.End()}
 	}
-	return nil
 }
 
-func (p *Parser) expect(tok scanner.Token) error {
-	if p.tok != tok {
-		return types.NewSyntaxError("expected token %d, but got %d", tok, p.tok)
+// peek returns the next token without consuming it.
+func (p *Parser) peek() (scanner.Token, error) {
+	if !p.peeked {
+		var err error
+		if p.tok, p.lit, err = p.scanner.Scan(); err != nil {
+			return scanner.ILLEGAL, err
+		}
+		p.peeked = true
 	}
-	if err := p.next(); err != nil {
+	return p.tok, nil
+}
+
+// next consumes the next token.
+func (p *Parser) next() (scanner.Token, error) {
+	tok, err := p.peek()
+	p.peeked = false
+	return tok, err
+}
+
+func (p *Parser) error(format string, v ...interface{}) error {
+	return types.NewReadError(p.scanner.Pos(), format, v...)
+}
+
+func (p *Parser) expect(tok scanner.Token) error {
+	next, err := p.next()
+	if err != nil {
 		return err
 	}
+	if next != tok {
+		return p.error("expected token %d, but got %d", tok, next)
+	}
 	return nil
 }
 
 func (p *Parser) parseFloat() (types.Object, error) {
 	f, err := strconv.ParseFloat(p.lit, 64)
 	if err != nil {
-		return nil, types.NewSyntaxError("cannot parse %s as a number", p.lit)
+		return nil, p.error("cannot parse %s as a number", p.lit)
+	}
+	return types.Number(f), nil
+}
+
+// parseAbbreviation parses the datum after an abbreviation such as ' and
+// returns the list (name datum).
+func (p *Parser) parseAbbreviation(name string) (types.Object, error) {
+	start := p.scanner.Pos()
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
 	}
-	n := types.Number(f)
-	return n, p.next()
+	abbr := types.List(types.NewSymbol(name), obj)
+	p.setSpan(abbr, start)
+	return abbr, nil
 }
 
 func (p *Parser) parseIdent() (types.Object, error) {
-	sym := types.NewSymbol(p.lit)
-	return sym, p.next()
+	return types.NewSymbol(p.lit), nil
 }
 
-func (p *Parser) parsePair() (types.Object, error) {
-	if p.tok == scanner.RPAREN {
-		return types.NilObject, p.next()
+// parseElements parses data until ")".
+// If dotted is true, the datum after "." is returned as the tail, otherwise the tail is ().
+func (p *Parser) parseElements(dotted bool) ([]types.Object, types.Object, error) {
+	elems := []types.Object{}
+	for {
+		tok, err := p.peek()
+		if err != nil {
+			return nil, nil, err
+		}
+		if tok == scanner.RPAREN {
+			p.next()
+			return elems, types.NilObject, nil
+		}
+		if tok == scanner.IDENT && p.lit == "." {
+			if !dotted || len(elems) == 0 {
+				p.next()
+				return nil, nil, p.error("unexpected .")
+			}
+			p.next()
+			tail, err := p.parseObject()
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := p.expect(scanner.RPAREN); err != nil {
+				return nil, nil, p.error("expected ) after the tail of the dotted list")
+			}
+			return elems, tail, nil
+		}
+		o, err := p.parseObject()
+		if err != nil {
+			return nil, nil, err
+		}
+		elems = append(elems, o)
 	}
-	car, err := p.parseObject()
+}
+
+func (p *Parser) parsePair() (types.Object, error) {
+	elems, tail, err := p.parseElements(true)
 	if err != nil {
 		return nil, err
 	}
-	cdr, err := p.parsePair()
+	return types.ListWithTail(tail, elems...), nil
+}
+
+func (p *Parser) parseVector() (types.Object, error) {
+	elems, _, err := p.parseElements(false)
 	if err != nil {
 		return nil, err
 	}
-	return types.Cons(car, cdr), nil
+	return types.NewVector(elems), nil
 }
 
-func (p *Parser) parseVector() (types.Object, error) {
-	var v types.Vector
-	for p.tok != scanner.RPAREN {
-		o, err := p.parseObject()
-		if err != nil {
-			return nil, err
-		}
-		v = append(v, o)
-	}
-	if err := p.next(); err != nil {
+func (p *Parser) parseBytevector() (types.Object, error) {
+	elems, _, err := p.parseElements(false)
+	if err != nil {
 		return nil, err
 	}
-	return v, nil
+	bytes := []byte{}
+	for _, o := range elems {
+		num, ok := o.(types.Number)
+		if !ok || num < 0 || num > 255 || num != types.Number(int(num)) {
+			return nil, p.error("invalid byte %v in bytevector", o)
+		}
+		bytes = append(bytes, byte(num))
+	}
+	return types.NewBytevector(bytes), nil
 }
 
 func (p *Parser) parseString() (types.Object, error) {
-	s := types.String(p.lit)
-	if err := p.next(); err != nil {
-		return nil, err
+	return types.NewString(p.lit), nil
+}
+
+func (p *Parser) parseChar() (types.Object, error) {
+	lit := p.lit
+	if r, size := utf8.DecodeRuneInString(lit); size == len(lit) {
+		return types.Char(r), nil
+	}
+	if ch, ok := types.CharByName(lit); ok {
+		return ch, nil
+	}
+	if lit[0] == 'x' {
+		if code, err := strconv.ParseUint(lit[1:], 16, 32); err == nil && utf8.ValidRune(rune(code)) {
+			return types.Char(code), nil
+		}
 	}
-	return s, nil
+	return nil, p.error("unknown character name #\\%s", lit)
 }
 
 func (p *Parser) parseObject() (types.Object, error) {
-	tok := p.tok
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
 	switch tok {
 	case scanner.NUMBER:
 		return p.parseFloat()
 	case scanner.LPAREN:
-		if err := p.next(); err != nil {
+		start := p.scanner.Pos()
+		obj, err := p.parsePair()
+		if err != nil {
 			return nil, err
 		}
-		return p.parsePair()
+		p.setSpan(obj, start)
+		return obj, nil
 	case scanner.VLPAREN:
-		if err := p.next(); err != nil {
-			return nil, err
-		}
 		return p.parseVector()
+	case scanner.BVLPAREN:
+		return p.parseBytevector()
 	case scanner.IDENT:
+		if p.lit == "." {
+			return nil, p.error("unexpected .")
+		}
 		return p.parseIdent()
 	case scanner.QUOTE: // '(1 2 3) => (quote (1 2 3))
-		if err := p.next(); err != nil {
-			return nil, err
-		}
-		obj, err := p.parseObject()
-		if err != nil {
-			return nil, err
-		}
-		return types.List(types.NewSymbol("quote"), obj), nil
-	case scanner.TRUE, scanner.FALSE:
-		if err := p.next(); err != nil {
-			return nil, err
-		}
-		if tok == scanner.TRUE {
-			return types.Boolean(true), nil
-		}
+		return p.parseAbbreviation("quote")
+	case scanner.QUASIQUOTE: // `(1 ,x) => (quasiquote (1 (unquote x)))
+		return p.parseAbbreviation("quasiquote")
+	case scanner.UNQUOTE:
+		return p.parseAbbreviation("unquote")
+	case scanner.UNQUOTE_SPLICING:
+		return p.parseAbbreviation("unquote-splicing")
+	case scanner.TRUE:
+		return types.Boolean(true), nil
+	case scanner.FALSE:
 		return types.Boolean(false), nil
 	case scanner.STRING:
 		return p.parseString()
+	case scanner.CHAR:
+		return p.parseChar()
+	case scanner.LABEL:
+		return p.parseLabel()
+	case scanner.LABELREF:
+		obj, ok := p.labels[p.lit]
+		if !ok {
+			return nil, p.error("undefined datum label #%s#", p.lit)
+		}
+		return obj, nil
+	case scanner.RPAREN:
+		return nil, p.error("unexpected )")
+	case scanner.EOF:
+		return nil, p.error("unexpected end of input")
 	default:
-		return nil, types.NewSyntaxError("unexpected token %d", p.tok)
+		return nil, p.error("unexpected token %d", tok)
+	}
+}
 
+// parseLabel parses the datum labeled by #n=.
+func (p *Parser) parseLabel() (types.Object, error) {
+	label := p.lit
+	if p.labels == nil {
+		p.labels = map[string]types.Object{}
+	}
+	if _, ok := p.labels[label]; ok {
+		return nil, p.error("duplicate datum label #%s=", label)
+	}
+	ph := &placeholder{}
+	p.labels[label] = ph
+	obj, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+	if obj == types.Object(ph) {
+		return nil, p.error("datum label #%s= refers to itself", label)
 	}
+	ph.value = obj
+	p.labels[label] = obj
+	replacePlaceholder(obj, ph, map[types.Object]bool{})
+	return obj, nil
+}
+
+// replacePlaceholder replaces ph inside obj with its value.
+func replacePlaceholder(obj types.Object, ph *placeholder, visited map[types.Object]bool) {
+	for !visited[obj] {
+		switch o := obj.(type) {
+		case *types.Pair:
+			visited[o] = true
+			if o.Car() == types.Object(ph) {
+				o.SetCar(ph.value)
+			} else {
+				replacePlaceholder(o.Car(), ph, visited)
+			}
+			if o.Cdr() == types.Object(ph) {
+				o.SetCdr(ph.value)
+				return
+			}
+			obj = o.Cdr()
+			continue
+		case *types.Vector:
+			visited[o] = true
+			for i, elem := range o.Elems() {
+				if elem == types.Object(ph) {
+					o.Set(i, ph.value)
+				} else {
+					replacePlaceholder(elem, ph, visited)
+				}
+			}
+		}
+		return
+	}
+}
+
+// ParseObject parses the next datum.
+// It returns io.EOF if there are no more data.
+func (p *Parser) ParseObject() (types.Object, error) {
+	tok, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if tok == scanner.EOF {
+		return nil, io.EOF
+	}
+	// the scope of datum labels is the outermost datum
+	p.labels = map[string]types.Object{}
+	return p.parseObject()
 }
 
 func (p *Parser) parseObjects() ([]types.Object, error) {
 	var objs []types.Object
-	for p.tok != scanner.EOF {
-		obj, err := p.parseObject()
+	for {
+		obj, err := p.ParseObject()
+		if err == io.EOF {
+			return objs, nil
+		}
 		if err != nil {
 			return objs, err
 		}
 		objs = append(objs, obj)
 	}
-	return objs, nil
 }
 
 func (p *Parser) ParseFile() (*File, error) {
@@ -153,5 +365,5 @@ func (p *Parser) ParseFile() (*File, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &File{Objs: objs}, nil
+	return &File{Objs: objs, Spans: p.spans}, nil
 }
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"github.com/hyusuk/tama/types"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -46,3 +48,168 @@ func TestParsePair(t *testing.T) {
 		t.Fatalf("expected %s, but got %s", sym.Name, "+")
 	}
 }
+
+func TestParseObject(t *testing.T) {
+	r := strings.NewReader("(a b) #(1) rest")
+	p := &Parser{}
+	p.InitReader(r, 1, 1)
+	for _, expect := range []string{"(a b)", "#(1)"} {
+		obj, err := p.ParseObject()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.String() != expect {
+			t.Fatalf("expected %s, but got %s", expect, obj.String())
+		}
+	}
+	// the parser must not read beyond the datum
+	rest, _ := io.ReadAll(r)
+	if string(rest) != " rest" {
+		t.Fatalf("expected %q to be left, but got %q", " rest", rest)
+	}
+	p.InitReader(strings.NewReader(" "), 1, 1)
+	if _, err := p.ParseObject(); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+}
+
+func TestParseAbbreviation(t *testing.T) {
+	p := &Parser{}
+	if err := p.Init([]byte("'a `(a ,b ,@c)")); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []string{"(quote a)", "(quasiquote (a (unquote b) (unquote-splicing c)))"}
+	for i, expect := range expects {
+		if f.Objs[i].String() != expect {
+			t.Fatalf("expected %s, but got %s", expect, f.Objs[i].String())
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tcases := []struct {
+		src          string
+		line, column int
+	}{
+		{"(1 2", 1, 5},
+		{"(1\n 2))", 2, 4},
+		{"#u8(1 256)", 1, 10},
+		{"#\\foo", 1, 1},
+	}
+	for i, tc := range tcases {
+		p := &Parser{}
+		p.Init([]byte(tc.src))
+		_, err := p.ParseFile()
+		e, ok := err.(*types.Error)
+		if !ok || e.ErrorType() != types.ErrRead {
+			t.Fatalf("case %d: expected read error, but got %v", i, err)
+		}
+		if pos := e.Position(); pos.Line != tc.line || pos.Column != tc.column {
+			t.Fatalf("case %d: expected %d:%d, but got %v", i, tc.line, tc.column, pos)
+		}
+	}
+}
+
+func TestParseDotted(t *testing.T) {
+	p := &Parser{}
+	p.Init([]byte("(1 2 . 3) (a . (b))"))
+	for _, expect := range []string{"(1 2 . 3)", "(a b)"} {
+		obj, err := p.ParseObject()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.String() != expect {
+			t.Fatalf("expected %s, but got %s", expect, obj.String())
+		}
+	}
+	for i, src := range []string{"(. 1)", "(1 . 2 3)", "(1 .)", "#(1 . 2)", "."} {
+		p.Init([]byte(src))
+		if _, err := p.ParseObject(); err == nil {
+			t.Fatalf("case %d: expected error for %s", i, src)
+		}
+	}
+}
+
+func TestParseLabel(t *testing.T) {
+	parse := func(src string) (types.Object, error) {
+		p := &Parser{}
+		p.Init([]byte(src))
+		return p.ParseObject()
+	}
+	obj, err := parse("#0=(a b . #0#)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair := obj.(*types.Pair)
+	if pair.Cdr().(*types.Pair).Cdr() != obj {
+		t.Fatalf("expected a circular list")
+	}
+	obj, err = parse("(#1=(x) #1# '#1#)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems, _ := obj.(*types.Pair).Slice()
+	quoted, _ := elems[2].(*types.Pair).Second()
+	if elems[0] != elems[1] || elems[0] != quoted {
+		t.Fatalf("expected shared elements, but got %v", obj)
+	}
+	obj, err = parse("#0=#(1 #0# (#0#))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec := obj.(*types.Vector)
+	if vec.Ref(1) != obj || vec.Ref(2).(*types.Pair).Car() != obj {
+		t.Fatalf("expected a circular vector")
+	}
+	for i, src := range []string{"#0#", "#0=(#1#)", "(#0=a #0=b)", "#0=#0#", "#0x"} {
+		if _, err := parse(src); err == nil {
+			t.Fatalf("case %d: expected error for %s", i, src)
+		}
+	}
+	// labels are scoped to the outermost datum
+	p := &Parser{}
+	p.Init([]byte("#0=(a) #0#"))
+	p.ParseObject()
+	if _, err := p.ParseObject(); err == nil {
+		t.Fatalf("expected error for a label of the previous datum")
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	p := &Parser{}
+	p.InitFile("a.scm", []byte("(define (f x)\n  (car x))\n'(1 2) #(3)"))
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	define := f.Objs[0].(*types.Pair)
+	head := define.Cdr().(*types.Pair).Car().(*types.Pair)
+	body := define.Cdr().(*types.Pair).Cdr().(*types.Pair).Car().(*types.Pair)
+	quote := f.Objs[1].(*types.Pair)
+	quoted := quote.Cdr().(*types.Pair).Car().(*types.Pair)
+	for i, tc := range []struct {
+		pair       *types.Pair
+		start, end string
+	}{
+		{define, "a.scm:1:1", "a.scm:2:11"},
+		{head, "a.scm:1:9", "a.scm:1:14"},
+		{body, "a.scm:2:3", "a.scm:2:10"},
+		{quote, "a.scm:3:1", "a.scm:3:7"},
+		{quoted, "a.scm:3:2", "a.scm:3:7"},
+	} {
+		span, ok := f.Spans[tc.pair]
+		if !ok {
+			t.Fatalf("case %d: no span for %v", i, tc.pair)
+		}
+		if span.Start.String() != tc.start || span.End.String() != tc.end {
+			t.Fatalf("case %d: expected %s-%s, but got %v-%v", i, tc.start, tc.end, span.Start, span.End)
+		}
+	}
+	if len(f.Spans) != 5 {
+		t.Fatalf("expected 5 spans, but got %d", len(f.Spans))
+	}
+}
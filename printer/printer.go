@@ -0,0 +1,384 @@
+// Package printer prints the external representations of objects.
+package printer
+
+import (
+	"fmt"
+	"github.com/hyusuk/tama/types"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Mode selects how strings and characters are printed.
+type Mode int
+
+const (
+	// Display prints strings and characters as they are, like display.
+	Display Mode = iota
+	// Write prints objects so that they can be read back, like write.
+	Write
+)
+
+// Labels selects the objects printed with datum labels such as #0=(a . #0#).
+type Labels int
+
+const (
+	// LabelCycles labels the objects in cycles, like write.
+	LabelCycles Labels = iota
+	// LabelShared labels all the objects appearing more than once, like write-shared.
+	LabelShared
+	// LabelNone never labels objects, like write-simple.
+	// Printing cyclic objects does not terminate unless MaxDepth or MaxLength is set.
+	LabelNone
+)
+
+// Printer prints objects. The zero value prints in the display mode without truncation,
+// labeling cycles.
+type Printer struct {
+	Mode   Mode
+	Labels Labels
+	// MaxDepth is the maximum nesting of lists, vectors and records.
+	// Deeper ones are printed as "...". Zero means no limit.
+	MaxDepth int
+	// MaxLength is the maximum number of elements printed for each list, vector and record.
+	// The rest is printed as "...". Zero means no limit.
+	MaxLength int
+}
+
+// Fprint writes the representation of obj to w.
+func (p *Printer) Fprint(w io.Writer, obj types.Object) error {
+	_, err := io.WriteString(w, p.Sprint(obj))
+	return err
+}
+
+// Sprint returns the representation of obj.
+func (p *Printer) Sprint(obj types.Object) string {
+	var b strings.Builder
+	st := &state{Printer: p}
+	if p.Labels != LabelNone {
+		st.labels = findLabels(obj, p.Labels == LabelShared)
+	}
+	st.print(&b, obj, 0)
+	return b.String()
+}
+
+// state is the state of printing an object.
+type state struct {
+	*Printer
+	labels map[types.Object]int // label numbers, or -1 until the object is printed
+	next   int                  // next label number
+}
+
+// WriteString returns the representation of obj in the write mode.
+func WriteString(obj types.Object) string {
+	return (&Printer{Mode: Write}).Sprint(obj)
+}
+
+// DisplayString returns the representation of obj in the display mode.
+func DisplayString(obj types.Object) string {
+	return (&Printer{}).Sprint(obj)
+}
+
+// abbreviations are the prefixes of the quote forms printed in the write mode.
+var abbreviations = map[string]string{
+	"quote":            "'",
+	"quasiquote":       "`",
+	"unquote":          ",",
+	"unquote-splicing": ",@",
+}
+
+func (p *state) print(b *strings.Builder, obj types.Object, depth int) {
+	if n, ok := p.labels[obj]; ok {
+		if n >= 0 {
+			fmt.Fprintf(b, "#%d#", n)
+			return
+		}
+		p.labels[obj] = p.next
+		fmt.Fprintf(b, "#%d=", p.next)
+		p.next++
+	}
+	switch o := obj.(type) {
+	case *types.Pair:
+		if p.Mode == Write {
+			if prefix, ok := abbreviation(o); ok && !p.isLabeled(o.Cdr()) {
+				b.WriteString(prefix)
+				p.print(b, o.Cdr().(*types.Pair).Car(), depth)
+				return
+			}
+		}
+		if p.truncateDepth(b, depth) {
+			return
+		}
+		b.WriteByte('(')
+		var rest types.Object = o
+		for i := 0; ; i++ {
+			pair, ok := rest.(*types.Pair)
+			if !ok || (i > 0 && p.isLabeled(pair)) {
+				// a labeled cdr is printed in the dotted notation
+				break
+			}
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if p.truncateLength(b, i) {
+				rest = types.NilObject
+				break
+			}
+			p.print(b, pair.Car(), depth+1)
+			rest = pair.Cdr()
+		}
+		if rest != types.NilObject {
+			b.WriteString(" . ")
+			p.print(b, rest, depth+1)
+		}
+		b.WriteByte(')')
+	case *types.Vector:
+		if p.truncateDepth(b, depth) {
+			return
+		}
+		b.WriteString("#(")
+		p.printElems(b, o.Elems(), depth)
+		b.WriteByte(')')
+	case *types.Bytevector:
+		if p.truncateDepth(b, depth) {
+			return
+		}
+		b.WriteString("#u8(")
+		bytes := o.Bytes()
+		elems := make([]types.Object, len(bytes))
+		for i, c := range bytes {
+			elems[i] = types.Number(c)
+		}
+		p.printElems(b, elems, depth)
+		b.WriteByte(')')
+	case *types.String:
+		if p.Mode == Write {
+			writeQuoted(b, o.String())
+		} else {
+			b.WriteString(o.String())
+		}
+	case types.Char:
+		if p.Mode == Write {
+			b.WriteString(charName(o))
+		} else {
+			b.WriteRune(rune(o))
+		}
+	case types.Number:
+		b.WriteString(formatNumber(o))
+	case *types.Closure:
+		b.WriteString("#<procedure")
+		if name := procedureName(o); name != "" {
+			b.WriteString(" " + name)
+		}
+		b.WriteByte('>')
+	case *types.Record:
+		if p.truncateDepth(b, depth) {
+			return
+		}
+		b.WriteString("#<" + recordTypeName(o.RecordType))
+		for i, field := range o.Fields {
+			b.WriteByte(' ')
+			if p.truncateLength(b, i) {
+				break
+			}
+			b.WriteString(o.RecordType.Fields[i] + ": ")
+			p.print(b, field, depth+1)
+		}
+		b.WriteByte('>')
+	case *types.RecordType:
+		b.WriteString("#<record-type " + recordTypeName(o) + ">")
+	case *types.Values:
+		for i, elem := range o.Objs {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			p.print(b, elem, depth)
+		}
+	case *types.Error:
+		b.WriteString("#<error ")
+		writeQuoted(b, o.Error())
+		b.WriteByte('>')
+	case types.Boolean, *types.Symbol, *types.Nil, *types.EOF, *types.JSONNull:
+		b.WriteString(o.String())
+	default:
+		b.WriteString("#<" + o.String() + ">")
+	}
+}
+
+func (p *state) isLabeled(obj types.Object) bool {
+	_, ok := p.labels[obj]
+	return ok
+}
+
+// printElems prints the elements of a vector or a bytevector separated by spaces.
+func (p *state) printElems(b *strings.Builder, elems []types.Object, depth int) {
+	for i, elem := range elems {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if p.truncateLength(b, i) {
+			return
+		}
+		p.print(b, elem, depth+1)
+	}
+}
+
+// truncateDepth prints "..." if the object at depth is too deep.
+func (p *state) truncateDepth(b *strings.Builder, depth int) bool {
+	if p.MaxDepth > 0 && depth >= p.MaxDepth {
+		b.WriteString("...")
+		return true
+	}
+	return false
+}
+
+// truncateLength prints "..." if the i-th element exceeds the maximum length.
+func (p *state) truncateLength(b *strings.Builder, i int) bool {
+	if p.MaxLength > 0 && i >= p.MaxLength {
+		b.WriteString("...")
+		return true
+	}
+	return false
+}
+
+// labelFinder finds the objects to be labeled.
+type labelFinder struct {
+	shared bool                  // label shared objects, not only cycles
+	seen   map[types.Object]bool // objects already traversed
+	path   map[types.Object]bool // objects enclosing the current one
+	labels map[types.Object]int
+}
+
+func findLabels(obj types.Object, shared bool) map[types.Object]int {
+	f := &labelFinder{
+		shared: shared,
+		seen:   map[types.Object]bool{},
+		path:   map[types.Object]bool{},
+		labels: map[types.Object]int{},
+	}
+	f.walk(obj)
+	return f.labels
+}
+
+func (f *labelFinder) walk(obj types.Object) {
+	var path []types.Object
+	defer func() {
+		for _, o := range path {
+			delete(f.path, o)
+		}
+	}()
+	for {
+		switch obj.(type) {
+		case *types.Pair, *types.Vector, *types.Record:
+		default:
+			return
+		}
+		if f.seen[obj] {
+			if f.shared || f.path[obj] {
+				f.labels[obj] = -1
+			}
+			return
+		}
+		f.seen[obj] = true
+		f.path[obj] = true
+		path = append(path, obj)
+		switch o := obj.(type) {
+		case *types.Pair:
+			f.walk(o.Car())
+			// the cdr is traversed in the loop, keeping the preceding pairs in the path
+			obj = o.Cdr()
+			continue
+		case *types.Vector:
+			for _, elem := range o.Elems() {
+				f.walk(elem)
+			}
+		case *types.Record:
+			for _, field := range o.Fields {
+				f.walk(field)
+			}
+		}
+		return
+	}
+}
+
+// abbreviation returns the prefix if pair is a quote form such as (quote x).
+func abbreviation(pair *types.Pair) (string, bool) {
+	sym, ok := pair.Car().(*types.Symbol)
+	if !ok {
+		return "", false
+	}
+	prefix, ok := abbreviations[sym.Name]
+	if !ok {
+		return "", false
+	}
+	rest, ok := pair.Cdr().(*types.Pair)
+	if !ok || rest.Cdr() != types.NilObject {
+		return "", false
+	}
+	return prefix, true
+}
+
+// stringEscapes are the escape sequences of strings in R7RS.
+var stringEscapes = map[rune]string{
+	'"':  `\"`,
+	'\\': `\\`,
+	'\a': `\a`,
+	'\b': `\b`,
+	'\t': `\t`,
+	'\n': `\n`,
+	'\r': `\r`,
+}
+
+func writeQuoted(b *strings.Builder, str string) {
+	b.WriteByte('"')
+	for _, r := range str {
+		if esc, ok := stringEscapes[r]; ok {
+			b.WriteString(esc)
+		} else if unicode.IsPrint(r) {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(b, `\x%x;`, r)
+		}
+	}
+	b.WriteByte('"')
+}
+
+func charName(c types.Char) string {
+	if name, ok := c.Name(); ok {
+		return `#\` + name
+	}
+	if !unicode.IsPrint(rune(c)) || unicode.IsSpace(rune(c)) {
+		return fmt.Sprintf(`#\x%x`, rune(c))
+	}
+	return `#\` + c.String()
+}
+
+func formatNumber(num types.Number) string {
+	f := float64(num)
+	switch {
+	case math.IsNaN(f):
+		return "+nan.0"
+	case math.IsInf(f, 1):
+		return "+inf.0"
+	case math.IsInf(f, -1):
+		return "-inf.0"
+	}
+	return num.String()
+}
+
+func procedureName(cl *types.Closure) string {
+	if cl.IsGo {
+		return cl.FnName
+	}
+	return cl.Proto.Name
+}
+
+// recordTypeName strips the angle brackets of the conventional type name such as <point>.
+func recordTypeName(rt *types.RecordType) string {
+	name := rt.Name
+	if len(name) > 2 && strings.HasPrefix(name, "<") && strings.HasSuffix(name, ">") {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,128 @@
+package printer
+
+import (
+	"bytes"
+	"github.com/hyusuk/tama/types"
+	"math"
+	"testing"
+)
+
+func sym(name string) types.Object {
+	return types.NewSymbol(name)
+}
+
+func TestPrint(t *testing.T) {
+	rt := types.NewRecordType("<point>", []string{"x", "y"})
+	tcases := []struct {
+		obj     types.Object
+		write   string
+		display string
+	}{
+		{types.List(types.Number(1), types.Number(2)), "(1 2)", "(1 2)"},
+		{types.ListWithTail(types.Number(3), types.Number(1), types.Number(2)), "(1 2 . 3)", "(1 2 . 3)"},
+		{types.NilObject, "()", "()"},
+		{types.List(sym("quote"), sym("x")), "'x", "(quote x)"},
+		{types.List(sym("quasiquote"), types.List(sym("unquote"), sym("x"), sym("y"))), "`(unquote x y)", "(quasiquote (unquote x y))"},
+		{types.List(sym("unquote-splicing"), types.List(sym("quote"), types.NilObject)), ",@'()", "(unquote-splicing (quote ()))"},
+		{types.NewString("a\"b\\c\nd\x01"), `"a\"b\\c\nd\x1;"`, "a\"b\\c\nd\x01"},
+		{types.Char('a'), `#\a`, "a"},
+		{types.Char(' '), `#\space`, " "},
+		{types.Char(0x3000), `#\x3000`, "　"},
+		{types.NewVector([]types.Object{types.Number(1), types.NewString("s")}), `#(1 "s")`, "#(1 s)"},
+		{types.NewBytevector([]byte{0, 255}), "#u8(0 255)", "#u8(0 255)"},
+		{types.Number(math.Inf(-1)), "-inf.0", "-inf.0"},
+		{types.Number(math.NaN()), "+nan.0", "+nan.0"},
+		{types.Number(1.5), "1.5", "1.5"},
+		{types.Boolean(false), "#f", "#f"},
+		{types.NewGoClosure("car", 1, 1, nil), "#<procedure car>", "#<procedure car>"},
+		{&types.Closure{Proto: types.NewClosureProto()}, "#<procedure>", "#<procedure>"},
+		{types.NewRecord(rt, []types.Object{types.Number(1), types.NewString("a")}), `#<point x: 1 y: "a">`, "#<point x: 1 y: a>"},
+		{rt, "#<record-type point>", "#<record-type point>"},
+		{types.EOFObject, "#<eof>", "#<eof>"},
+		{types.NewHashTable(nil, nil), "#<hash-table>", "#<hash-table>"},
+	}
+	for i, tc := range tcases {
+		if actual := WriteString(tc.obj); actual != tc.write {
+			t.Fatalf("case %d: expected %s for write, but got %s", i, tc.write, actual)
+		}
+		if actual := DisplayString(tc.obj); actual != tc.display {
+			t.Fatalf("case %d: expected %s for display, but got %s", i, tc.display, actual)
+		}
+	}
+}
+
+func TestPrintTruncate(t *testing.T) {
+	nums := func(n int) []types.Object {
+		objs := make([]types.Object, n)
+		for i := range objs {
+			objs[i] = types.Number(i)
+		}
+		return objs
+	}
+	nested := types.List(types.Number(1), types.List(types.Number(2), types.List(types.Number(3))))
+	tcases := []struct {
+		p      *Printer
+		obj    types.Object
+		expect string
+	}{
+		{&Printer{MaxLength: 3}, types.List(nums(5)...), "(0 1 2 ...)"},
+		{&Printer{MaxLength: 3}, types.List(nums(3)...), "(0 1 2)"},
+		{&Printer{MaxLength: 2}, types.ListWithTail(types.Number(9), nums(3)...), "(0 1 ...)"},
+		{&Printer{MaxLength: 2}, types.NewVector(nums(4)), "#(0 1 ...)"},
+		{&Printer{MaxLength: 1}, types.NewBytevector([]byte{1, 2}), "#u8(1 ...)"},
+		{&Printer{MaxDepth: 2}, nested, "(1 (2 ...))"},
+		{&Printer{MaxDepth: 1}, types.NewVector([]types.Object{nested}), "#(...)"},
+		{&Printer{Mode: Write, MaxDepth: 1}, types.List(sym("quote"), nested), "'(1 ...)"},
+	}
+	for i, tc := range tcases {
+		if actual := tc.p.Sprint(tc.obj); actual != tc.expect {
+			t.Fatalf("case %d: expected %s, but got %s", i, tc.expect, actual)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	var b bytes.Buffer
+	p := &Printer{Mode: Write}
+	if err := p.Fprint(&b, types.List(types.NewString("a"))); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `("a")` {
+		t.Fatalf("expected %s, but got %s", `("a")`, b.String())
+	}
+}
+
+func TestPrintLabels(t *testing.T) {
+	cycle := types.List(sym("a"), sym("b")).(*types.Pair)
+	cycle.Cdr().(*types.Pair).SetCdr(cycle)
+	carCycle := types.Cons(types.NilObject, types.NilObject)
+	carCycle.SetCar(carCycle)
+	shared := types.List(sym("a"))
+	vec := types.NewVector([]types.Object{types.Number(1), types.NilObject})
+	vec.Set(1, vec)
+	quoted := types.List(sym("x"))
+	quoted.(*types.Pair).SetCdr(quoted)
+	write := &Printer{Mode: Write}
+	writeShared := &Printer{Mode: Write, Labels: LabelShared}
+	tcases := []struct {
+		p      *Printer
+		obj    types.Object
+		expect string
+	}{
+		{write, cycle, "#0=(a b . #0#)"},
+		{write, carCycle, "#0=(#0#)"},
+		{write, types.List(shared, shared), "((a) (a))"},
+		{writeShared, types.List(shared, shared), "(#0=(a) #0#)"},
+		{writeShared, types.List(cycle, shared, shared), "(#0=(a b . #0#) #1=(a) #1#)"},
+		{write, vec, "#0=#(1 #0#)"},
+		{write, types.List(sym("quote"), cycle), "'#0=(a b . #0#)"},
+		{write, types.Cons(sym("quote"), quoted), "(quote . #0=(x . #0#))"},
+		{&Printer{}, types.List(types.NewString("s"), cycle), "(s #0=(a b . #0#))"},
+		{&Printer{Mode: Write, Labels: LabelNone, MaxLength: 3}, cycle, "(a b a ...)"},
+	}
+	for i, tc := range tcases {
+		if actual := tc.p.Sprint(tc.obj); actual != tc.expect {
+			t.Fatalf("case %d: expected %s, but got %s", i, tc.expect, actual)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package tama
+
+import (
+	"fmt"
+	"github.com/hyusuk/tama/types"
+	"sort"
+)
+
+// ExitError is returned to Go when the program calls exit or emergency-exit.
+// The process itself is never terminated.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// OpenProcessContext registers the process context library.
+// The command line and the environment variables are given by Option.Args and Option.Env.
+func (s *State) OpenProcessContext() *State {
+	s.RegisterFunc("command-line", 0, 0, fnCommandLine)
+	s.RegisterFunc("get-environment-variable", 1, 1, fnGetEnvironmentVariable)
+	s.RegisterFunc("get-environment-variables", 0, 0, fnGetEnvironmentVariables)
+	s.RegisterFunc("exit", 0, 1, fnExit)
+	s.RegisterFunc("emergency-exit", 0, 1, fnEmergencyExit)
+	return s
+}
+
+func fnCommandLine(s *State, args []types.Object) (types.Object, error) {
+	objs := make([]types.Object, len(s.args))
+	for i, arg := range s.args {
+		objs[i] = types.NewString(arg)
+	}
+	return types.List(objs...), nil
+}
+
+// (get-environment-variable name)
+// It returns #f if the variable is not defined.
+func fnGetEnvironmentVariable(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	v, ok := s.env[args[0].(*types.String).String()]
+	if !ok {
+		return types.Boolean(false), nil
+	}
+	return types.NewString(v), nil
+}
+
+// (get-environment-variables)
+// It returns an alist of the names and the values sorted by the names.
+func fnGetEnvironmentVariables(s *State, args []types.Object) (types.Object, error) {
+	names := make([]string, 0, len(s.env))
+	for name := range s.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	alist := make([]types.Object, len(names))
+	for i, name := range names {
+		alist[i] = types.Cons(types.NewString(name), types.NewString(s.env[name]))
+	}
+	return types.List(alist...), nil
+}
+
+// toExitCode converts the optional argument of exit to the status code.
+// #t or no argument means success, and #f means failure.
+func toExitCode(args []types.Object) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if b, ok := args[0].(types.Boolean); ok {
+		if b {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	code, err := toInteger(args[0])
+	if err != nil {
+		return 0, types.NewTypeError("boolean or integer required, but got %v", args[0])
+	}
+	return int(code), nil
+}
+
+// (exit [obj])
+// The after thunks of the active dynamic-winds are run before exiting.
+func fnExit(s *State, args []types.Object) (types.Object, error) {
+	code, err := toExitCode(args)
+	if err != nil {
+		return nil, err
+	}
+	for len(s.winders) > 0 {
+		after := s.winders[len(s.winders)-1]
+		s.winders = s.winders[:len(s.winders)-1]
+		if _, err := s.Call(after); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &ExitError{Code: code}
+}
+
+// (emergency-exit [obj])
+// Unlike exit, no after thunks are run.
+func fnEmergencyExit(s *State, args []types.Object) (types.Object, error) {
+	code, err := toExitCode(args)
+	if err != nil {
+		return nil, err
+	}
+	s.winders = nil
+	return nil, &ExitError{Code: code}
+}
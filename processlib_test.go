@@ -0,0 +1,62 @@
+package tama
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProcessContext(t *testing.T) {
+	option := Option{
+		Args: []string{"script.scm", "-v", "input"},
+		Env:  map[string]string{"HOME": "/home/tama", "LANG": "C"},
+	}
+	tcases := []*tcase{
+		&tcase{src: `(command-line)`, expect: "(script.scm -v input)", option: option},
+		&tcase{src: `(command-line)`, expect: "()"},
+		&tcase{src: `(get-environment-variable "HOME")`, expect: "/home/tama", option: option},
+		&tcase{src: `(get-environment-variable "PATH")`, expect: "#f", option: option},
+		&tcase{src: `(get-environment-variables)`, expect: "((HOME . /home/tama) (LANG . C))", option: option},
+		&tcase{src: `(get-environment-variables)`, expect: "()"},
+		&tcase{src: `(get-environment-variable 'HOME)`, expectErr: true, option: option},
+	}
+	testTcases(t, tcases, (*State).OpenProcessContext)
+}
+
+func TestExit(t *testing.T) {
+	for _, tc := range []struct {
+		src    string
+		code   int
+		output string
+	}{
+		{`(display "a") (exit) (display "b")`, 0, "a"},
+		{`(exit 3)`, 3, ""},
+		{`(exit #t)`, 0, ""},
+		{`(exit #f)`, 1, ""},
+		{`(dynamic-wind (lambda () (display "[")) (lambda () (dynamic-wind (lambda () (display "(")) (lambda () (exit 2)) (lambda () (display ")")))) (lambda () (display "]")))`, 2, "[()]"},
+		{`(dynamic-wind (lambda () 1) (lambda () (emergency-exit 4)) (lambda () (display "after")))`, 4, ""},
+		{`(for-each (lambda (x) (if (= x 2) (exit x) (display x))) '(1 2 3))`, 2, "1"},
+		{`(dynamic-wind (lambda () 1) (lambda () (exit 1)) (lambda () (exit 5)))`, 5, ""},
+	} {
+		var b strings.Builder
+		s := NewState(Option{Stdout: &b}).OpenProcessContext()
+		err := s.ExecString(tc.src)
+		var e *ExitError
+		if !errors.As(err, &e) {
+			t.Fatalf("expected exit error, but got %v\nsrc: %s", err, tc.src)
+		}
+		if e.Code != tc.code {
+			t.Fatalf("expected exit status %d, but got %d\nsrc: %s", tc.code, e.Code, tc.src)
+		}
+		if b.String() != tc.output {
+			t.Fatalf("expected output %q, but got %q\nsrc: %s", tc.output, b.String(), tc.src)
+		}
+		if len(s.winders) != 0 {
+			t.Fatalf("winders remain after exit\nsrc: %s", tc.src)
+		}
+	}
+	s := NewState(Option{}).OpenProcessContext()
+	if err := s.ExecString(`(exit "x")`); err == nil || errors.As(err, new(*ExitError)) {
+		t.Fatalf("expected type error, but got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"math/rand/v2"
+)
+
+// OpenRandom registers the random number library. (SRFI-27)
+//
+// Each state has its own default-random-source seeded by Option.RandomSeed.
+// Random sources are PCG generators, and their states are bytevectors.
+func (s *State) OpenRandom() *State {
+	s.SetGlobal("default-random-source", s.random)
+	s.RegisterFunc("random-integer", 1, 1, fnRandomInteger)
+	s.RegisterFunc("random-real", 0, 0, fnRandomReal)
+	s.RegisterFunc("make-random-source", 0, 0, fnMakeRandomSource)
+	s.RegisterFunc("random-source?", 1, 1, fnIsRandomSource)
+	s.RegisterFunc("random-source-state-ref", 1, 1, fnRandomSourceStateRef)
+	s.RegisterFunc("random-source-state-set!", 2, 2, fnRandomSourceStateSet)
+	s.RegisterFunc("random-source-randomize!", 1, 1, fnRandomSourceRandomize)
+	s.RegisterFunc("random-source-pseudo-randomize!", 3, 3, fnRandomSourcePseudoRandomize)
+	s.RegisterFunc("random-source-make-integers", 1, 1, fnRandomSourceMakeIntegers)
+	s.RegisterFunc("random-source-make-reals", 1, 2, fnRandomSourceMakeReals)
+	return s
+}
+
+func toRandomSource(obj types.Object) (*types.RandomSource, error) {
+	if err := types.AssertType(types.TyRandomSource, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.RandomSource), nil
+}
+
+// randomInteger returns an integer in [0, n) from src.
+// n must be less than 2^53, since 2^53 + 1 cannot be told from 2^53.
+func randomInteger(src *types.RandomSource, obj types.Object) (types.Object, error) {
+	n, err := toInteger(obj)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || n >= 1<<53 {
+		return nil, types.NewTypeError("integer in [1, 2^53) required, but got %v", obj)
+	}
+	return types.Number(src.Uint64N(uint64(n))), nil
+}
+
+// randomReal returns a real number in the open interval (0, 1) from src.
+func randomReal(src *types.RandomSource) types.Object {
+	for {
+		if x := src.Float64(); x != 0 {
+			return types.Number(x)
+		}
+	}
+}
+
+// (random-integer n)
+func fnRandomInteger(s *State, args []types.Object) (types.Object, error) {
+	return randomInteger(s.random, args[0])
+}
+
+// (random-real)
+func fnRandomReal(s *State, args []types.Object) (types.Object, error) {
+	return randomReal(s.random), nil
+}
+
+// (make-random-source)
+// All the new sources are in the same state.
+func fnMakeRandomSource(s *State, args []types.Object) (types.Object, error) {
+	return types.NewRandomSource(0, 0), nil
+}
+
+func fnIsRandomSource(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyRandomSource), nil
+}
+
+// (random-source-state-ref source)
+func fnRandomSourceStateRef(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBytevector(src.State()), nil
+}
+
+// (random-source-state-set! source state)
+func fnRandomSourceStateSet(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyBytevector, args[1]); err != nil {
+		return nil, err
+	}
+	if err := src.SetState(args[1].(*types.Bytevector).Bytes()); err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// (random-source-randomize! source)
+func fnRandomSourceRandomize(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	src.Seed(rand.Uint64(), rand.Uint64())
+	return types.UndefinedObject, nil
+}
+
+// (random-source-pseudo-randomize! source i j)
+// The state is determined by the non-negative integers i and j.
+func fnRandomSourcePseudoRandomize(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var seeds [2]uint64
+	for k, arg := range args[1:] {
+		n, err := toInteger(arg)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, types.NewTypeError("non-negative integer required, but got %v", arg)
+		}
+		seeds[k] = uint64(n)
+	}
+	src.Seed(seeds[0], seeds[1])
+	return types.UndefinedObject, nil
+}
+
+// (random-source-make-integers source)
+// It returns a procedure like random-integer drawing from source.
+func fnRandomSourceMakeIntegers(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	fn := func(s *State, args []types.Object) (types.Object, error) {
+		return randomInteger(src, args[0])
+	}
+	return types.NewGoClosure("random-integer", 1, 1, fn), nil
+}
+
+// (random-source-make-reals source [unit])
+// It returns a procedure like random-real drawing from source.
+// unit must be in (0, 1), but the numbers are always as fine as the float64 allows.
+func fnRandomSourceMakeReals(s *State, args []types.Object) (types.Object, error) {
+	src, err := toRandomSource(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		if err := types.AssertType(types.TyNumber, args[1]); err != nil {
+			return nil, err
+		}
+		if unit := args[1].(types.Number); unit <= 0 || unit >= 1 {
+			return nil, types.NewTypeError("unit must be in (0, 1), but got %v", args[1])
+		}
+	}
+	fn := func(s *State, args []types.Object) (types.Object, error) {
+		return randomReal(src), nil
+	}
+	return types.NewGoClosure("random-real", 0, 0, fn), nil
+}
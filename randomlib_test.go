@@ -0,0 +1,47 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestRandom(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define x (random-integer 10)) (list (<= 0 x) (< x 10) (exact-integer? x))`, expect: "(#t #t #t)"},
+		&tcase{src: `(random-integer 1)`, expect: "0"},
+		&tcase{src: `(define x (random-real)) (list (< 0 x) (< x 1))`, expect: "(#t #t)"},
+		&tcase{src: `(random-source? default-random-source)`, expect: "#t"},
+		&tcase{src: `(random-source? 1)`, expect: "#f"},
+		&tcase{src: `(define s (random-source-state-ref default-random-source)) (define x (random-integer 1000000)) (random-source-state-set! default-random-source s) (= x (random-integer 1000000))`, expect: "#t"},
+		&tcase{src: `(define (draw) ((random-source-make-integers (make-random-source)) 1000000)) (= (draw) (draw))`, expect: "#t"},
+		&tcase{src: `(define a (make-random-source)) (define b (make-random-source)) (random-source-pseudo-randomize! a 1 2) (random-source-pseudo-randomize! b 1 2) (= ((random-source-make-reals a)) ((random-source-make-reals b 0.5)))`, expect: "#t"},
+		&tcase{src: `(define a (make-random-source)) (random-source-randomize! a) (define r (random-source-make-integers a)) (< (r 10) 10)`, expect: "#t"},
+		&tcase{src: `(random-integer 0)`, expectErr: true},
+		&tcase{src: `(random-integer 1.5)`, expectErr: true},
+		&tcase{src: `(< (random-integer (- (expt 2 53) 1)) (expt 2 53))`, expect: "#t"},
+		&tcase{src: `(random-integer (expt 2 53))`, expectErr: true},
+		&tcase{src: `(random-integer (+ 1 (expt 2 53)))`, expectErr: true},
+		&tcase{src: `(random-source-make-reals (make-random-source) 1)`, expectErr: true},
+		&tcase{src: `(random-source-pseudo-randomize! (make-random-source) -1 0)`, expectErr: true},
+		&tcase{src: `(random-source-state-set! (make-random-source) (bytevector 1 2))`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenRandom, (*State).OpenMath, (*State).OpenBytevector)
+}
+
+func TestRandomSeed(t *testing.T) {
+	draw := func(seed uint64) string {
+		s := NewState(Option{RandomSeed: &seed}).OpenRandom()
+		if err := s.ExecString(`(list (random-integer 1000000) (random-integer 1000000) (random-real))`); err != nil {
+			t.Fatal(err)
+		}
+		return s.CallStack.Top().String()
+	}
+	if a, b := draw(42), draw(42); a != b {
+		t.Fatalf("the same seed gives different numbers: %s and %s", a, b)
+	}
+	if a, b := draw(0), draw(0); a != b {
+		t.Fatalf("the seed 0 gives different numbers: %s and %s", a, b)
+	}
+	if a, b := draw(42), draw(43); a == b {
+		t.Fatalf("different seeds give the same numbers: %s", a)
+	}
+}
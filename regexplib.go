@@ -0,0 +1,626 @@
+package tama
+
+import (
+	"fmt"
+	"github.com/hyusuk/tama/types"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// OpenRegexp registers the regular expression library. (SRFI-115)
+//
+// Regular expressions are backed by go regexp, so the RE2 syntax is used.
+// A pattern is either a string in the RE2 syntax or a SRE (e.g. '(+ digit)).
+// Note that strings inside a SRE are matched literally.
+// Since RE2 has no look-around assertions, bow and eow match any word boundary,
+// so they do not distinguish the start of a word from its end.
+func (s *State) OpenRegexp() *State {
+	s.RegisterFunc("regexp", 1, 1, fnRegexp)
+	s.RegisterFunc("regexp?", 1, 1, fnIsRegexp)
+	s.RegisterFunc("regexp-matches", 2, 4, genFnRegexpMatches(false))
+	s.RegisterFunc("regexp-matches?", 2, 4, genFnRegexpMatches(true))
+	s.RegisterFunc("regexp-search", 2, 4, fnRegexpSearch)
+	s.RegisterFunc("regexp-replace", 3, 6, fnRegexpReplace)
+	s.RegisterFunc("regexp-replace-all", 3, 5, fnRegexpReplaceAll)
+	s.RegisterFunc("regexp-extract", 2, 4, fnRegexpExtract)
+	s.RegisterFunc("regexp-split", 2, 4, fnRegexpSplit)
+	s.RegisterFunc("regexp-match?", 1, 1, fnIsRegexpMatch)
+	s.RegisterFunc("regexp-match-count", 1, 1, fnRegexpMatchCount)
+	s.RegisterFunc("regexp-match-submatch", 2, 2, fnRegexpMatchSubmatch)
+	s.RegisterFunc("regexp-match-submatch-start", 2, 2, genFnRegexpMatchBound(true))
+	s.RegisterFunc("regexp-match-submatch-end", 2, 2, genFnRegexpMatchBound(false))
+	s.RegisterFunc("regexp-match->list", 1, 1, fnRegexpMatchToList)
+	return s
+}
+
+// toRegexp compiles the pattern obj unless it is already a regexp.
+func toRegexp(obj types.Object) (*types.Regexp, error) {
+	var src string
+	switch o := obj.(type) {
+	case *types.Regexp:
+		return o, nil
+	case *types.String:
+		src = o.String()
+	default:
+		var err error
+		if src, err = sreToRE2(obj); err != nil {
+			return nil, err
+		}
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil, types.NewInternalError("invalid regexp: %v", err)
+	}
+	return types.NewRegexp(re), nil
+}
+
+// sreClasses is the RE2 class bodies of the named character sets of SRE.
+var sreClasses = map[string]string{
+	"alphabetic":   `\p{L}`,
+	"alpha":        `\p{L}`,
+	"numeric":      `\p{Nd}`,
+	"num":          `\p{Nd}`,
+	"digit":        `\p{Nd}`,
+	"alphanumeric": `\p{L}\p{Nd}`,
+	"alnum":        `\p{L}\p{Nd}`,
+	"alphanum":     `\p{L}\p{Nd}`,
+	"whitespace":   `\s\p{Z}`,
+	"white":        `\s\p{Z}`,
+	"space":        `\s\p{Z}`,
+	"upper-case":   `\p{Lu}`,
+	"upper":        `\p{Lu}`,
+	"lower-case":   `\p{Ll}`,
+	"lower":        `\p{Ll}`,
+	"punctuation":  `\p{P}`,
+	"punct":        `\p{P}`,
+	"symbol":       `\p{S}`,
+	"control":      `\p{Cc}`,
+	"cntrl":        `\p{Cc}`,
+	"hex-digit":    `0-9A-Fa-f`,
+	"xdigit":       `0-9A-Fa-f`,
+	"ascii":        `\x00-\x7f`,
+}
+
+// sreAssertions is the RE2 syntax of the SRE symbols which are not character sets.
+var sreAssertions = map[string]string{
+	"any":  `(?s:.)`,
+	"nonl": `.`,
+	"bos":  `\A`,
+	"eos":  `\z`,
+	"bol":  `(?m:^)`,
+	"eol":  `(?m:$)`,
+	"bow":  `\b`, // RE2 cannot tell the start of a word from the end
+	"eow":  `\b`,
+	"nwb":  `\B`,
+	"word": `\b[\p{L}\p{Nd}_]+\b`,
+}
+
+// sreToRE2 converts the SRE sre to the RE2 syntax.
+func sreToRE2(sre types.Object) (string, error) {
+	switch o := sre.(type) {
+	case *types.String:
+		return regexp.QuoteMeta(o.String()), nil
+	case types.Char:
+		return regexp.QuoteMeta(o.String()), nil
+	case *types.Symbol:
+		if re, ok := sreAssertions[o.Name]; ok {
+			return re, nil
+		}
+		if class, ok := sreClasses[o.Name]; ok {
+			return "[" + class + "]", nil
+		}
+		return "", types.NewSyntaxError("unknown SRE %v", o)
+	case *types.Pair:
+		elems, err := toSlice(o)
+		if err != nil {
+			return "", types.NewSyntaxError("invalid SRE %v", o)
+		}
+		if _, ok := elems[0].(*types.String); ok && len(elems) == 1 {
+			// ("abc") is the set of the characters
+			class, err := sreClassBody(o)
+			return sreBracket("", class, o, err)
+		}
+		head, ok := elems[0].(*types.Symbol)
+		if !ok {
+			return "", types.NewSyntaxError("invalid SRE %v", o)
+		}
+		return sreFormToRE2(head.Name, elems[1:], o)
+	}
+	return "", types.NewSyntaxError("invalid SRE %v", sre)
+}
+
+// sreSeq converts the SREs to the RE2 syntax of their concatenation.
+func sreSeq(sres []types.Object) (string, error) {
+	var b strings.Builder
+	for _, sre := range sres {
+		re, err := sreToRE2(sre)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(re)
+	}
+	return b.String(), nil
+}
+
+// sreCount converts the repetition count obj of the SRE form.
+func sreCount(obj types.Object, form types.Object) (int, error) {
+	n, err := toIndex(obj, 1000) // the maximum repeat count of RE2
+	if err != nil {
+		return 0, types.NewSyntaxError("invalid repetition count in %v", form)
+	}
+	return n, nil
+}
+
+func sreFormToRE2(name string, args []types.Object, form types.Object) (string, error) {
+	switch name {
+	case ":", "seq":
+		return sreSeq(args)
+	case "or":
+		if len(args) == 0 {
+			return `[^\x00-\x{10FFFF}]`, nil // never matches
+		}
+		alts := make([]string, len(args))
+		for i, arg := range args {
+			re, err := sreToRE2(arg)
+			if err != nil {
+				return "", err
+			}
+			alts[i] = re
+		}
+		return "(?:" + strings.Join(alts, "|") + ")", nil
+	case "*", "+", "?", "*?", "+?", "??":
+		re, err := sreSeq(args)
+		if err != nil {
+			return "", err
+		}
+		return "(?:" + re + ")" + name, nil
+	case "=", ">=":
+		if len(args) < 1 {
+			return "", types.NewSyntaxError("invalid SRE %v", form)
+		}
+		n, err := sreCount(args[0], form)
+		if err != nil {
+			return "", err
+		}
+		re, err := sreSeq(args[1:])
+		if err != nil {
+			return "", err
+		}
+		if name == "=" {
+			return fmt.Sprintf("(?:%s){%d}", re, n), nil
+		}
+		return fmt.Sprintf("(?:%s){%d,}", re, n), nil
+	case "**", "**?":
+		if len(args) < 2 {
+			return "", types.NewSyntaxError("invalid SRE %v", form)
+		}
+		n, err := sreCount(args[0], form)
+		if err != nil {
+			return "", err
+		}
+		m, err := sreCount(args[1], form)
+		if err != nil {
+			return "", err
+		}
+		re, err := sreSeq(args[2:])
+		if err != nil {
+			return "", err
+		}
+		lazy := ""
+		if name == "**?" {
+			lazy = "?"
+		}
+		return fmt.Sprintf("(?:%s){%d,%d}%s", re, n, m, lazy), nil
+	case "submatch", "$":
+		re, err := sreSeq(args)
+		return "(" + re + ")", err
+	case "submatch-named", "->":
+		if len(args) < 1 {
+			return "", types.NewSyntaxError("invalid SRE %v", form)
+		}
+		sym, ok := args[0].(*types.Symbol)
+		if !ok {
+			return "", types.NewSyntaxError("submatch name must be a symbol in %v", form)
+		}
+		re, err := sreSeq(args[1:])
+		return "(?P<" + sym.Name + ">" + re + ")", err
+	case "w/nocase":
+		re, err := sreSeq(args)
+		return "(?i:" + re + ")", err
+	case "w/case":
+		re, err := sreSeq(args)
+		return "(?-i:" + re + ")", err
+	case "char-set", "/", "char-range":
+		class, err := sreClassBody(form)
+		return sreBracket("", class, form, err)
+	case "~", "complement":
+		class, err := sreClassBodies(args)
+		return sreBracket("^", class, form, err)
+	}
+	return "", types.NewSyntaxError("unsupported SRE %v", form)
+}
+
+// sreBracket encloses the class body in brackets.
+// The empty set is rejected since RE2 has no syntax for it.
+func sreBracket(negate, class string, form types.Object, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if class == "" {
+		return "", types.NewSyntaxError("empty character set in %v", form)
+	}
+	return "[" + negate + class + "]", nil
+}
+
+// sreClassBody converts the character set SRE cset to the body of a RE2 character class.
+func sreClassBody(cset types.Object) (string, error) {
+	switch o := cset.(type) {
+	case *types.String:
+		return quoteClass(o.String()), nil
+	case types.Char:
+		return quoteClass(o.String()), nil
+	case *types.Symbol:
+		if class, ok := sreClasses[o.Name]; ok {
+			return class, nil
+		}
+	case *types.Pair:
+		elems, err := toSlice(o)
+		if err != nil {
+			break
+		}
+		if str, ok := elems[0].(*types.String); ok && len(elems) == 1 {
+			return quoteClass(str.String()), nil
+		}
+		head, ok := elems[0].(*types.Symbol)
+		if !ok {
+			break
+		}
+		switch head.Name {
+		case "or", "char-set":
+			return sreClassBodies(elems[1:])
+		case "/", "char-range":
+			return sreRanges(elems[1:], o)
+		}
+	}
+	return "", types.NewSyntaxError("invalid character set %v", cset)
+}
+
+func sreClassBodies(csets []types.Object) (string, error) {
+	var b strings.Builder
+	for _, cset := range csets {
+		class, err := sreClassBody(cset)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(class)
+	}
+	return b.String(), nil
+}
+
+// sreRanges converts the arguments of (/ "az" #\0 #\9) to RE2 ranges.
+func sreRanges(args []types.Object, form types.Object) (string, error) {
+	chars := []rune{}
+	for _, arg := range args {
+		switch o := arg.(type) {
+		case *types.String:
+			chars = append(chars, []rune(o.String())...)
+		case types.Char:
+			chars = append(chars, rune(o))
+		default:
+			return "", types.NewSyntaxError("invalid character range %v", form)
+		}
+	}
+	if len(chars)%2 != 0 {
+		return "", types.NewSyntaxError("odd number of characters in range %v", form)
+	}
+	var b strings.Builder
+	for i := 0; i < len(chars); i += 2 {
+		b.WriteString(quoteClass(string(chars[i])) + "-" + quoteClass(string(chars[i+1])))
+	}
+	return b.String(), nil
+}
+
+// quoteClass escapes the characters of str to be used in a RE2 character class.
+func quoteClass(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if r < 0x80 && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			fmt.Fprintf(&b, `\x{%x}`, r)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func fnRegexp(s *State, args []types.Object) (types.Object, error) {
+	return toRegexp(args[0])
+}
+
+func fnIsRegexp(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyRegexp), nil
+}
+
+func fnIsRegexpMatch(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyRegexpMatch), nil
+}
+
+// regexpTarget is the string searched by the regexp procedures.
+type regexpTarget struct {
+	str    *types.String
+	start  int
+	end    int
+	target string // the characters between start and end
+}
+
+// toRegexpTarget converts the arguments (re str [start [end]]).
+func toRegexpTarget(args []types.Object) (*types.Regexp, *regexpTarget, error) {
+	re, err := toRegexp(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := types.AssertType(types.TyString, args[1]); err != nil {
+		return nil, nil, err
+	}
+	str := args[1].(*types.String)
+	start, end, err := toRange(args[2:], str.Len())
+	if err != nil {
+		return nil, nil, err
+	}
+	return re, &regexpTarget{str: str, start: start, end: end, target: str.Substring(start, end)}, nil
+}
+
+// genFnRegexpMatches generates (regexp-matches re str [start [end]]) and regexp-matches?.
+func genFnRegexpMatches(pred bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		re, t, err := toRegexpTarget(args)
+		if err != nil {
+			return nil, err
+		}
+		anchored := re.Anchored()
+		indices := anchored.FindStringSubmatchIndex(t.target)
+		if pred || indices == nil {
+			return types.Boolean(indices != nil), nil
+		}
+		return types.NewRegexpMatch(anchored, t.target, indices, t.start), nil
+	}
+}
+
+// (regexp-search re str [start [end]])
+func fnRegexpSearch(s *State, args []types.Object) (types.Object, error) {
+	re, t, err := toRegexpTarget(args)
+	if err != nil {
+		return nil, err
+	}
+	indices := re.Regexp().FindStringSubmatchIndex(t.target)
+	if indices == nil {
+		return types.Boolean(false), nil
+	}
+	return types.NewRegexpMatch(re.Regexp(), t.target, indices, t.start), nil
+}
+
+// substitute returns the replacement of the match m.
+// subst is a string, a submatch index, a submatch name, 'pre, 'post, a list of them,
+// or a procedure which is called with the match and returns a string.
+func (s *State) substitute(m *types.RegexpMatch, subst types.Object) (string, error) {
+	switch o := subst.(type) {
+	case *types.String:
+		return o.String(), nil
+	case *types.Symbol:
+		switch o.Name {
+		case "pre":
+			return m.Prefix(), nil
+		case "post":
+			return m.Suffix(), nil
+		}
+	case *types.Closure:
+		v, err := s.Call(o, m)
+		if err != nil {
+			return "", err
+		}
+		if err := types.AssertType(types.TyString, v); err != nil {
+			return "", err
+		}
+		return v.(*types.String).String(), nil
+	case *types.Pair, *types.Nil:
+		elems, err := toSlice(o)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		for _, elem := range elems {
+			str, err := s.substitute(m, elem)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(str)
+		}
+		return b.String(), nil
+	}
+	i, err := submatchIndex(m, subst)
+	if err != nil {
+		return "", err
+	}
+	sub, _ := m.Submatch(i)
+	return sub, nil
+}
+
+// replace replaces the matches of re in t with subst.
+// Only the count-th match is replaced unless count is negative.
+func (s *State) replace(re *types.Regexp, t *regexpTarget, subst types.Object, count int) (types.Object, error) {
+	var b strings.Builder
+	b.WriteString(t.str.Substring(0, t.start))
+	last := 0
+	all := re.Regexp().FindAllStringSubmatchIndex(t.target, -1)
+	for i, indices := range all {
+		if count >= 0 && i != count {
+			continue
+		}
+		m := types.NewRegexpMatch(re.Regexp(), t.target, indices, t.start)
+		str, err := s.substitute(m, subst)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(t.target[last:indices[0]])
+		b.WriteString(str)
+		last = indices[1]
+	}
+	b.WriteString(t.target[last:])
+	b.WriteString(t.str.Substring(t.end, t.str.Len()))
+	return types.NewString(b.String()), nil
+}
+
+// (regexp-replace re str subst [start [end [count]]])
+// Replaces the count-th match (0 by default).
+func fnRegexpReplace(s *State, args []types.Object) (types.Object, error) {
+	bounds := args[3:]
+	if len(bounds) > 2 {
+		bounds = bounds[:2]
+	}
+	re, t, err := toRegexpTarget(append([]types.Object{args[0], args[1]}, bounds...))
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	if len(args) > 5 {
+		if count, err = toIndex(args[5], math.MaxInt32); err != nil {
+			return nil, err
+		}
+	}
+	return s.replace(re, t, args[2], count)
+}
+
+// (regexp-replace-all re str subst [start [end]])
+func fnRegexpReplaceAll(s *State, args []types.Object) (types.Object, error) {
+	re, t, err := toRegexpTarget(append([]types.Object{args[0], args[1]}, args[3:]...))
+	if err != nil {
+		return nil, err
+	}
+	return s.replace(re, t, args[2], -1)
+}
+
+// (regexp-extract re str [start [end]])
+// Returns the list of the non-empty matches.
+func fnRegexpExtract(s *State, args []types.Object) (types.Object, error) {
+	re, t, err := toRegexpTarget(args)
+	if err != nil {
+		return nil, err
+	}
+	strs := []types.Object{}
+	for _, str := range re.Regexp().FindAllString(t.target, -1) {
+		if str != "" {
+			strs = append(strs, types.NewString(str))
+		}
+	}
+	return types.List(strs...), nil
+}
+
+// (regexp-split re str [start [end]])
+// Returns the list of the strings separated by the matches.
+func fnRegexpSplit(s *State, args []types.Object) (types.Object, error) {
+	re, t, err := toRegexpTarget(args)
+	if err != nil {
+		return nil, err
+	}
+	strs := []types.Object{}
+	for _, str := range re.Regexp().Split(t.target, -1) {
+		strs = append(strs, types.NewString(str))
+	}
+	return types.List(strs...), nil
+}
+
+func toRegexpMatch(obj types.Object) (*types.RegexpMatch, error) {
+	if err := types.AssertType(types.TyRegexpMatch, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.RegexpMatch), nil
+}
+
+// submatchIndex converts field, the index or the name of a submatch, to the index.
+func submatchIndex(m *types.RegexpMatch, field types.Object) (int, error) {
+	var i int
+	switch o := field.(type) {
+	case types.Number:
+		var err error
+		if i, err = toIndex(o, m.Count()); err != nil {
+			return 0, err
+		}
+	case *types.Symbol:
+		i = m.Index(o.Name)
+	case *types.String:
+		i = m.Index(o.String())
+	default:
+		return 0, types.NewTypeError("submatch index or name required, but got %v", field)
+	}
+	if i < 0 {
+		return 0, types.NewInternalError("no such submatch: %v", field)
+	}
+	return i, nil
+}
+
+func fnRegexpMatchCount(s *State, args []types.Object) (types.Object, error) {
+	m, err := toRegexpMatch(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(m.Count()), nil
+}
+
+// (regexp-match-submatch match field)
+// Returns #f if the submatch did not match.
+func fnRegexpMatchSubmatch(s *State, args []types.Object) (types.Object, error) {
+	m, err := toRegexpMatch(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := submatchIndex(m, args[1])
+	if err != nil {
+		return nil, err
+	}
+	sub, ok := m.Submatch(i)
+	if !ok {
+		return types.Boolean(false), nil
+	}
+	return types.NewString(sub), nil
+}
+
+// genFnRegexpMatchBound generates regexp-match-submatch-start and regexp-match-submatch-end.
+func genFnRegexpMatchBound(start bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		m, err := toRegexpMatch(args[0])
+		if err != nil {
+			return nil, err
+		}
+		i, err := submatchIndex(m, args[1])
+		if err != nil {
+			return nil, err
+		}
+		from, to, ok := m.Bounds(i)
+		switch {
+		case !ok:
+			return types.Boolean(false), nil
+		case start:
+			return types.Number(from), nil
+		}
+		return types.Number(to), nil
+	}
+}
+
+// (regexp-match->list match)
+// Returns the list of the whole match and the submatches.
+func fnRegexpMatchToList(s *State, args []types.Object) (types.Object, error) {
+	m, err := toRegexpMatch(args[0])
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]types.Object, m.Count()+1)
+	for i := range subs {
+		if sub, ok := m.Submatch(i); ok {
+			subs[i] = types.NewString(sub)
+		} else {
+			subs[i] = types.Boolean(false)
+		}
+	}
+	return types.List(subs...), nil
+}
@@ -0,0 +1,117 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestFnRegexp(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(regexp? (regexp "a+"))`, expect: "#t"},
+		&tcase{src: `(regexp? "a+")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? "a+b" "aaab")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? "a+" "aaab")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? "a|ab" "ab")`, expect: "#t"},
+		&tcase{src: `(regexp-matches "a+" "baa")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? "b" "abc" 1 2)`, expect: "#t"},
+		&tcase{src: `(regexp-match? (regexp-search "[0-9]+" "ab123"))`, expect: "#t"},
+		&tcase{src: `(regexp-search "x" "abc")`, expect: "#f"},
+		&tcase{src: `(regexp-match-submatch (regexp-search "[0-9]+" "ab123cd") 0)`, expect: "123"},
+		&tcase{src: `(regexp-match-submatch (regexp-search "a" "aXa" 1) 0)`, expect: "a"},
+		&tcase{src: `(regexp-match-submatch-start (regexp-search "a" "aXa" 1) 0)`, expect: "2"},
+		&tcase{src: `(regexp "(")`, expectErr: true},
+		&tcase{src: `(regexp-search "a" 1)`, expectErr: true},
+		&tcase{src: `(regexp-search "a" "abc" 4)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenRegexp)
+}
+
+func TestFnRegexpSRE(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(regexp-matches? '(+ digit) "123")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(: "a.b" (* "c")) "a.bcc")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(: "a.b") "axb")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? '(or "ab" "cd") "cd")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(seq (? "x") "y") "y")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(= 3 alpha) "abc")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(= 3 alpha) "ab")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? '(>= 2 "a") "aaaa")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(** 1 2 "a") "aaa")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? '(w/nocase "abc") "AbC")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(+ ("abc")) "cab")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(+ ("-]")) "]-")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(+ (/ "az" #\0 #\9)) "a1z")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(+ (~ digit)) "abc")`, expect: "#t"},
+		&tcase{src: `(regexp-matches? '(+ (~ digit)) "a1")`, expect: "#f"},
+		&tcase{src: `(regexp-matches? '(: bos (* any) eos) "a b")`, expect: "#t"},
+		&tcase{src: `(regexp-match-submatch (regexp-search '(: bow "cat" eow) "concat cat") 0)`, expect: "cat"},
+		&tcase{src: `(regexp-match-submatch-start (regexp-search '(: bow "cat" eow) "concat cat") 0)`, expect: "7"},
+		&tcase{src: `(regexp-match-submatch (regexp-search '(: "<" (*? any) ">") "<a><b>") 0)`, expect: "<a>"},
+		&tcase{src: `(regexp-matches? '(+ foo) "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? '(look-ahead "a") "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? '(/ "abc") "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? '(submatch-named 1 "a") "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? 1 "a")`, expectErr: true},
+		&tcase{src: `(regexp-match-submatch (regexp-search '(: (-> x (+ digit)) "-") "ab12-") 'x)`, expect: "12"},
+		&tcase{src: `(regexp-matches? '(~) "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? '(char-set "") "a")`, expectErr: true},
+		&tcase{src: `(regexp-matches? '(: (+ alpha) (~ (""))) "ab")`, expectErr: true},
+		// bow and eow match any word boundary
+		&tcase{src: `(regexp-match-submatch (regexp-search '(: eow "cat" bow) "a cat b") 0)`, expect: "cat"},
+		&tcase{src: `(regexp-search '(: bow "at") "cat")`, expect: "#f"},
+	}
+	testTcases(t, tcases, (*State).OpenRegexp)
+}
+
+func TestFnRegexpMatch(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define m (regexp-search '(: ($ (+ digit)) "-" (submatch-named month (+ digit))) "on 2024-05"))
+(regexp-match-submatch m 1)`, expect: "2024"},
+		&tcase{src: `(define m (regexp-search '(: ($ (+ digit)) "-" (submatch-named month (+ digit))) "on 2024-05"))
+(regexp-match-submatch m 'month)`, expect: "05"},
+		&tcase{src: `(define m (regexp-search "(?P<y>[0-9]+)" "x12"))
+(regexp-match-submatch m "y")`, expect: "12"},
+		&tcase{src: `(regexp-match-count (regexp-search "(a)(b)?" "a"))`, expect: "2"},
+		&tcase{src: `(regexp-match-submatch (regexp-search "(a)(b)?" "a") 2)`, expect: "#f"},
+		&tcase{src: `(regexp-match-submatch-end (regexp-search "(a)(b)?" "a") 2)`, expect: "#f"},
+		&tcase{src: `(length (regexp-match->list (regexp-search "(a)(b)?" "a")))`, expect: "3"},
+		&tcase{src: `(car (cdr (regexp-match->list (regexp-matches "(a)b" "ab"))))`, expect: "a"},
+		// indices are counted in characters
+		&tcase{src: `(define m (regexp-search "(b+)" "ああbbい"))
+(regexp-match-submatch-start m 1)`, expect: "2"},
+		&tcase{src: `(define m (regexp-search "(b+)" "ああbbい"))
+(regexp-match-submatch-end m 1)`, expect: "4"},
+		&tcase{src: `(regexp-match-submatch (regexp-search "a" "a") 1)`, expectErr: true},
+		&tcase{src: `(regexp-match-submatch (regexp-search "a" "a") 'x)`, expectErr: true},
+		&tcase{src: `(regexp-match-count "a")`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenRegexp)
+}
+
+func TestFnRegexpReplace(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(regexp-replace "o" "foo boo" "0")`, expect: "f0o boo"},
+		&tcase{src: `(regexp-replace "o" "foo boo" "0" 0 7 2)`, expect: "foo b0o"},
+		&tcase{src: `(regexp-replace-all "o" "foo boo" "0")`, expect: "f00 b00"},
+		&tcase{src: `(regexp-replace-all "o" "foo boo" "0" 3)`, expect: "foo b00"},
+		&tcase{src: `(regexp-replace-all "x" "abc" "0")`, expect: "abc"},
+		&tcase{src: `(regexp-replace-all "([a-z0-9]+)=([a-z0-9]+)" "a=1 b=2" '(2 "=" 1))`, expect: "1=a 2=b"},
+		&tcase{src: `(regexp-replace '(submatch-named n (+ digit)) "ab12cd" '("[" n "]"))`, expect: "ab[12]cd"},
+		&tcase{src: `(regexp-replace "-" "ab-cd" '(post pre))`, expect: "abcdabcd"},
+		&tcase{src: `(regexp-replace-all '(+ digit) "a1b22" (lambda (m) (string-append "<" (regexp-match-submatch m 0) ">")))`, expect: "a<1>b<22>"},
+		&tcase{src: `(regexp-replace-all "a" "aa" (lambda (m) 1))`, expectErr: true},
+		&tcase{src: `(regexp-replace "a" "a" 5)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenRegexp, (*State).OpenString)
+}
+
+func TestFnRegexpExtractSplit(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(length (regexp-extract '(+ digit) "a1b22c333"))`, expect: "3"},
+		&tcase{src: `(car (cdr (regexp-extract '(+ digit) "a1b22c333")))`, expect: "22"},
+		&tcase{src: `(regexp-extract '(* digit) "abc")`, expect: "()"},
+		&tcase{src: `(length (regexp-split '(+ space) "a b  c"))`, expect: "3"},
+		&tcase{src: `(car (cdr (cdr (regexp-split "," "a,b,c"))))`, expect: "c"},
+		&tcase{src: `(length (regexp-split "," "a,b,c" 2))`, expect: "2"},
+	}
+	testTcases(t, tcases, (*State).OpenRegexp)
+}
@@ -3,55 +3,118 @@ package scanner
 import (
 	"bytes"
 	"github.com/hyusuk/tama/types"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// Scanner reads tokens from an io.RuneScanner.
+// It never reads beyond the end of the token, so the rest of the input
+// is left in the reader.
 type Scanner struct {
-	src     []byte
-	ch      byte
-	forward int
-	offset  int
+	r         io.RuneScanner
+	err       error  // error of the reader other than io.EOF
+	filename  string // name of the source, or empty
+	line      int    // position of the next character
+	column    int
+	tokLine   int // position of the last token
+	tokColumn int
 }
 
-var eofCh byte = 255
+const eofCh rune = -1
 
-func (s *Scanner) next() {
-	if s.forward < len(s.src) {
-		s.offset = s.forward
-		s.ch = s.src[s.forward]
-		s.forward += 1
-	} else {
-		s.offset = len(s.src)
-		s.ch = eofCh
+// peek returns the next character without consuming it.
+func (s *Scanner) peek() rune {
+	if s.err != nil {
+		return eofCh
+	}
+	ch, _, err := s.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return eofCh
 	}
+	s.r.UnreadRune()
+	return ch
 }
 
-func (s *Scanner) peek() byte {
-	if s.forward < len(s.src) {
-		return s.src[s.forward]
+// next consumes the next character.
+func (s *Scanner) next() rune {
+	if s.err != nil {
+		return eofCh
+	}
+	ch, _, err := s.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return eofCh
 	}
-	return eofCh
+	if ch == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return ch
 }
 
 func (s *Scanner) Init(src []byte) {
-	s.src = src
-	s.ch = ' '
-	s.forward = 0
-	s.offset = 0
-	s.next()
+	s.InitFile("", src)
+}
+
+// InitFile initializes the scanner to read src named filename.
+// The name is reported in the positions.
+func (s *Scanner) InitFile(filename string, src []byte) {
+	s.InitReader(bytes.NewReader(src), 1, 1)
+	s.filename = filename
+}
+
+// InitReader initializes the scanner to read from r.
+// line and column are the position of the first character of r.
+func (s *Scanner) InitReader(r io.RuneScanner, line, column int) {
+	s.r = r
+	s.err = nil
+	s.filename = ""
+	s.line = line
+	s.column = column
+	s.tokLine = line
+	s.tokColumn = column
+}
+
+// Pos returns the position where the last token starts.
+func (s *Scanner) Pos() types.Pos {
+	return types.Pos{File: s.filename, Line: s.tokLine, Column: s.tokColumn}
+}
+
+// End returns the position just after the last token.
+func (s *Scanner) End() types.Pos {
+	return types.Pos{File: s.filename, Line: s.line, Column: s.column}
+}
+
+func (s *Scanner) error(format string, v ...interface{}) error {
+	return types.NewReadError(s.Pos(), format, v...)
 }
 
 func (s *Scanner) skipWhitespaces() {
-	if isWhitespace(s.ch) {
+	for isWhitespace(s.peek()) {
 		s.next()
 	}
 }
 
-func (s *Scanner) scanUnsigned() (Token, string) {
-	off := s.offset
-	for !isDelimiter(s.ch) {
-		s.next()
+// scanDelimited scans characters until a delimiter.
+func (s *Scanner) scanDelimited(b *strings.Builder) {
+	for !isDelimiter(s.peek()) {
+		b.WriteRune(s.next())
 	}
-	return NUMBER, string(s.src[off:s.offset])
+}
+
+func (s *Scanner) scanUnsigned() (Token, string) {
+	var b strings.Builder
+	s.scanDelimited(&b)
+	return NUMBER, b.String()
 }
 
 var (
@@ -59,56 +122,170 @@ var (
 	specialSubseqents = []byte{'+', '-', '.', '@'}
 )
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isInitial(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || bytes.LastIndexByte(specialInits, ch) >= 0
+func isInitial(ch rune) bool {
+	return ('a' <= ch && ch <= 'z') || (0 <= ch && ch < 128 && bytes.IndexByte(specialInits, byte(ch)) >= 0)
 }
 
-func (s *Scanner) scanIdentifier() string {
-	offs := s.offset
-	for isInitial(s.ch) || isDigit(s.ch) || bytes.LastIndexByte(specialSubseqents, s.ch) >= 0 {
-		s.next()
+func isSubsequent(ch rune) bool {
+	return isInitial(ch) || isDigit(ch) || (0 <= ch && ch < 128 && bytes.IndexByte(specialSubseqents, byte(ch)) >= 0)
+}
+
+func (s *Scanner) scanIdentifier(b *strings.Builder) string {
+	for isSubsequent(s.peek()) {
+		b.WriteRune(s.next())
 	}
-	return string(s.src[offs:s.offset])
+	return b.String()
 }
 
 func (s *Scanner) scanComment() {
-	for s.ch != '\n' && s.ch != '\r' && s.ch != eofCh {
+	for ch := s.peek(); ch != '\n' && ch != '\r' && ch != eofCh; ch = s.peek() {
 		s.next()
 	}
 }
 
-func (s *Scanner) scanString() (Token, string) {
-	offs := s.offset
-	for s.ch != '"' && s.ch != eofCh {
-		s.next()
+// stringEscapes are the characters of the escape sequences in strings.
+var stringEscapes = map[rune]rune{
+	'a':  '\a',
+	'b':  '\b',
+	't':  '\t',
+	'n':  '\n',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+	'|':  '|',
+}
+
+func isIntralineWhitespace(ch rune) bool {
+	return ch == ' ' || ch == '\t'
+}
+
+func (s *Scanner) scanString() (Token, string, error) {
+	var b strings.Builder
+	for {
+		ch := s.next()
+		switch ch {
+		case '"':
+			return STRING, b.String(), nil
+		case eofCh:
+			return ILLEGAL, "", s.error("unterminated string")
+		case '\\':
+			if err := s.scanEscape(&b); err != nil {
+				return ILLEGAL, "", err
+			}
+			continue
+		}
+		b.WriteRune(ch)
 	}
-	offset := s.offset
-	s.next()
-	return STRING, string(s.src[offs:offset])
 }
 
-func isWhitespace(ch byte) bool {
+// scanEscape scans an escape sequence after a backslash in a string.
+func (s *Scanner) scanEscape(b *strings.Builder) error {
+	ch := s.next()
+	if r, ok := stringEscapes[ch]; ok {
+		b.WriteRune(r)
+		return nil
+	}
+	switch {
+	case ch == 'x':
+		var hex strings.Builder
+		for ch = s.next(); ch != ';'; ch = s.next() {
+			if ch == eofCh || ch == '"' {
+				return s.error("unterminated hex escape in string")
+			}
+			hex.WriteRune(ch)
+		}
+		code, err := strconv.ParseUint(hex.String(), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return s.error("invalid hex escape \\x%s; in string", hex.String())
+		}
+		b.WriteRune(rune(code))
+		return nil
+	case isIntralineWhitespace(ch) || ch == '\n' || ch == '\r':
+		// line continuation: \<intraline whitespace>*<line ending><intraline whitespace>*
+		for isIntralineWhitespace(ch) {
+			ch = s.next()
+		}
+		if ch == '\r' && s.peek() == '\n' {
+			ch = s.next()
+		}
+		if ch != '\n' && ch != '\r' {
+			return s.error("invalid line continuation in string")
+		}
+		for isIntralineWhitespace(s.peek()) {
+			s.next()
+		}
+		return nil
+	case ch == eofCh:
+		return s.error("unterminated string")
+	}
+	return s.error("unknown escape sequence \\%c in string", ch)
+}
+
+// scanChar scans a character after "#\".
+// The literal is the character itself or its name. (e.g. "a", "space", "x41")
+func (s *Scanner) scanChar() (Token, string, error) {
+	var b strings.Builder
+	// the first character can be a delimiter. (e.g. #\()
+	ch := s.next()
+	if ch == eofCh {
+		return ILLEGAL, "", s.error("unterminated character")
+	}
+	b.WriteRune(ch)
+	s.scanDelimited(&b)
+	return CHAR, b.String(), nil
+}
+
+// scanLabel scans a datum label such as #0= or #0# after "#" and the first digit.
+// The literal is the number.
+func (s *Scanner) scanLabel(first rune) (Token, string, error) {
+	var b strings.Builder
+	b.WriteRune(first)
+	for isDigit(s.peek()) {
+		b.WriteRune(s.next())
+	}
+	switch s.next() {
+	case '=':
+		return LABEL, b.String(), nil
+	case '#':
+		return LABELREF, b.String(), nil
+	}
+	return ILLEGAL, "", s.error("invalid datum label #%s", b.String())
+}
+
+func isWhitespace(ch rune) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 }
 
-func isDelimiter(ch byte) bool {
+func isDelimiter(ch rune) bool {
 	return isWhitespace(ch) || ch == '(' || ch == ')' || ch == '"' || ch == ';' || ch == eofCh
 }
 
 func (s *Scanner) Scan() (tok Token, lit string, err error) {
+	tok, lit, err = s.scan()
+	if s.err != nil {
+		if e, ok := s.err.(*types.Error); ok {
+			return ILLEGAL, "", e
+		}
+		return ILLEGAL, "", types.NewInternalError("%v", s.err)
+	}
+	return
+}
+
+func (s *Scanner) scan() (tok Token, lit string, err error) {
 scanAgain:
 	s.skipWhitespaces()
-	ch := s.ch
+	s.tokLine, s.tokColumn = s.line, s.column
+	ch := s.peek()
 	if isDigit(ch) {
 		tok, lit = s.scanUnsigned()
 		return
 	}
 	if isInitial(ch) {
-		lit = s.scanIdentifier()
+		lit = s.scanIdentifier(&strings.Builder{})
 		tok = IDENT
 		return
 	}
@@ -116,35 +293,46 @@ scanAgain:
 	switch ch {
 	case eofCh:
 		tok = EOF
-	case '+':
-		if isDelimiter(s.ch) {
-			tok = IDENT
-			lit = "+"
-		} else {
+	case '+', '-':
+		if next := s.peek(); isDigit(next) || next == '.' {
 			tok, lit = s.scanUnsigned()
-		}
-	case '-':
-		if isDelimiter(s.ch) {
-			tok = IDENT
-			lit = "-"
+			if ch == '-' {
+				lit = "-" + lit
+			}
 		} else {
-			tok, lit = s.scanUnsigned()
-			lit = "-" + lit
+			// peculiar identifier such as + and ->
+			var b strings.Builder
+			b.WriteRune(ch)
+			tok = IDENT
+			lit = s.scanIdentifier(&b)
 		}
 	case '.':
 		tok = IDENT
 		lit = "."
+		if s.peek() == '.' {
+			// peculiar identifier such as ...
+			var b strings.Builder
+			b.WriteRune('.')
+			lit = s.scanIdentifier(&b)
+		}
 	case '"':
-		tok, lit = s.scanString()
+		tok, lit, err = s.scanString()
 	case '(':
 		tok = LPAREN
 	case ')':
 		tok = RPAREN
 	case '\'':
 		tok = QUOTE
+	case '`':
+		tok = QUASIQUOTE
+	case ',':
+		tok = UNQUOTE
+		if s.peek() == '@' {
+			s.next()
+			tok = UNQUOTE_SPLICING
+		}
 	case '#':
-		ch2 := s.ch
-		s.next()
+		ch2 := s.next()
 		switch ch2 {
 		case 't':
 			tok = TRUE
@@ -152,14 +340,26 @@ scanAgain:
 			tok = FALSE
 		case '(':
 			tok = VLPAREN
+		case '\\':
+			tok, lit, err = s.scanChar()
+		case 'u':
+			if s.next() != '8' || s.next() != '(' {
+				return ILLEGAL, "", s.error("unexpected token #u")
+			}
+			tok = BVLPAREN
+		case eofCh:
+			return ILLEGAL, "", s.error("unexpected end of input after #")
 		default:
-			return ILLEGAL, "", types.NewSyntaxError("unexpected token %c", ch2)
+			if isDigit(ch2) {
+				return s.scanLabel(ch2)
+			}
+			return ILLEGAL, "", s.error("unexpected token #%c", ch2)
 		}
 	case ';':
 		s.scanComment()
 		goto scanAgain
 	default:
-		return ILLEGAL, "", types.NewSyntaxError("unexpected token %c", s.ch)
+		return ILLEGAL, "", s.error("unexpected character %q", ch)
 	}
 	return
 }
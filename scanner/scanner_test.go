@@ -1,6 +1,8 @@
 package scanner
 
 import (
+	"github.com/hyusuk/tama/types"
+	"strings"
 	"testing"
 )
 
@@ -52,6 +54,59 @@ func TestScan(t *testing.T) {
 				{tok: EOF, lit: ""},
 			},
 		},
+		{
+			src: []byte("(-> ->x - -.5 +a)"),
+			expects: []expect{
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "->"},
+				{tok: IDENT, lit: "->x"},
+				{tok: IDENT, lit: "-"},
+				{tok: NUMBER, lit: "-.5"},
+				{tok: IDENT, lit: "+a"},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte("`(a ,b ,@c)"),
+			expects: []expect{
+				{tok: QUASIQUOTE, lit: ""},
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "a"},
+				{tok: UNQUOTE, lit: ""},
+				{tok: IDENT, lit: "b"},
+				{tok: UNQUOTE_SPLICING, lit: ""},
+				{tok: IDENT, lit: "c"},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte("(a . b) (x ...)"),
+			expects: []expect{
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "a"},
+				{tok: IDENT, lit: "."},
+				{tok: IDENT, lit: "b"},
+				{tok: RPAREN, lit: ""},
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "x"},
+				{tok: IDENT, lit: "..."},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte("(a  \n\t b) ; comment\n c"),
+			expects: []expect{
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "a"},
+				{tok: IDENT, lit: "b"},
+				{tok: RPAREN, lit: ""},
+				{tok: IDENT, lit: "c"},
+				{tok: EOF, lit: ""},
+			},
+		},
 		{
 			src: []byte("\"test\""),
 			expects: []expect{
@@ -59,6 +114,47 @@ func TestScan(t *testing.T) {
 				{tok: EOF, lit: ""},
 			},
 		},
+		{
+			src: []byte("#\\a #\\space #\\( #\\é)"),
+			expects: []expect{
+				{tok: CHAR, lit: "a"},
+				{tok: CHAR, lit: "space"},
+				{tok: CHAR, lit: "("},
+				{tok: CHAR, lit: "é"},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte(`"a\"\\\n\x41;\
+			  b"`),
+			expects: []expect{
+				{tok: STRING, lit: "a\"\\\nAb"},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte("#0=(a . #12#)"),
+			expects: []expect{
+				{tok: LABEL, lit: "0"},
+				{tok: LPAREN, lit: ""},
+				{tok: IDENT, lit: "a"},
+				{tok: IDENT, lit: "."},
+				{tok: LABELREF, lit: "12"},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
+		{
+			src: []byte("#u8(1 2)"),
+			expects: []expect{
+				{tok: BVLPAREN, lit: ""},
+				{tok: NUMBER, lit: "1"},
+				{tok: NUMBER, lit: "2"},
+				{tok: RPAREN, lit: ""},
+				{tok: EOF, lit: ""},
+			},
+		},
 	}
 	for i, tc := range testcases {
 		s.Init(tc.src)
@@ -76,3 +172,53 @@ func TestScan(t *testing.T) {
 		}
 	}
 }
+
+func TestScanPos(t *testing.T) {
+	var s Scanner
+	s.InitReader(strings.NewReader("a\n  (b \"日本\" c"), 1, 1)
+	expects := [][2]int{{1, 1}, {2, 3}, {2, 4}, {2, 6}, {2, 11}, {2, 12}}
+	for i, expect := range expects {
+		if _, _, err := s.Scan(); err != nil {
+			t.Fatalf("case %d: unexpected error %v", i, err)
+		}
+		if pos := s.Pos(); pos.Line != expect[0] || pos.Column != expect[1] {
+			t.Fatalf("case %d: expected %d:%d, but got %v", i, expect[0], expect[1], pos)
+		}
+	}
+}
+
+func TestScanFilePos(t *testing.T) {
+	var s Scanner
+	s.InitFile("rules.scm", []byte("\n (abc \"x\ny\")"))
+	expects := []struct{ start, end string }{
+		{"rules.scm:2:2", "rules.scm:2:3"},
+		{"rules.scm:2:3", "rules.scm:2:6"},
+		{"rules.scm:2:7", "rules.scm:3:3"},
+		{"rules.scm:3:3", "rules.scm:3:4"},
+	}
+	for i, expect := range expects {
+		if _, _, err := s.Scan(); err != nil {
+			t.Fatalf("case %d: unexpected error %v", i, err)
+		}
+		if start, end := s.Pos().String(), s.End().String(); start != expect.start || end != expect.end {
+			t.Fatalf("case %d: expected %s-%s, but got %s-%s", i, expect.start, expect.end, start, end)
+		}
+	}
+	s.InitFile("bad.scm", []byte("\n  #q"))
+	_, _, err := s.Scan()
+	if err == nil || err.Error() != "bad.scm:2:3: unexpected token #q" {
+		t.Fatalf("expected the error at bad.scm:2:3, but got %v", err)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	var s Scanner
+	for i, src := range []string{"\"abc", `"\q"`, `"\x41"`, "#q", "#u9(", "A", "#\\"} {
+		s.Init([]byte(src))
+		_, _, err := s.Scan()
+		e, ok := err.(*types.Error)
+		if !ok || e.ErrorType() != types.ErrRead {
+			t.Fatalf("case %d: expected read error, but got %v", i, err)
+		}
+	}
+}
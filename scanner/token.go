@@ -9,9 +9,16 @@ const (
 	LPAREN // "("
 	RPAREN // ")"
 	IDENT
-	QUOTE // "'"
-	TRUE  // "#t"
-	FALSE // "#f"
+	QUOTE            // "'"
+	QUASIQUOTE       // "`"
+	UNQUOTE          // ","
+	UNQUOTE_SPLICING // ",@"
+	TRUE             // "#t"
+	FALSE            // "#f"
 	STRING
-	VLPAREN // "#("
+	VLPAREN  // "#("
+	CHAR     // "#\a"
+	BVLPAREN // "#u8("
+	LABEL    // "#0="
+	LABELREF // "#0#"
 )
@@ -0,0 +1,188 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+)
+
+// OpenSort registers the sort library. (SRFI-132)
+// The comparison procedure is called as (< a b) and must be a strict order.
+func (s *State) OpenSort() *State {
+	s.RegisterFunc("list-sort", 2, 2, fnListSort)
+	s.RegisterFunc("vector-sort", 2, 4, fnVecSort)
+	s.RegisterFunc("vector-sort!", 2, 4, fnVecSortInPlace)
+	s.RegisterFunc("list-merge", 3, 3, fnListMerge)
+	s.RegisterFunc("sorted?", 2, 2, fnIsSorted)
+	s.RegisterFunc("list-delete-neighbor-dups", 2, 2, fnListDeleteNeighborDups)
+	return s
+}
+
+// lessFunc returns a Go function which calls the comparison procedure less.
+func (s *State) lessFunc(less types.Object) func(a, b types.Object) (bool, error) {
+	return func(a, b types.Object) (bool, error) {
+		v, err := s.Call(less, a, b)
+		if err != nil {
+			return false, err
+		}
+		return types.IsTruthy(v), nil
+	}
+}
+
+// mergeSort sorts elems stably in place.
+// It stops at the first error returned by less, leaving elems in an unspecified order.
+func mergeSort(elems []types.Object, less func(a, b types.Object) (bool, error)) error {
+	buf := make([]types.Object, len(elems))
+	// bottom-up, so that sorting never grows the Go stack
+	for width := 1; width < len(elems); width *= 2 {
+		for lo := 0; lo < len(elems)-width; lo += 2 * width {
+			mid := lo + width
+			hi := mid + width
+			if hi > len(elems) {
+				hi = len(elems)
+			}
+			if err := merge(buf[lo:hi], elems[lo:mid], elems[mid:hi], less); err != nil {
+				return err
+			}
+			copy(elems[lo:hi], buf[lo:hi])
+		}
+	}
+	return nil
+}
+
+// merge merges the sorted slices a and b into dst.
+// Elements of a precede equal elements of b.
+func merge(dst, a, b []types.Object, less func(a, b types.Object) (bool, error)) error {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		bFirst, err := less(b[j], a[i])
+		if err != nil {
+			return err
+		}
+		if bFirst {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+	return nil
+}
+
+// (list-sort < list)
+func fnListSort(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := mergeSort(elems, s.lessFunc(args[0])); err != nil {
+		return nil, err
+	}
+	return types.List(elems...), nil
+}
+
+// (vector-sort < vector [start [end]])
+// Returns a new vector of the sorted elements between start and end.
+func fnVecSort(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[1]); err != nil {
+		return nil, err
+	}
+	vec := args[1].(*types.Vector)
+	start, end, err := toRange(args[2:], vec.Len())
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]types.Object, end-start)
+	copy(elems, vec.Elems()[start:end])
+	if err := mergeSort(elems, s.lessFunc(args[0])); err != nil {
+		return nil, err
+	}
+	return types.NewVector(elems), nil
+}
+
+// (vector-sort! < vector [start [end]])
+// The elements are not modified if the comparison procedure raises an error.
+func fnVecSortInPlace(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyVector, args[1]); err != nil {
+		return nil, err
+	}
+	vec := args[1].(*types.Vector)
+	if vec.IsImmutable() {
+		return nil, types.NewInternalError("attempt to modify an immutable vector")
+	}
+	start, end, err := toRange(args[2:], vec.Len())
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]types.Object, end-start)
+	copy(elems, vec.Elems()[start:end])
+	if err := mergeSort(elems, s.lessFunc(args[0])); err != nil {
+		return nil, err
+	}
+	copy(vec.Elems()[start:end], elems)
+	return types.UndefinedObject, nil
+}
+
+// (list-merge < list1 list2)
+func fnListMerge(s *State, args []types.Object) (types.Object, error) {
+	a, err := toSlice(args[1])
+	if err != nil {
+		return nil, err
+	}
+	b, err := toSlice(args[2])
+	if err != nil {
+		return nil, err
+	}
+	elems := make([]types.Object, len(a)+len(b))
+	if err := merge(elems, a, b, s.lessFunc(args[0])); err != nil {
+		return nil, err
+	}
+	return types.List(elems...), nil
+}
+
+// (sorted? < list-or-vector)
+func fnIsSorted(s *State, args []types.Object) (types.Object, error) {
+	var elems []types.Object
+	if vec, ok := args[1].(*types.Vector); ok {
+		elems = vec.Elems()
+	} else {
+		var err error
+		if elems, err = toSlice(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	less := s.lessFunc(args[0])
+	for i := 1; i < len(elems); i++ {
+		desc, err := less(elems[i], elems[i-1])
+		if err != nil {
+			return nil, err
+		}
+		if desc {
+			return types.Boolean(false), nil
+		}
+	}
+	return types.Boolean(true), nil
+}
+
+// (list-delete-neighbor-dups = list)
+// Keeps the first element of each run of equal elements.
+func fnListDeleteNeighborDups(s *State, args []types.Object) (types.Object, error) {
+	equiv := s.equivalence("", args[:1])
+	kept := []types.Object{}
+	err := mapLists(args[1:], func(elems []types.Object) error {
+		if len(kept) > 0 {
+			same, err := equiv(kept[len(kept)-1], elems[0])
+			if err != nil || same {
+				return err
+			}
+		}
+		kept = append(kept, elems[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.List(kept...), nil
+}
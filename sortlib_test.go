@@ -0,0 +1,67 @@
+package tama
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFnListSort(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (list-sort < (list 3 1 2)) (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(list-sort < '())", expect: "()"},
+		// stable
+		&tcase{src: "(equal? (list-sort (lambda (a b) (< (car a) (car b))) (list (cons 1 'a) (cons 0 'b) (cons 1 'c) (cons 0 'd))) (list (cons 0 'b) (cons 0 'd) (cons 1 'a) (cons 1 'c)))", expect: "#t"},
+		&tcase{src: "(list-sort < (cons 1 2))", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenSort)
+}
+
+func TestFnVecSort(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (vector-sort < #(3 1 2)) #(1 2 3))", expect: "#t"},
+		&tcase{src: "(equal? (vector-sort < #(5 4 3 2 1) 1 4) #(2 3 4))", expect: "#t"},
+		&tcase{src: "(define v (vector 5 4 3 2 1)) (vector-sort! < v 1 4) (equal? v #(5 2 3 4 1))", expect: "#t"},
+		&tcase{src: "(define v (vector 3 1 2)) (vector-sort! > v) (equal? v #(3 2 1))", expect: "#t"},
+		&tcase{src: "(vector-sort! < #(3 1 2))", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenSort)
+}
+
+func TestFnListMerge(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(equal? (list-merge < (list 1 3 5) (list 2 3 4)) (list 1 2 3 3 4 5))", expect: "#t"},
+		&tcase{src: "(sorted? < (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(sorted? < #(1 3 2))", expect: "#f"},
+		&tcase{src: "(sorted? < (list 1 1))", expect: "#t"},
+		&tcase{src: "(equal? (list-delete-neighbor-dups = (list 1 1 2 1 3 3)) (list 1 2 1 3))", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenSort)
+}
+
+func TestFnSortLarge(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(sorted? < (list-sort < (reverse (iota 200000))))", expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenSort, (*State).OpenList)
+}
+
+func TestFnSortError(t *testing.T) {
+	s := NewState(Option{}).OpenSort()
+	sp := s.CallStack.Sp()
+	err := s.ExecString("(define v (vector 3 1 2)) (vector-sort! (lambda (a b) (car a)) v)")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if !strings.Contains(err.Error(), "car") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if s.CallStack.Sp() != sp {
+		t.Fatalf("expected %d, but got %d", sp, s.CallStack.Sp())
+	}
+	if err := s.ExecString("(vector-ref v 0)"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.CallStack.Top(); v.String() != "3" {
+		t.Fatalf("expected %s, but got %s", "3", v.String())
+	}
+}
@@ -5,6 +5,11 @@ import (
 	"github.com/hyusuk/tama/compiler"
 	"github.com/hyusuk/tama/parser"
 	"github.com/hyusuk/tama/types"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"time"
 )
 
 const (
@@ -16,6 +21,29 @@ type Option struct {
 	StackSize    int
 	CallInfoSize int
 	Debug        bool
+	// Stdin is the current input port. It defaults to os.Stdin.
+	Stdin io.Reader
+	// Stdout is the current output port. It defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr is the current error port. It defaults to os.Stderr.
+	Stderr io.Writer
+	// FS is the file system read by the file library.
+	// Reading files is not allowed if it is nil.
+	FS fs.FS
+	// WriteFS is the file system written by the file library.
+	// Writing files is not allowed if it is nil.
+	WriteFS WriteFS
+	// Clock returns the current time for the time library. It defaults to time.Now.
+	// Jiffies are counted from the time when the state is created.
+	Clock func() time.Time
+	// RandomSeed seeds default-random-source of the random library, so that runs
+	// are reproducible. If it is nil, a random seed is used.
+	RandomSeed *uint64
+	// Args is the command line returned by command-line, starting with the script name.
+	Args []string
+	// Env holds the environment variables of the process context library.
+	// The environment of the host process is not visible unless it is copied here.
+	Env map[string]string
 }
 
 type State struct {
@@ -25,6 +53,20 @@ type State struct {
 	Global    map[string]types.Object
 	uvhead    *types.UpValue
 	Debug     bool
+	stdin     *types.Port // current input port
+	stdout    *types.Port // current output port
+	stderr    *types.Port // current error port
+	fsys      fs.FS
+	wfs       WriteFS
+	clock     func() time.Time
+	epoch     time.Time // origin of jiffies
+	random    *types.RandomSource
+	winders   []types.Object // after thunks of the active dynamic-winds, innermost last
+	vms       []uint64       // ids of the running VM loops, innermost last
+	vmSerial  uint64         // id of the last VM loop
+	args      []string
+	env       map[string]string
+	applyCl   *types.Closure
 }
 
 type GoFunc = func(s *State, args []types.Object) (types.Object, error)
@@ -36,25 +78,59 @@ func NewState(option Option) *State {
 	if option.CallInfoSize == 0 {
 		option.CallInfoSize = DefaultCallInfoSize
 	}
+	if option.Stdin == nil {
+		option.Stdin = os.Stdin
+	}
+	if option.Stdout == nil {
+		option.Stdout = os.Stdout
+	}
+	if option.Stderr == nil {
+		option.Stderr = os.Stderr
+	}
+	if option.Clock == nil {
+		option.Clock = time.Now
+	}
+	if option.RandomSeed == nil {
+		seed := rand.Uint64()
+		option.RandomSeed = &seed
+	}
 
 	s := &State{
 		CallStack: types.NewStack(option.StackSize),
 		CallInfos: types.NewStack(option.CallInfoSize),
 		Global:    map[string]types.Object{},
 		Debug:     option.Debug,
+		stdin:     types.NewInputPort(option.Stdin, false),
+		stdout:    types.NewOutputPort(option.Stdout, false),
+		stderr:    types.NewOutputPort(option.Stderr, false),
+		fsys:      option.FS,
+		wfs:       option.WriteFS,
+		clock:     option.Clock,
+		epoch:     option.Clock(),
+		random:    types.NewRandomSource(*option.RandomSeed, 0),
+		args:      option.Args,
+		env:       option.Env,
 	}
 	s.OpenBase()
 	return s
 }
 
 func (s *State) LoadString(source string) (*types.Closure, error) {
+	return s.LoadSource("", source)
+}
+
+// LoadSource compiles source read from the file filename.
+// The errors raised by the code are reported with the positions in the file.
+func (s *State) LoadSource(filename string, source string) (*types.Closure, error) {
 	p := &parser.Parser{}
-	p.Init([]byte(source))
+	if err := p.InitFile(filename, []byte(source)); err != nil {
+		return nil, err
+	}
 	f, err := p.ParseFile()
 	if err != nil {
 		return nil, err
 	}
-	return compiler.Compile(s.Global, f.Objs)
+	return compiler.Compile(s.Global, f.Objs, f.Spans)
 }
 
 // popArgs pops arguements and create a slice [argument 1, ..., argument nargs].
@@ -109,6 +185,10 @@ func (s *State) checkArgNumber(name string, nargs, minArg, maxArg int) error {
 //      |            |
 //
 func (s *State) precall(clIndex int) (*types.CallInfo, error) {
+	if cont, ok := s.CallStack.Get(clIndex).(*types.Continuation); ok {
+		// called by a go function such as map
+		return nil, s.escape(cont, s.CallStack.Top())
+	}
 	cl, ok := s.CallStack.Get(clIndex).(*types.Closure)
 	if !ok {
 		return nil, types.NewInternalError("function is not loaded")
@@ -129,7 +209,7 @@ func (s *State) precall(clIndex int) (*types.CallInfo, error) {
 		retval, err := fn(s, args)
 		if err != nil {
 			if scmErr, ok := err.(*types.Error); ok {
-				scmErr.Set(fmt.Sprintf("%s: %s", cl.FnName, scmErr.Error()))
+				scmErr.Set(fmt.Sprintf("%s: %s", cl.FnName, scmErr.Message()))
 				return nil, scmErr
 			}
 			return nil, err
@@ -160,6 +240,34 @@ func (s *State) precall(clIndex int) (*types.CallInfo, error) {
 	}
 }
 
+// spreadApplyArgs rewrites the application of apply at fnIndex into the
+// application of its first argument, so that the VM can call it directly.
+//
+//	(apply f a b (c d)) -> (f a b c d)
+func (s *State) spreadApplyArgs(fnIndex int) error {
+	nargs := s.CallStack.Sp() - fnIndex
+	if err := s.checkArgNumber("apply", nargs, s.applyCl.MinArg, s.applyCl.MaxArg); err != nil {
+		return err
+	}
+	rest, err := toSlice(s.CallStack.Get(s.CallStack.Sp()))
+	if err != nil {
+		return types.NewTypeError("apply: %v", err)
+	}
+	// shift the procedure and the leading arguments down over apply
+	for i := 0; i < nargs-1; i++ {
+		s.CallStack.Set(fnIndex+i, s.CallStack.Get(fnIndex+i+1))
+	}
+	top := fnIndex + nargs - 2
+	if top+len(rest) >= s.CallStack.Len() {
+		return types.NewInternalError("apply: stack overflow (%d arguments)", len(rest))
+	}
+	for i, arg := range rest {
+		s.CallStack.Set(top+i+1, arg)
+	}
+	s.CallStack.SetSp(top + len(rest))
+	return nil
+}
+
 func (s *State) postcall(resultSp int) {
 	curCi := s.CallInfos.Pop().(*types.CallInfo) // pop current call info
 	result := s.CallStack.Get(resultSp)
@@ -169,19 +277,87 @@ func (s *State) postcall(resultSp int) {
 
 func (s *State) call(nargs int) error {
 	clIndex := s.CallStack.Sp() - nargs
-	if _, err := s.precall(clIndex); err != nil {
+	ci, err := s.precall(clIndex)
+	if err != nil {
 		return err
 	}
+	if ci.Cl.IsGo {
+		// already called by precall
+		return nil
+	}
 	return runVM(s, s.Debug)
 }
 
+// continuationEscape is the error returned by a continuation invoked in a VM loop
+// other than the one which owns it. It is passed through the go functions between
+// them, and the owner resumes the continuation.
+type continuationEscape struct {
+	cont *types.Continuation
+	arg  types.Object
+	vm   uint64 // id of the VM loop resuming the continuation
+}
+
+func (e *continuationEscape) Error() string {
+	return "continuation escaping from a go function"
+}
+
+// escape returns the error which makes the owner of cont resume it with arg.
+// The continuations of the top level can be resumed by any top level VM loop, but
+// the others only by the VM loop which captured them while it is running.
+func (s *State) escape(cont *types.Continuation, arg types.Object) error {
+	switch {
+	case len(s.vms) == 0:
+	case cont.Depth == 1:
+		return &continuationEscape{cont: cont, arg: arg, vm: s.vms[0]}
+	case cont.Depth <= len(s.vms) && s.vms[cont.Depth-1] == cont.VM:
+		return &continuationEscape{cont: cont, arg: arg, vm: cont.VM}
+	}
+	return types.NewInternalError("continuation cannot be resumed after the procedure which captured it returned")
+}
+
+// Call calls the procedure fn with args and returns the result.
+// Go functions can use it to call scheme procedures.
+// If an error occurs, the stacks are restored to the state before the call.
+func (s *State) Call(fn types.Object, args ...types.Object) (types.Object, error) {
+	sp := s.CallStack.Sp()
+	ciSp := s.CallInfos.Sp()
+	s.CallStack.Push(fn)
+	for _, arg := range args {
+		s.CallStack.Push(arg)
+	}
+	if err := s.call(len(args)); err != nil {
+		s.unwind(sp, ciSp)
+		return nil, err
+	}
+	return s.CallStack.Pop(), nil
+}
+
+// unwind restores the stacks to sp and ciSp after an error.
+// Upvalues still referring to the discarded slots are closed.
+func (s *State) unwind(sp int, ciSp int) {
+	s.closeUpValues(sp + 1)
+	s.CallStack.SetSp(sp)
+	s.CallInfos.SetSp(ciSp)
+}
+
 func (s *State) ExecString(source string) error {
-	cl, err := s.LoadString(source)
+	return s.ExecSource("", source)
+}
+
+// ExecSource executes source read from the file filename.
+func (s *State) ExecSource(filename string, source string) error {
+	cl, err := s.LoadSource(filename, source)
 	if err != nil {
 		return err
 	}
+	sp := s.CallStack.Sp()
+	ciSp := s.CallInfos.Sp()
 	s.CallStack.Push(cl)
-	return s.call(0)
+	if err := s.call(0); err != nil {
+		s.unwind(sp, ciSp)
+		return err
+	}
+	return nil
 }
 
 func (s *State) findUpValue(level int) *types.UpValue {
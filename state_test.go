@@ -2,6 +2,7 @@ package tama
 
 import (
 	"github.com/hyusuk/tama/types"
+	"strings"
 	"testing"
 )
 
@@ -22,6 +23,16 @@ func TestTailCall(t *testing.T) {
 			"(define (recur a) (if (= a 1) 1 (begin (recur (- a 1))))) (recur 100)",
 			"1",
 		},
+		{
+			func() *State { return NewState(Option{StackSize: 100, CallInfoSize: 10}) },
+			"(define (recur n) (if (= n 1) 1 (apply recur (- n 1) '()))) (recur 100)",
+			"1",
+		},
+		{
+			func() *State { return NewState(Option{StackSize: 100, CallInfoSize: 10}) },
+			"(define (recur . args) (if (= (car args) 1) 1 (apply apply recur (list (list (- (car args) 1)))))) (recur 100)",
+			"1",
+		},
 	}
 	for i, tc := range testcases {
 		s := tc.stateFactory()
@@ -73,3 +84,68 @@ func TestComment(t *testing.T) {
 		}
 	}
 }
+
+func TestCall(t *testing.T) {
+	s := NewState(Option{})
+	if err := s.ExecString("(define (add a b) (+ a b))"); err != nil {
+		t.Fatal(err)
+	}
+	add, _ := s.GetGlobal("add")
+	v, err := s.Call(add, types.Number(1), types.Number(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "3" {
+		t.Fatalf("expected %s, but got %s", "3", v.String())
+	}
+	sp := s.CallStack.Sp()
+	if _, err := s.Call(add, types.Number(1), types.NilObject); err == nil {
+		t.Fatalf("expected error")
+	}
+	if s.CallStack.Sp() != sp {
+		t.Fatalf("expected %d, but got %d", sp, s.CallStack.Sp())
+	}
+	car, _ := s.GetGlobal("car")
+	v, err = s.Call(car, types.List(types.Number(5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "5" {
+		t.Fatalf("expected %s, but got %s", "5", v.String())
+	}
+}
+
+func TestCallApply(t *testing.T) {
+	s := NewState(Option{})
+	apply, _ := s.GetGlobal("apply")
+	add, _ := s.GetGlobal("+")
+	v, err := s.Call(apply, add, types.Number(1), types.List(types.Number(2), types.Number(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "6" {
+		t.Fatalf("expected %s, but got %s", "6", v.String())
+	}
+}
+
+func TestSourcePosition(t *testing.T) {
+	for _, tc := range []struct {
+		filename string
+		src      string
+		prefix   string
+	}{
+		{"rules.scm", "(define x 1)\n(car x)", "rules.scm:2:1: car: "},
+		{"rules.scm", "(define (f x)\n  (+ 1\n     (car x)))\n(f 2)", "rules.scm:3:6: car: "},
+		{"rules.scm", "(display\n  (list 1 undefined-var))", "rules.scm:2:3: unbound symbol"},
+		{"rules.scm", "(define x 1)\n  (if)", "rules.scm:2:3: "},
+		{"rules.scm", "(define x 1)\n (1 2", "rules.scm:2:"},
+		{"", "\n(car 1)", "2:1: car: "},
+		{"rules.scm", "(map (lambda (x)\n  (car x)) '(1))", "rules.scm:2:3: map: car: "},
+	} {
+		s := NewState(Option{})
+		err := s.ExecSource(tc.filename, tc.src)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.prefix) {
+			t.Fatalf("expected an error starting with %q, but got %v\nsrc: %s", tc.prefix, err, tc.src)
+		}
+	}
+}
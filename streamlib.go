@@ -0,0 +1,375 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"math"
+)
+
+// OpenStream registers the stream library. (SRFI-41)
+//
+// A stream is a promise which is forced to either the empty list (stream-null)
+// or a stream pair. stream-cons, stream-lambda and define-stream are compiled
+// by the compiler.
+func (s *State) OpenStream() *State {
+	s.registerSyntax("stream-cons", types.NewSyntax("stream-cons", nil))
+	s.registerSyntax("stream-lambda", types.NewSyntax("stream-lambda", nil))
+	s.registerSyntax("define-stream", types.NewSyntax("define-stream", nil))
+
+	s.SetGlobal("stream-null", streamNull())
+	s.RegisterFunc("stream?", 1, 1, fnIsStream)
+	s.RegisterFunc("stream-null?", 1, 1, fnIsStreamNull)
+	s.RegisterFunc("stream-pair?", 1, 1, fnIsStreamPair)
+	s.RegisterFunc("stream-car", 1, 1, fnStreamCar)
+	s.RegisterFunc("stream-cdr", 1, 1, fnStreamCdr)
+	s.RegisterFunc("stream", 0, -1, fnStream)
+	s.RegisterFunc("list->stream", 1, 1, fnListToStream)
+	s.RegisterFunc("stream->list", 1, 2, fnStreamToList)
+	s.RegisterFunc("stream-range", 2, 3, fnStreamRange)
+	s.RegisterFunc("stream-map", 2, -1, fnStreamMap)
+	s.RegisterFunc("stream-filter", 2, 2, fnStreamFilter)
+	s.RegisterFunc("stream-take", 2, 2, fnStreamTake)
+	s.RegisterFunc("stream-drop", 2, 2, fnStreamDrop)
+	s.RegisterFunc("stream-ref", 2, 2, fnStreamRef)
+	s.RegisterFunc("stream-fold", 3, 3, fnStreamFold)
+	s.RegisterFunc("stream-for-each", 2, 2, fnStreamForEach)
+	return s
+}
+
+func streamNull() *types.Promise {
+	return types.NewForcedPromise(types.NilObject)
+}
+
+func streamCons(car *types.Promise, cdr *types.Promise) *types.Promise {
+	return types.NewForcedPromise(&types.StreamPair{Car: car, Cdr: cdr})
+}
+
+// lazyStream creates a stream which is computed by next when it is forced.
+// next returns a stream, which is forced in turn like delay-force.
+func lazyStream(name string, next func(s *State) (types.Object, error)) *types.Promise {
+	var fn GoFunc = func(s *State, args []types.Object) (types.Object, error) {
+		return next(s)
+	}
+	return types.NewPromise(types.NewGoClosure(name, 0, 0, fn), true)
+}
+
+// forceStream forces obj and returns its stream pair, or nil if obj is stream-null.
+func (s *State) forceStream(obj types.Object) (*types.StreamPair, error) {
+	if err := types.AssertType(types.TyPromise, obj); err != nil {
+		return nil, err
+	}
+	v, err := s.force(obj)
+	if err != nil {
+		return nil, err
+	}
+	switch o := v.(type) {
+	case *types.StreamPair:
+		return o, nil
+	case *types.Nil:
+		return nil, nil
+	}
+	return nil, types.NewTypeError("stream required, but got %v", v)
+}
+
+func fnIsStream(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyPromise), nil
+}
+
+func fnIsStreamNull(s *State, args []types.Object) (types.Object, error) {
+	if args[0].Type() != types.TyPromise {
+		return types.Boolean(false), nil
+	}
+	v, err := s.force(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean(types.IsNull(v)), nil
+}
+
+func fnIsStreamPair(s *State, args []types.Object) (types.Object, error) {
+	if args[0].Type() != types.TyPromise {
+		return types.Boolean(false), nil
+	}
+	v, err := s.force(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Boolean(v.Type() == types.TyStreamPair), nil
+}
+
+// streamPair is the same as forceStream, but stream-null is an error.
+func (s *State) streamPair(obj types.Object) (*types.StreamPair, error) {
+	sp, err := s.forceStream(obj)
+	if err != nil {
+		return nil, err
+	}
+	if sp == nil {
+		return nil, types.NewTypeError("non-empty stream required, but got stream-null")
+	}
+	return sp, nil
+}
+
+func fnStreamCar(s *State, args []types.Object) (types.Object, error) {
+	sp, err := s.streamPair(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return s.force(sp.Car)
+}
+
+func fnStreamCdr(s *State, args []types.Object) (types.Object, error) {
+	sp, err := s.streamPair(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return sp.Cdr, nil
+}
+
+// sliceToStream creates a stream of objs.
+func sliceToStream(objs []types.Object) *types.Promise {
+	stream := streamNull()
+	for i := len(objs) - 1; i >= 0; i-- {
+		stream = streamCons(types.NewForcedPromise(objs[i]), stream)
+	}
+	return stream
+}
+
+// (stream obj ...)
+func fnStream(s *State, args []types.Object) (types.Object, error) {
+	return sliceToStream(args), nil
+}
+
+func fnListToStream(s *State, args []types.Object) (types.Object, error) {
+	elems, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return sliceToStream(elems), nil
+}
+
+// (stream->list stream [n])
+func fnStreamToList(s *State, args []types.Object) (types.Object, error) {
+	n := math.MaxInt32
+	if len(args) > 1 {
+		var err error
+		if n, err = toIndex(args[1], math.MaxInt32); err != nil {
+			return nil, err
+		}
+	}
+	elems := []types.Object{}
+	stream := args[0]
+	for len(elems) < n {
+		sp, err := s.forceStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		if sp == nil {
+			break
+		}
+		v, err := s.force(sp.Car)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+		stream = sp.Cdr
+	}
+	return types.List(elems...), nil
+}
+
+// (stream-range first past [step])
+func fnStreamRange(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyNumber, args...); err != nil {
+		return nil, err
+	}
+	first, past := args[0].(types.Number), args[1].(types.Number)
+	step := types.Number(1)
+	if first > past {
+		step = -1
+	}
+	if len(args) > 2 {
+		step = args[2].(types.Number)
+	}
+	return streamRange(first, past, step), nil
+}
+
+func streamRange(first, past, step types.Number) *types.Promise {
+	return lazyStream("stream-range", func(s *State) (types.Object, error) {
+		if (step >= 0 && first >= past) || (step < 0 && first <= past) {
+			return streamNull(), nil
+		}
+		return streamCons(types.NewForcedPromise(first), streamRange(first+step, past, step)), nil
+	})
+}
+
+// (stream-map proc stream1 stream2 ...)
+func fnStreamMap(s *State, args []types.Object) (types.Object, error) {
+	return streamMap(args[0], args[1:]), nil
+}
+
+func streamMap(proc types.Object, streams []types.Object) *types.Promise {
+	return lazyStream("stream-map", func(s *State) (types.Object, error) {
+		cars := make([]*types.Promise, len(streams))
+		cdrs := make([]types.Object, len(streams))
+		for i, stream := range streams {
+			sp, err := s.forceStream(stream)
+			if err != nil {
+				return nil, err
+			}
+			if sp == nil {
+				return streamNull(), nil
+			}
+			cars[i], cdrs[i] = sp.Car, sp.Cdr
+		}
+		car := types.NewPromise(types.NewGoClosure("stream-map", 0, 0, GoFunc(func(s *State, _ []types.Object) (types.Object, error) {
+			vals := make([]types.Object, len(cars))
+			for i, car := range cars {
+				v, err := s.force(car)
+				if err != nil {
+					return nil, err
+				}
+				vals[i] = v
+			}
+			return s.Call(proc, vals...)
+		})), false)
+		return streamCons(car, streamMap(proc, cdrs)), nil
+	})
+}
+
+// (stream-filter pred stream)
+func fnStreamFilter(s *State, args []types.Object) (types.Object, error) {
+	return streamFilter(args[0], args[1]), nil
+}
+
+func streamFilter(pred types.Object, stream types.Object) *types.Promise {
+	return lazyStream("stream-filter", func(s *State) (types.Object, error) {
+		// skip the elements which do not satisfy pred without recursion
+		for {
+			sp, err := s.forceStream(stream)
+			if err != nil {
+				return nil, err
+			}
+			if sp == nil {
+				return streamNull(), nil
+			}
+			v, err := s.force(sp.Car)
+			if err != nil {
+				return nil, err
+			}
+			ok, err := s.Call(pred, v)
+			if err != nil {
+				return nil, err
+			}
+			if types.IsTruthy(ok) {
+				return streamCons(sp.Car, streamFilter(pred, sp.Cdr)), nil
+			}
+			stream = sp.Cdr
+		}
+	})
+}
+
+// (stream-take n stream)
+func fnStreamTake(s *State, args []types.Object) (types.Object, error) {
+	n, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	return streamTake(n, args[1]), nil
+}
+
+func streamTake(n int, stream types.Object) *types.Promise {
+	return lazyStream("stream-take", func(s *State) (types.Object, error) {
+		if n == 0 {
+			return streamNull(), nil
+		}
+		sp, err := s.forceStream(stream)
+		if err != nil || sp == nil {
+			return streamNull(), err
+		}
+		return streamCons(sp.Car, streamTake(n-1, sp.Cdr)), nil
+	})
+}
+
+// (stream-drop n stream)
+func fnStreamDrop(s *State, args []types.Object) (types.Object, error) {
+	n, err := toIndex(args[0], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	stream := args[1]
+	return lazyStream("stream-drop", func(s *State) (types.Object, error) {
+		for ; n > 0; n-- {
+			sp, err := s.forceStream(stream)
+			if err != nil || sp == nil {
+				return streamNull(), err
+			}
+			stream = sp.Cdr
+		}
+		return stream, nil
+	}), nil
+}
+
+// (stream-ref stream k)
+func fnStreamRef(s *State, args []types.Object) (types.Object, error) {
+	k, err := toIndex(args[1], math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	stream := args[0]
+	for i := 0; ; i++ {
+		sp, err := s.forceStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		if sp == nil {
+			return nil, types.NewInternalError("index out of range: %d", k)
+		}
+		if i == k {
+			return s.force(sp.Car)
+		}
+		stream = sp.Cdr
+	}
+}
+
+// (stream-fold proc base stream)
+// proc is called as (proc acc elem).
+func fnStreamFold(s *State, args []types.Object) (types.Object, error) {
+	acc := args[1]
+	err := s.streamForEach(args[2], func(v types.Object) error {
+		next, err := s.Call(args[0], acc, v)
+		acc = next
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// (stream-for-each proc stream)
+func fnStreamForEach(s *State, args []types.Object) (types.Object, error) {
+	err := s.streamForEach(args[1], func(v types.Object) error {
+		_, err := s.Call(args[0], v)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
+
+// streamForEach calls fn with each element of stream.
+func (s *State) streamForEach(stream types.Object, fn func(v types.Object) error) error {
+	for {
+		sp, err := s.forceStream(stream)
+		if err != nil || sp == nil {
+			return err
+		}
+		v, err := s.force(sp.Car)
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+		stream = sp.Cdr
+	}
+}
@@ -0,0 +1,91 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestDelay(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(force (delay (+ 1 2)))", expect: "3"},
+		&tcase{src: "(define n 0) (define p (delay (begin (set! n (+ n 1)) n))) (force p) (force p) n", expect: "1"},
+		&tcase{src: "(promise? (delay 1))", expect: "#t"},
+		&tcase{src: "(promise? 1)", expect: "#f"},
+		&tcase{src: "(force 5)", expect: "5"},
+		&tcase{src: "(force (make-promise 7))", expect: "7"},
+		&tcase{src: "(define p (delay 1)) (eq? p (make-promise p))", expect: "#t"},
+		&tcase{src: "(force (delay-force (delay 4)))", expect: "4"},
+		&tcase{src: "(force (delay-force 4))", expectErr: true},
+		// R7RS: a promise forced while it is being forced keeps the first value
+		&tcase{src: "(define x 5) (define p (delay (begin (set! x (+ x 1)) (if (> x 6) x (force p))))) (force p)", expect: "7"},
+	}
+	testTcases(t, tcases)
+}
+
+func TestDelayForceLoop(t *testing.T) {
+	s := NewState(Option{StackSize: 100, CallInfoSize: 16})
+	src := "(define (loop n) (delay-force (if (= n 0) (delay 'done) (loop (- n 1))))) (force (loop 1000000))"
+	if err := s.ExecString(src); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.CallStack.Top(); v.String() != "done" {
+		t.Fatalf("expected %s, but got %s", "done", v.String())
+	}
+}
+
+func TestFnStream(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(stream-car (stream-cons 1 stream-null))", expect: "1"},
+		&tcase{src: "(stream-null? (stream-cdr (stream-cons 1 stream-null)))", expect: "#t"},
+		&tcase{src: "(stream-pair? (stream-cons 1 stream-null))", expect: "#t"},
+		&tcase{src: "(stream? stream-null)", expect: "#t"},
+		&tcase{src: "(stream-pair? 1)", expect: "#f"},
+		&tcase{src: "(stream-car stream-null)", expectErr: true},
+		&tcase{src: "(equal? (stream->list (stream 1 2 3)) (list 1 2 3))", expect: "#t"},
+		&tcase{src: "(equal? (stream->list (list->stream (list 1 2 3)) 2) (list 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (stream->list (stream-range 0 3)) (list 0 1 2))", expect: "#t"},
+		&tcase{src: "(equal? (stream->list (stream-range 3 0)) (list 3 2 1))", expect: "#t"},
+		&tcase{src: "(stream-ref (stream 1 2 3) 2)", expect: "3"},
+		&tcase{src: "(stream-ref (stream 1 2 3) 3)", expectErr: true},
+		&tcase{src: "(stream-fold + 0 (stream 1 2 3))", expect: "6"},
+		&tcase{src: "(define n 0) (stream-for-each (lambda (x) (set! n (+ n x))) (stream 1 2)) n", expect: "3"},
+		// the elements are not evaluated until they are needed
+		&tcase{src: "(stream-car (stream-cdr (stream-cons (car '()) (stream-cons 2 stream-null))))", expect: "2"},
+	}
+	testTcases(t, tcases, (*State).OpenStream)
+}
+
+func TestFnStreamOperations(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: "(define-stream (from n) (stream-cons n (from (+ n 1)))) (equal? (stream->list (stream-take 3 (from 5))) (list 5 6 7))", expect: "#t"},
+		&tcase{src: "(define-stream (from n) (stream-cons n (from (+ n 1)))) (equal? (stream->list (stream-drop 2 (stream-take 4 (from 0)))) (list 2 3))", expect: "#t"},
+		&tcase{src: "(equal? (stream->list (stream-map + (stream 1 2 3) (stream 10 20))) (list 11 22))", expect: "#t"},
+		&tcase{src: "(equal? (stream->list (stream-filter (lambda (x) (< x 2)) (stream 3 1 2 0))) (list 1 0))", expect: "#t"},
+		&tcase{src: "(define s (stream-lambda (a . rest) (stream-cons a stream-null))) (stream-car (s 1 2))", expect: "1"},
+		&tcase{src: "(define-stream (f . args) (list->stream args)) (stream-ref (f 1 2) 1)", expect: "2"},
+		&tcase{src: "(stream-cons 1)", expectErr: true},
+		&tcase{src: "(stream-car (stream-map car (stream 1)))", expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenStream)
+}
+
+func TestStreamLarge(t *testing.T) {
+	tcases := []*tcase{
+		// iterating long streams must not grow the stacks
+		&tcase{
+			src:    "(define-stream (from n) (stream-cons n (from (+ n 1)))) (= (stream-ref (stream-filter (lambda (x) (= x 100000)) (from 0)) 0) 100000)",
+			expect: "#t",
+			option: Option{StackSize: 100, CallInfoSize: 16},
+		},
+		&tcase{
+			src:    "(define-stream (sfilter pred s) (if (pred (stream-car s)) (stream-cons (stream-car s) (sfilter pred (stream-cdr s))) (sfilter pred (stream-cdr s)))) (= (stream-car (sfilter (lambda (x) (= x 100000)) (stream-range 0 200000))) 100000)",
+			expect: "#t",
+			option: Option{StackSize: 100, CallInfoSize: 16},
+		},
+		&tcase{
+			src:    "(= (stream-fold + 0 (stream-map (lambda (x) 1) (stream-take 100000 (stream-range 0 200000)))) 100000)",
+			expect: "#t",
+			option: Option{StackSize: 100, CallInfoSize: 16},
+		},
+	}
+	testTcases(t, tcases, (*State).OpenStream)
+}
@@ -0,0 +1,470 @@
+package tama
+
+import (
+	"github.com/hyusuk/tama/types"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OpenString registers the string library.
+// It provides the string procedures of R7RS which are not in the base library,
+// and utilities from SRFI-13 and SRFI-130.
+func (s *State) OpenString() *State {
+	s.RegisterFunc("string?", 1, 1, fnIsStr)
+	s.RegisterFunc("string-append", 0, -1, fnStrAppend)
+	s.RegisterFunc("string-copy", 1, 3, fnStrCopy)
+	s.RegisterFunc("string=?", 2, -1, genFnStrComp("string=?", false))
+	s.RegisterFunc("string<?", 2, -1, genFnStrComp("string<?", false))
+	s.RegisterFunc("string>?", 2, -1, genFnStrComp("string>?", false))
+	s.RegisterFunc("string<=?", 2, -1, genFnStrComp("string<=?", false))
+	s.RegisterFunc("string>=?", 2, -1, genFnStrComp("string>=?", false))
+	s.RegisterFunc("string-ci=?", 2, -1, genFnStrComp("string=?", true))
+	s.RegisterFunc("string-ci<?", 2, -1, genFnStrComp("string<?", true))
+	s.RegisterFunc("string-ci>?", 2, -1, genFnStrComp("string>?", true))
+	s.RegisterFunc("string-ci<=?", 2, -1, genFnStrComp("string<=?", true))
+	s.RegisterFunc("string-ci>=?", 2, -1, genFnStrComp("string>=?", true))
+	s.RegisterFunc("string-upcase", 1, 1, fnStrUpcase)
+	s.RegisterFunc("string-downcase", 1, 1, fnStrDowncase)
+	s.RegisterFunc("string->list", 1, 3, fnStrToList)
+	s.RegisterFunc("list->string", 1, 1, fnListToStr)
+	s.RegisterFunc("string-index", 2, 4, fnStrIndex)
+	s.RegisterFunc("string-contains", 2, 4, fnStrContains)
+	s.RegisterFunc("string-prefix?", 2, 2, fnStrIsPrefix)
+	s.RegisterFunc("string-suffix?", 2, 2, fnStrIsSuffix)
+	s.RegisterFunc("string-join", 1, 3, fnStrJoin)
+	s.RegisterFunc("string-split", 2, 3, fnStrSplit)
+	s.RegisterFunc("string-trim", 1, 4, genFnStrTrim(true, false))
+	s.RegisterFunc("string-trim-left", 1, 4, genFnStrTrim(true, false))
+	s.RegisterFunc("string-trim-right", 1, 4, genFnStrTrim(false, true))
+	s.RegisterFunc("string-trim-both", 1, 4, genFnStrTrim(true, true))
+	s.RegisterFunc("string-pad", 2, 5, genFnStrPad(true))
+	s.RegisterFunc("string-pad-right", 2, 5, genFnStrPad(false))
+	s.RegisterFunc("string-map", 2, -1, fnStrMap)
+	s.RegisterFunc("string-for-each", 2, -1, fnStrForEach)
+	return s
+}
+
+func fnIsStr(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(types.IsString(args[0])), nil
+}
+
+func fnStrAppend(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args...); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(arg.(*types.String).String())
+	}
+	return types.NewString(b.String()), nil
+}
+
+func fnStrCopy(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[1:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	return types.NewString(str.Substring(start, end)), nil
+}
+
+// foldString returns the case folding of str used to compare strings case-insensitively.
+// Each character is folded to the lower case of its upper case, so that the variants
+// such as final sigma are folded together, and sharp s is expanded to ss.
+func foldString(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		if r == 'ß' || r == 'ẞ' {
+			b.WriteString("ss")
+			continue
+		}
+		b.WriteRune(unicode.ToLower(unicode.ToUpper(r)))
+	}
+	return b.String()
+}
+
+func genFnStrComp(name string, ci bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyString, args...); err != nil {
+			return nil, err
+		}
+		key := func(obj types.Object) string {
+			str := obj.(*types.String).String()
+			if ci {
+				return foldString(str)
+			}
+			return str
+		}
+		prev := key(args[0])
+		var yes bool
+		for _, arg := range args[1:] {
+			next := key(arg)
+			// comparing utf-8 strings is the same as comparing their code points.
+			switch name {
+			case "string=?":
+				yes = prev == next
+			case "string<?":
+				yes = prev < next
+			case "string>?":
+				yes = prev > next
+			case "string<=?":
+				yes = prev <= next
+			case "string>=?":
+				yes = prev >= next
+			}
+			prev = next
+			if !yes {
+				return types.Boolean(false), nil
+			}
+		}
+		return types.Boolean(true), nil
+	}
+}
+
+func fnStrUpcase(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	return types.NewString(strings.ToUpper(args[0].String())), nil
+}
+
+func fnStrDowncase(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	return types.NewString(strings.ToLower(args[0].String())), nil
+}
+
+func fnStrToList(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[1:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	chars := make([]types.Object, 0, end-start)
+	for _, r := range str.Runes(start, end) {
+		chars = append(chars, types.Char(r))
+	}
+	return types.List(chars...), nil
+}
+
+func fnListToStr(s *State, args []types.Object) (types.Object, error) {
+	chars, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return fnStr(s, chars)
+}
+
+// charMatcher returns a function that reports whether a character satisfies criterion.
+// criterion is a character or a predicate procedure.
+func (s *State) charMatcher(criterion types.Object) (func(types.Char) (bool, error), error) {
+	switch c := criterion.(type) {
+	case types.Char:
+		return func(ch types.Char) (bool, error) {
+			return ch == c, nil
+		}, nil
+	case *types.Closure:
+		return func(ch types.Char) (bool, error) {
+			v, err := s.Call(c, ch)
+			if err != nil {
+				return false, err
+			}
+			return types.IsTruthy(v), nil
+		}, nil
+	}
+	return nil, types.NewTypeError("char or procedure required, but got %v", criterion)
+}
+
+// (string-index s pred [start end])
+func fnStrIndex(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	match, err := s.charMatcher(args[1])
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := toRange(args[2:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	for i := start; i < end; i++ {
+		ok, err := match(str.Ref(i))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return types.Number(i), nil
+		}
+	}
+	return types.Boolean(false), nil
+}
+
+// (string-contains s1 s2 [start end])
+// Returns the index in s1 where s2 occurs first, or #f.
+func fnStrContains(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0], args[1]); err != nil {
+		return nil, err
+	}
+	str := args[0].(*types.String)
+	start, end, err := toRange(args[2:], str.Len())
+	if err != nil {
+		return nil, err
+	}
+	sub := str.Substring(start, end)
+	i := strings.Index(sub, args[1].String())
+	if i < 0 {
+		return types.Boolean(false), nil
+	}
+	return types.Number(start + utf8.RuneCountInString(sub[:i])), nil
+}
+
+// (string-prefix? prefix s)
+func fnStrIsPrefix(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args...); err != nil {
+		return nil, err
+	}
+	return types.Boolean(strings.HasPrefix(args[1].String(), args[0].String())), nil
+}
+
+// (string-suffix? suffix s)
+func fnStrIsSuffix(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args...); err != nil {
+		return nil, err
+	}
+	return types.Boolean(strings.HasSuffix(args[1].String(), args[0].String())), nil
+}
+
+// (string-join string-list [delimiter [grammar]])
+// grammar is one of infix (default), strict-infix, prefix and suffix.
+func fnStrJoin(s *State, args []types.Object) (types.Object, error) {
+	strs, err := toSlice(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := types.AssertType(types.TyString, strs...); err != nil {
+		return nil, err
+	}
+	delim := " "
+	if len(args) > 1 {
+		if err := types.AssertType(types.TyString, args[1]); err != nil {
+			return nil, err
+		}
+		delim = args[1].String()
+	}
+	grammar := "infix"
+	if len(args) > 2 {
+		if err := types.AssertType(types.TySymbol, args[2]); err != nil {
+			return nil, err
+		}
+		grammar = args[2].String()
+	}
+	elems := make([]string, len(strs))
+	for i, str := range strs {
+		elems[i] = str.String()
+	}
+	joined := strings.Join(elems, delim)
+	switch grammar {
+	case "infix":
+	case "strict-infix":
+		if len(elems) == 0 {
+			return nil, types.NewInternalError("empty list cannot be joined with the strict-infix grammar")
+		}
+	case "prefix":
+		if len(elems) > 0 {
+			joined = delim + joined
+		}
+	case "suffix":
+		if len(elems) > 0 {
+			joined = joined + delim
+		}
+	default:
+		return nil, types.NewInternalError("unknown grammar %s", grammar)
+	}
+	return types.NewString(joined), nil
+}
+
+// (string-split s delimiter [limit])
+// delimiter is a character or a string. If limit is given, s is split at most limit times.
+func fnStrSplit(s *State, args []types.Object) (types.Object, error) {
+	if err := types.AssertType(types.TyString, args[0]); err != nil {
+		return nil, err
+	}
+	var delim string
+	switch d := args[1].(type) {
+	case types.Char, *types.String:
+		delim = d.String()
+	default:
+		return nil, types.NewTypeError("char or string required, but got %v", d)
+	}
+	n := -1
+	if len(args) > 2 {
+		limit, err := toIndex(args[2], math.MaxInt32)
+		if err != nil {
+			return nil, err
+		}
+		n = limit + 1
+	}
+	fields := strings.SplitN(args[0].String(), delim, n)
+	objs := make([]types.Object, len(fields))
+	for i, f := range fields {
+		objs[i] = types.NewString(f)
+	}
+	return types.List(objs...), nil
+}
+
+// genFnStrTrim generates (string-trim s [criterion [start end]]).
+// criterion defaults to whitespaces.
+func genFnStrTrim(left, right bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyString, args[0]); err != nil {
+			return nil, err
+		}
+		str := args[0].(*types.String)
+		match := func(ch types.Char) (bool, error) {
+			return unicode.IsSpace(rune(ch)), nil
+		}
+		if len(args) > 1 {
+			var err error
+			if match, err = s.charMatcher(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		var rangeArgs []types.Object
+		if len(args) > 2 {
+			rangeArgs = args[2:]
+		}
+		start, end, err := toRange(rangeArgs, str.Len())
+		if err != nil {
+			return nil, err
+		}
+		for left && start < end {
+			ok, err := match(str.Ref(start))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			start++
+		}
+		for right && start < end {
+			ok, err := match(str.Ref(end - 1))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			end--
+		}
+		return types.NewString(str.Substring(start, end)), nil
+	}
+}
+
+// genFnStrPad generates (string-pad s n [char [start end]]).
+// If left is true, s is padded or truncated on the left.
+func genFnStrPad(left bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		if err := types.AssertType(types.TyString, args[0]); err != nil {
+			return nil, err
+		}
+		str := args[0].(*types.String)
+		n, err := toIndex(args[1], math.MaxInt32)
+		if err != nil {
+			return nil, err
+		}
+		ch := types.Char(' ')
+		if len(args) > 2 {
+			if err := types.AssertType(types.TyChar, args[2]); err != nil {
+				return nil, err
+			}
+			ch = args[2].(types.Char)
+		}
+		var rangeArgs []types.Object
+		if len(args) > 3 {
+			rangeArgs = args[3:]
+		}
+		start, end, err := toRange(rangeArgs, str.Len())
+		if err != nil {
+			return nil, err
+		}
+		runes := str.Runes(start, end)
+		if len(runes) >= n {
+			if left {
+				return types.NewStringFromRunes(runes[len(runes)-n:]), nil
+			}
+			return types.NewStringFromRunes(runes[:n]), nil
+		}
+		pad := []rune(strings.Repeat(string(rune(ch)), n-len(runes)))
+		if left {
+			return types.NewStringFromRunes(append(pad, runes...)), nil
+		}
+		return types.NewStringFromRunes(append(runes, pad...)), nil
+	}
+}
+
+// mapStrings calls fn with the i-th characters of strs, for i from 0 to the length of the shortest string.
+func mapStrings(strs []types.Object, fn func(chars []types.Object) error) error {
+	if err := types.AssertType(types.TyString, strs...); err != nil {
+		return err
+	}
+	n := math.MaxInt32
+	for _, str := range strs {
+		if l := str.(*types.String).Len(); l < n {
+			n = l
+		}
+	}
+	for i := 0; i < n; i++ {
+		chars := make([]types.Object, len(strs))
+		for j, str := range strs {
+			chars[j] = str.(*types.String).Ref(i)
+		}
+		if err := fn(chars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// (string-map proc string1 string2 ...)
+func fnStrMap(s *State, args []types.Object) (types.Object, error) {
+	var runes []rune
+	err := mapStrings(args[1:], func(chars []types.Object) error {
+		v, err := s.Call(args[0], chars...)
+		if err != nil {
+			return err
+		}
+		if err := types.AssertType(types.TyChar, v); err != nil {
+			return err
+		}
+		runes = append(runes, rune(v.(types.Char)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewStringFromRunes(runes), nil
+}
+
+// (string-for-each proc string1 string2 ...)
+func fnStrForEach(s *State, args []types.Object) (types.Object, error) {
+	err := mapStrings(args[1:], func(chars []types.Object) error {
+		_, err := s.Call(args[0], chars...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.UndefinedObject, nil
+}
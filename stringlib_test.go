@@ -0,0 +1,117 @@
+package tama
+
+import (
+	"testing"
+)
+
+func TestFnStrAppend(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-append "ab" "cd" "é")`, expect: "abcdé"},
+		&tcase{src: `(string-append)`, expect: ""},
+		&tcase{src: `(string-append "a" 1)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrCopy(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define s (string-copy "abc")) (string-set! s 0 #\z) s`, expect: "zbc"},
+		&tcase{src: `(string-copy "héllo" 1)`, expect: "éllo"},
+		&tcase{src: `(string-copy "héllo" 1 2)`, expect: "é"},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrComp(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string=? "abc" "abc" "abc")`, expect: "#t"},
+		&tcase{src: `(string=? "abc" "abd")`, expect: "#f"},
+		&tcase{src: `(string<? "abc" "abd" "b")`, expect: "#t"},
+		&tcase{src: `(string>? "b" "a" "a")`, expect: "#f"},
+		&tcase{src: `(string<=? "a" "a" "b")`, expect: "#t"},
+		&tcase{src: `(string>=? "b" "c")`, expect: "#f"},
+		&tcase{src: `(string-ci=? "ABC" "abc")`, expect: "#t"},
+		&tcase{src: `(string-ci<? "a" "B")`, expect: "#t"},
+		&tcase{src: `(string-ci=? "Straße" "STRASSE" "strasse")`, expect: "#t"},
+		&tcase{src: `(string-ci=? "ΟΔΟΣ" "οδος" "οδοσ")`, expect: "#t"},
+		&tcase{src: `(string-ci=? "ΣΑΣ" "σας")`, expect: "#t"},
+		&tcase{src: `(string-ci<? "Ä" "ä")`, expect: "#f"},
+		&tcase{src: `(string-ci=? "ÄB" "äc")`, expect: "#f"},
+		&tcase{src: `(string=? "a" 'a)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrCase(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-upcase "héllo")`, expect: "HÉLLO"},
+		&tcase{src: `(string-downcase "HÉLLO")`, expect: "héllo"},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrToList(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(car (cdr (string->list "héllo")))`, expect: "é"},
+		&tcase{src: `(car (string->list "héllo" 2 4))`, expect: "l"},
+		&tcase{src: `(list->string (string->list "héllo"))`, expect: "héllo"},
+		&tcase{src: `(list->string '(1 2))`, expectErr: true},
+		&tcase{src: `(list->string 1)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrSearch(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-index "héllo" #\l)`, expect: "2"},
+		&tcase{src: `(string-index "héllo" (lambda (c) (string=? (string c) "o")))`, expect: "4"},
+		&tcase{src: `(string-index "hello" #\z)`, expect: "#f"},
+		&tcase{src: `(string-index "hello" #\l 3)`, expect: "3"},
+		&tcase{src: `(string-contains "héllo world" "wor")`, expect: "6"},
+		&tcase{src: `(string-contains "hello" "z")`, expect: "#f"},
+		&tcase{src: `(string-prefix? "hé" "héllo")`, expect: "#t"},
+		&tcase{src: `(string-prefix? "lo" "héllo")`, expect: "#f"},
+		&tcase{src: `(string-suffix? "lo" "héllo")`, expect: "#t"},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrJoinSplit(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-join '("a" "b" "c"))`, expect: "a b c"},
+		&tcase{src: `(string-join '("a" "b" "c") ", ")`, expect: "a, b, c"},
+		&tcase{src: `(string-join '("a" "b") "/" 'prefix)`, expect: "/a/b"},
+		&tcase{src: `(string-join '("a" "b") "/" 'suffix)`, expect: "a/b/"},
+		&tcase{src: `(string-join '() "/" 'strict-infix)`, expectErr: true},
+		&tcase{src: `(car (cdr (string-split "a,b,c" #\,)))`, expect: "b"},
+		&tcase{src: `(car (cdr (string-split "a::b::c" "::" 1)))`, expect: "b::c"},
+		&tcase{src: `(string-join (string-split "a b c" #\space) "-")`, expect: "a-b-c"},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrTrimPad(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-trim "  abc  ")`, expect: "abc  "},
+		&tcase{src: `(string-trim-right "  abc  ")`, expect: "  abc"},
+		&tcase{src: `(string-trim-both "  abc  ")`, expect: "abc"},
+		&tcase{src: `(string-trim-both "xxabcxx" #\x)`, expect: "abc"},
+		&tcase{src: `(string-pad "42" 5)`, expect: "   42"},
+		&tcase{src: `(string-pad "42" 5 #\0)`, expect: "00042"},
+		&tcase{src: `(string-pad "12345" 3)`, expect: "345"},
+		&tcase{src: `(string-pad-right "42" 4 #\.)`, expect: "42.."},
+		&tcase{src: `(string-pad-right "12345" 3)`, expect: "123"},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
+
+func TestFnStrMap(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(string-map (lambda (c) (string-ref (string-upcase (string c)) 0)) "héllo")`, expect: "HÉLLO"},
+		&tcase{src: `(string-map (lambda (a b) b) "abc" "xy")`, expect: "xy"},
+		&tcase{src: `(define n 0) (string-for-each (lambda (c) (set! n (+ n 1))) "héllo") n`, expect: "5"},
+		&tcase{src: `(string-map (lambda (c) 1) "abc")`, expectErr: true},
+		&tcase{src: `(string-for-each (lambda (c) (car c)) "abc")`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenString)
+}
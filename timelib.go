@@ -0,0 +1,767 @@
+package tama
+
+import (
+	"fmt"
+	"github.com/hyusuk/tama/types"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// jiffiesPerSecond is the resolution of current-jiffy. Jiffies are microseconds
+// so that they are exact as numbers for centuries.
+const jiffiesPerSecond = 1000000
+
+// OpenTime registers the time library. (SRFI-19, and current-second, current-jiffy
+// and jiffies-per-second of R7RS)
+//
+// The current time is given by Option.Clock. Times are in nanosecond resolution.
+// Leap seconds are not taken into account, so time-monotonic is the same as time-utc
+// and time-tai is not supported.
+func (s *State) OpenTime() *State {
+	for _, typ := range []types.TimeType{types.TimeUTC, types.TimeMonotonic, types.TimeDuration} {
+		s.SetGlobal(string(typ), types.NewSymbol(string(typ)))
+	}
+	s.RegisterFunc("current-second", 0, 0, fnCurrentSecond)
+	s.RegisterFunc("current-jiffy", 0, 0, fnCurrentJiffy)
+	s.RegisterFunc("jiffies-per-second", 0, 0, fnJiffiesPerSecond)
+	s.RegisterFunc("current-time", 0, 1, fnCurrentTime)
+	s.RegisterFunc("current-date", 0, 1, fnCurrentDate)
+	s.RegisterFunc("time-resolution", 0, 1, fnTimeResolution)
+	s.RegisterFunc("make-time", 3, 3, fnMakeTime)
+	s.RegisterFunc("time?", 1, 1, fnIsTime)
+	s.RegisterFunc("time-type", 1, 1, fnTimeType)
+	s.RegisterFunc("time-second", 1, 1, fnTimeSecond)
+	s.RegisterFunc("time-nanosecond", 1, 1, fnTimeNanosecond)
+	s.RegisterFunc("set-time-type!", 2, 2, fnSetTimeType)
+	s.RegisterFunc("set-time-second!", 2, 2, fnSetTimeSecond)
+	s.RegisterFunc("set-time-nanosecond!", 2, 2, fnSetTimeNanosecond)
+	s.RegisterFunc("copy-time", 1, 1, fnCopyTime)
+	s.RegisterFunc("time=?", 2, 2, genFnTimeCompare(func(c int) bool { return c == 0 }))
+	s.RegisterFunc("time<?", 2, 2, genFnTimeCompare(func(c int) bool { return c < 0 }))
+	s.RegisterFunc("time<=?", 2, 2, genFnTimeCompare(func(c int) bool { return c <= 0 }))
+	s.RegisterFunc("time>?", 2, 2, genFnTimeCompare(func(c int) bool { return c > 0 }))
+	s.RegisterFunc("time>=?", 2, 2, genFnTimeCompare(func(c int) bool { return c >= 0 }))
+	s.RegisterFunc("time-difference", 2, 2, genFnTimeDifference(false))
+	s.RegisterFunc("time-difference!", 2, 2, genFnTimeDifference(true))
+	s.RegisterFunc("add-duration", 2, 2, genFnAddDuration(1, false))
+	s.RegisterFunc("add-duration!", 2, 2, genFnAddDuration(1, true))
+	s.RegisterFunc("subtract-duration", 2, 2, genFnAddDuration(-1, false))
+	s.RegisterFunc("subtract-duration!", 2, 2, genFnAddDuration(-1, true))
+	s.RegisterFunc("make-date", 8, 8, fnMakeDate)
+	s.RegisterFunc("date?", 1, 1, fnIsDate)
+	s.RegisterFunc("date-nanosecond", 1, 1, genFnDateField(func(t time.Time) int { return t.Nanosecond() }))
+	s.RegisterFunc("date-second", 1, 1, genFnDateField(time.Time.Second))
+	s.RegisterFunc("date-minute", 1, 1, genFnDateField(time.Time.Minute))
+	s.RegisterFunc("date-hour", 1, 1, genFnDateField(time.Time.Hour))
+	s.RegisterFunc("date-day", 1, 1, genFnDateField(time.Time.Day))
+	s.RegisterFunc("date-month", 1, 1, genFnDateField(func(t time.Time) int { return int(t.Month()) }))
+	s.RegisterFunc("date-year", 1, 1, genFnDateField(time.Time.Year))
+	s.RegisterFunc("date-zone-offset", 1, 1, genFnDateField(func(t time.Time) int { _, offset := t.Zone(); return offset }))
+	s.RegisterFunc("date-year-day", 1, 1, genFnDateField(time.Time.YearDay))
+	s.RegisterFunc("date-week-day", 1, 1, genFnDateField(func(t time.Time) int { return int(t.Weekday()) }))
+	s.RegisterFunc("date-week-number", 2, 2, fnDateWeekNumber)
+	s.RegisterFunc("date->time-utc", 1, 1, genFnDateToTime(types.TimeUTC))
+	s.RegisterFunc("date->time-monotonic", 1, 1, genFnDateToTime(types.TimeMonotonic))
+	s.RegisterFunc("time-utc->date", 1, 2, genFnTimeToDate(types.TimeUTC))
+	s.RegisterFunc("time-monotonic->date", 1, 2, genFnTimeToDate(types.TimeMonotonic))
+	s.RegisterFunc("time-utc->time-monotonic", 1, 1, genFnConvertTime(types.TimeUTC, types.TimeMonotonic))
+	s.RegisterFunc("time-monotonic->time-utc", 1, 1, genFnConvertTime(types.TimeMonotonic, types.TimeUTC))
+	s.RegisterFunc("date->string", 1, 2, fnDateToString)
+	s.RegisterFunc("string->date", 2, 2, fnStringToDate)
+	return s
+}
+
+func toTime(obj types.Object) (*types.Time, error) {
+	if err := types.AssertType(types.TyTime, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.Time), nil
+}
+
+// toTimeOfType converts obj to a time of typ.
+func toTimeOfType(obj types.Object, typ types.TimeType) (*types.Time, error) {
+	t, err := toTime(obj)
+	if err != nil {
+		return nil, err
+	}
+	if t.TimeType != typ {
+		return nil, types.NewTypeError("%s required, but got %v", typ, obj)
+	}
+	return t, nil
+}
+
+func toTimeType(obj types.Object) (types.TimeType, error) {
+	if sym, ok := obj.(*types.Symbol); ok {
+		switch typ := types.TimeType(sym.Name); typ {
+		case types.TimeUTC, types.TimeMonotonic, types.TimeDuration:
+			return typ, nil
+		}
+	}
+	return "", types.NewTypeError("time type required, but got %v", obj)
+}
+
+func toDate(obj types.Object) (*types.Date, error) {
+	if err := types.AssertType(types.TyDate, obj); err != nil {
+		return nil, err
+	}
+	return obj.(*types.Date), nil
+}
+
+// toZone converts the time zone offset in seconds to a location.
+func toZone(obj types.Object) (*time.Location, error) {
+	offset, err := toInteger(obj)
+	if err != nil {
+		return nil, err
+	}
+	return time.FixedZone("", int(offset)), nil
+}
+
+// optionalZone returns the zone at args[i], or the zone of the clock if it is omitted.
+func (s *State) optionalZone(args []types.Object, i int) (*time.Location, error) {
+	if len(args) <= i {
+		return s.clock().Location(), nil
+	}
+	return toZone(args[i])
+}
+
+// (current-second)
+// It returns the seconds since the Unix epoch in UTC, not in TAI.
+func fnCurrentSecond(s *State, args []types.Object) (types.Object, error) {
+	now := s.clock()
+	return types.Number(float64(now.Unix()) + float64(now.Nanosecond())/1e9), nil
+}
+
+func fnCurrentJiffy(s *State, args []types.Object) (types.Object, error) {
+	return types.Number(s.clock().Sub(s.epoch).Microseconds()), nil
+}
+
+func fnJiffiesPerSecond(s *State, args []types.Object) (types.Object, error) {
+	return types.Number(jiffiesPerSecond), nil
+}
+
+// (current-time [type])
+// type defaults to time-utc. time-duration is not allowed.
+func fnCurrentTime(s *State, args []types.Object) (types.Object, error) {
+	typ := types.TimeUTC
+	if len(args) > 0 {
+		var err error
+		if typ, err = toTimeType(args[0]); err != nil {
+			return nil, err
+		}
+		if typ == types.TimeDuration {
+			return nil, types.NewTypeError("current time of %s is not available", typ)
+		}
+	}
+	return types.NewTimeFromGo(typ, s.clock()), nil
+}
+
+// (current-date [zone-offset])
+// zone-offset defaults to the zone of the clock.
+func fnCurrentDate(s *State, args []types.Object) (types.Object, error) {
+	loc, err := s.optionalZone(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewDate(s.clock().In(loc)), nil
+}
+
+// (time-resolution [type])
+func fnTimeResolution(s *State, args []types.Object) (types.Object, error) {
+	if len(args) > 0 {
+		if _, err := toTimeType(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	return types.Number(1), nil
+}
+
+// (make-time type nanosecond second)
+func fnMakeTime(s *State, args []types.Object) (types.Object, error) {
+	typ, err := toTimeType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	nsec, err := toInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	sec, err := toInteger(args[2])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTime(typ, int64(sec), int64(nsec)), nil
+}
+
+func fnIsTime(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyTime), nil
+}
+
+func fnTimeType(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewSymbol(string(t.TimeType)), nil
+}
+
+func fnTimeSecond(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(t.Second), nil
+}
+
+func fnTimeNanosecond(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Number(t.Nanosecond), nil
+}
+
+func fnSetTimeType(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	typ, err := toTimeType(args[1])
+	if err != nil {
+		return nil, err
+	}
+	t.Set(typ, t.Second, t.Nanosecond)
+	return types.UndefinedObject, nil
+}
+
+func fnSetTimeSecond(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sec, err := toInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	t.Set(t.TimeType, int64(sec), t.Nanosecond)
+	return types.UndefinedObject, nil
+}
+
+func fnSetTimeNanosecond(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	nsec, err := toInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	t.Set(t.TimeType, t.Second, int64(nsec))
+	return types.UndefinedObject, nil
+}
+
+func fnCopyTime(s *State, args []types.Object) (types.Object, error) {
+	t, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTime(t.TimeType, t.Second, t.Nanosecond), nil
+}
+
+// toSameTypeTimes converts args to times of the same type.
+func toSameTypeTimes(args []types.Object) (*types.Time, *types.Time, error) {
+	t1, err := toTime(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	t2, err := toTimeOfType(args[1], t1.TimeType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t1, t2, nil
+}
+
+// genFnTimeCompare generates (time=? t1 t2) and so on. Both times must have the same type.
+func genFnTimeCompare(pred func(c int) bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		t1, t2, err := toSameTypeTimes(args)
+		if err != nil {
+			return nil, err
+		}
+		return types.Boolean(pred(t1.Compare(t2))), nil
+	}
+}
+
+// genFnTimeDifference generates (time-difference t1 t2) and (time-difference! t1 t2),
+// which return the duration t1 - t2. The latter stores the result in t1.
+func genFnTimeDifference(bang bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		t1, t2, err := toSameTypeTimes(args)
+		if err != nil {
+			return nil, err
+		}
+		result := t1
+		if !bang {
+			result = &types.Time{}
+		}
+		result.Set(types.TimeDuration, t1.Second-t2.Second, t1.Nanosecond-t2.Nanosecond)
+		return result, nil
+	}
+}
+
+// genFnAddDuration generates (add-duration t d) and (subtract-duration t d) when sign is
+// 1 and -1 respectively. The bang versions store the result in t.
+func genFnAddDuration(sign int64, bang bool) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d, err := toTimeOfType(args[1], types.TimeDuration)
+		if err != nil {
+			return nil, err
+		}
+		result := t
+		if !bang {
+			result = &types.Time{}
+		}
+		result.Set(t.TimeType, t.Second+sign*d.Second, t.Nanosecond+sign*d.Nanosecond)
+		return result, nil
+	}
+}
+
+// (make-date nanosecond second minute hour day month year zone-offset)
+func fnMakeDate(s *State, args []types.Object) (types.Object, error) {
+	var fields [7]int
+	for i := range fields {
+		v, err := toInteger(args[i])
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = int(v)
+	}
+	loc, err := toZone(args[7])
+	if err != nil {
+		return nil, err
+	}
+	t, err := makeDate(fields[6], fields[5], fields[4], fields[3], fields[2], fields[1], fields[0], loc)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewDate(t), nil
+}
+
+// makeDate is time.Date reporting out of range fields instead of normalizing them.
+func makeDate(year, month, day, hour, min, sec, nsec int, loc *time.Location) (time.Time, error) {
+	t := time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day || t.Hour() != hour ||
+		t.Minute() != min || t.Second() != sec || t.Nanosecond() != nsec {
+		return time.Time{}, types.NewTypeError("invalid date %04d-%02d-%02d %02d:%02d:%02d.%09d",
+			year, month, day, hour, min, sec, nsec)
+	}
+	return t, nil
+}
+
+func fnIsDate(s *State, args []types.Object) (types.Object, error) {
+	return types.Boolean(args[0].Type() == types.TyDate), nil
+}
+
+func genFnDateField(field func(t time.Time) int) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		d, err := toDate(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return types.Number(field(d.Time)), nil
+	}
+}
+
+// (date-week-number date day-of-week-starting-week)
+// Days before the first day-of-week-starting-week of the year are in week 0.
+func fnDateWeekNumber(s *State, args []types.Object) (types.Object, error) {
+	d, err := toDate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	start, err := toInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || start > 6 {
+		return nil, types.NewTypeError("day of week must be in [0, 6], but got %v", args[1])
+	}
+	return types.Number(weekNumber(d.Time, time.Weekday(start))), nil
+}
+
+// weekNumber returns the week number of t where weeks start on start.
+func weekNumber(t time.Time, start time.Weekday) int {
+	yday := t.YearDay() - 1
+	return (yday + 7 - (int(t.Weekday())-int(start)+7)%7) / 7
+}
+
+func genFnDateToTime(typ types.TimeType) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		d, err := toDate(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTimeFromGo(typ, d.Time), nil
+	}
+}
+
+// genFnTimeToDate generates (time-utc->date time [zone-offset]) and so on.
+func genFnTimeToDate(typ types.TimeType) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		t, err := toTimeOfType(args[0], typ)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := s.optionalZone(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewDate(t.GoTime().In(loc)), nil
+	}
+}
+
+func genFnConvertTime(from, to types.TimeType) GoFunc {
+	return func(s *State, args []types.Object) (types.Object, error) {
+		t, err := toTimeOfType(args[0], from)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTime(to, t.Second, t.Nanosecond), nil
+	}
+}
+
+// dateComposites are the directives of date->string expanded to other directives.
+var dateComposites = map[rune]string{
+	'c': "~a ~b ~d ~H:~M:~S~z ~Y",
+	'D': "~m/~d/~y",
+	'r': "~I:~M:~S ~p",
+	'T': "~H:~M:~S",
+	'x': "~m/~d/~y",
+	'X': "~H:~M:~S",
+	'1': "~Y-~m-~d",
+	'2': "~H:~M:~S~z",
+	'3': "~H:~M:~S",
+	'4': "~Y-~m-~dT~H:~M:~S~z",
+	'5': "~Y-~m-~dT~H:~M:~S",
+}
+
+// (date->string date [format])
+// format defaults to "~c". See SRFI-19 for the directives.
+func fnDateToString(s *State, args []types.Object) (types.Object, error) {
+	d, err := toDate(args[0])
+	if err != nil {
+		return nil, err
+	}
+	format := "~c"
+	if len(args) > 1 {
+		if err := types.AssertType(types.TyString, args[1]); err != nil {
+			return nil, err
+		}
+		format = args[1].(*types.String).String()
+	}
+	var b strings.Builder
+	if err := formatDate(&b, d.Time, format); err != nil {
+		return nil, err
+	}
+	return types.NewString(b.String()), nil
+}
+
+func formatDate(b *strings.Builder, t time.Time, format string) error {
+	directives := []rune(format)
+	for i := 0; i < len(directives); i++ {
+		if directives[i] != '~' {
+			b.WriteRune(directives[i])
+			continue
+		}
+		i++
+		if i == len(directives) {
+			return types.NewTypeError("incomplete directive in date format %q", format)
+		}
+		c := directives[i]
+		if composite, ok := dateComposites[c]; ok {
+			formatDate(b, t, composite)
+			continue
+		}
+		switch c {
+		case '~':
+			b.WriteByte('~')
+		case 'a':
+			b.WriteString(t.Weekday().String()[:3])
+		case 'A':
+			b.WriteString(t.Weekday().String())
+		case 'b', 'h':
+			b.WriteString(t.Month().String()[:3])
+		case 'B':
+			b.WriteString(t.Month().String())
+		case 'd':
+			fmt.Fprintf(b, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(b, "%2d", t.Day())
+		case 'f':
+			fmt.Fprintf(b, "%02d", t.Second())
+			if t.Nanosecond() > 0 {
+				b.WriteString("." + strings.TrimRight(fmt.Sprintf("%09d", t.Nanosecond()), "0"))
+			}
+		case 'H':
+			fmt.Fprintf(b, "%02d", t.Hour())
+		case 'I':
+			fmt.Fprintf(b, "%02d", hour12(t))
+		case 'j':
+			fmt.Fprintf(b, "%03d", t.YearDay())
+		case 'k':
+			fmt.Fprintf(b, "%2d", t.Hour())
+		case 'l':
+			fmt.Fprintf(b, "%2d", hour12(t))
+		case 'm':
+			fmt.Fprintf(b, "%02d", int(t.Month()))
+		case 'M':
+			fmt.Fprintf(b, "%02d", t.Minute())
+		case 'n':
+			b.WriteByte('\n')
+		case 'N':
+			fmt.Fprintf(b, "%09d", t.Nanosecond())
+		case 'p':
+			if t.Hour() < 12 {
+				b.WriteString("AM")
+			} else {
+				b.WriteString("PM")
+			}
+		case 's':
+			fmt.Fprintf(b, "%d", t.Unix())
+		case 'S':
+			fmt.Fprintf(b, "%02d", t.Second())
+		case 't':
+			b.WriteByte('\t')
+		case 'U':
+			fmt.Fprintf(b, "%02d", weekNumber(t, time.Sunday))
+		case 'V':
+			_, week := t.ISOWeek()
+			fmt.Fprintf(b, "%02d", week)
+		case 'w':
+			fmt.Fprintf(b, "%d", int(t.Weekday()))
+		case 'W':
+			fmt.Fprintf(b, "%02d", weekNumber(t, time.Monday))
+		case 'y':
+			fmt.Fprintf(b, "%02d", t.Year()%100)
+		case 'Y':
+			fmt.Fprintf(b, "%d", t.Year())
+		case 'z':
+			b.WriteString(formatZoneOffset(t))
+		case 'Z':
+			if name, _ := t.Zone(); name != "" {
+				b.WriteString(name)
+			} else {
+				b.WriteString(formatZoneOffset(t))
+			}
+		default:
+			return types.NewTypeError("unknown directive ~%c in date format %q", c, format)
+		}
+	}
+	return nil
+}
+
+func hour12(t time.Time) int {
+	if h := t.Hour() % 12; h != 0 {
+		return h
+	}
+	return 12
+}
+
+// formatZoneOffset formats the zone offset of t such as -0500, or Z for UTC.
+func formatZoneOffset(t time.Time) string {
+	_, offset := t.Zone()
+	if offset == 0 {
+		return "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+// (string->date string format)
+// The directives ~~ ~a ~A ~b ~B ~d ~e ~h ~H ~k ~m ~M ~S ~y ~Y and ~z are accepted.
+// Fields missing in format are 0, except the month and the day which are 1.
+// The zone offset defaults to the zone of the clock.
+func fnStringToDate(s *State, args []types.Object) (types.Object, error) {
+	for _, arg := range args {
+		if err := types.AssertType(types.TyString, arg); err != nil {
+			return nil, err
+		}
+	}
+	p := &dateParser{
+		src:    []rune(args[0].(*types.String).String()),
+		format: args[1].(*types.String).String(),
+		now:    s.clock(),
+		month:  1,
+		day:    1,
+	}
+	t, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return types.NewDate(t), nil
+}
+
+// dateParser parses a string by the format of string->date.
+type dateParser struct {
+	src    []rune
+	pos    int
+	format string
+	now    time.Time // for the zone and the century of two-digit years
+
+	year, month, day, hour, minute, second int
+	loc                                    *time.Location
+}
+
+func (p *dateParser) error() error {
+	return types.NewTypeError("%q does not match the date format %q", string(p.src), p.format)
+}
+
+func (p *dateParser) parse() (time.Time, error) {
+	directives := []rune(p.format)
+	for i := 0; i < len(directives); i++ {
+		if directives[i] != '~' {
+			if p.pos == len(p.src) || p.src[p.pos] != directives[i] {
+				return time.Time{}, p.error()
+			}
+			p.pos++
+			continue
+		}
+		i++
+		if i == len(directives) {
+			return time.Time{}, types.NewTypeError("incomplete directive in date format %q", p.format)
+		}
+		if err := p.directive(directives[i]); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if p.pos != len(p.src) {
+		return time.Time{}, p.error()
+	}
+	if p.loc == nil {
+		p.loc = p.now.Location()
+	}
+	return makeDate(p.year, p.month, p.day, p.hour, p.minute, p.second, 0, p.loc)
+}
+
+func (p *dateParser) directive(c rune) error {
+	var err error
+	switch c {
+	case '~':
+		if p.pos == len(p.src) || p.src[p.pos] != '~' {
+			return p.error()
+		}
+		p.pos++
+	case 'a', 'A':
+		_, err = p.name(7, func(i int) string { return time.Weekday(i).String() })
+	case 'b', 'B', 'h':
+		var i int
+		i, err = p.name(12, func(i int) string { return time.Month(i + 1).String() })
+		p.month = i + 1
+	case 'd':
+		p.day, err = p.number(2)
+	case 'e':
+		p.skipSpace()
+		p.day, err = p.number(2)
+	case 'H':
+		p.hour, err = p.number(2)
+	case 'k':
+		p.skipSpace()
+		p.hour, err = p.number(2)
+	case 'm':
+		p.month, err = p.number(2)
+	case 'M':
+		p.minute, err = p.number(2)
+	case 'S':
+		p.second, err = p.number(2)
+	case 'y':
+		var y int
+		y, err = p.number(2)
+		p.year = naturalYear(y, p.now.Year())
+	case 'Y':
+		p.year, err = p.number(-1)
+	case 'z':
+		err = p.zone()
+	default:
+		return types.NewTypeError("unknown directive ~%c in date format %q", c, p.format)
+	}
+	return err
+}
+
+func (p *dateParser) skipSpace() {
+	if p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// number parses a decimal number of at most max digits, or any digits if max is negative.
+func (p *dateParser) number(max int) (int, error) {
+	n, start := 0, p.pos
+	for p.pos < len(p.src) && '0' <= p.src[p.pos] && p.src[p.pos] <= '9' && (max < 0 || p.pos-start < max) {
+		n = n*10 + int(p.src[p.pos]-'0')
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, p.error()
+	}
+	return n, nil
+}
+
+// name parses the full or abbreviated name of a weekday or a month ignoring case,
+// and returns its index.
+func (p *dateParser) name(count int, name func(i int) string) (int, error) {
+	rest := string(p.src[p.pos:])
+	for i := 0; i < count; i++ {
+		full := name(i)
+		for _, candidate := range []string{full, full[:3]} {
+			if len(rest) >= len(candidate) && strings.EqualFold(rest[:len(candidate)], candidate) {
+				p.pos += len(candidate)
+				return i, nil
+			}
+		}
+	}
+	return 0, p.error()
+}
+
+// zone parses the zone offset such as -0500 or Z.
+func (p *dateParser) zone() error {
+	if p.pos < len(p.src) && unicode.ToUpper(p.src[p.pos]) == 'Z' {
+		p.pos++
+		p.loc = time.UTC
+		return nil
+	}
+	if p.pos == len(p.src) || (p.src[p.pos] != '+' && p.src[p.pos] != '-') {
+		return p.error()
+	}
+	sign := 1
+	if p.src[p.pos] == '-' {
+		sign = -1
+	}
+	p.pos++
+	start := p.pos
+	hhmm, err := p.number(4)
+	if err != nil || p.pos-start != 4 {
+		return p.error()
+	}
+	p.loc = time.FixedZone("", sign*(hhmm/100*3600+hhmm%100*60))
+	return nil
+}
+
+// naturalYear returns the year within 50 years of the current year whose last two digits are y.
+func naturalYear(y, current int) int {
+	year := current - current%100 + y
+	switch {
+	case year-current > 50:
+		year -= 100
+	case current-year > 50:
+		year += 100
+	}
+	return year
+}
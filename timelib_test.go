@@ -0,0 +1,110 @@
+package tama
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock returns a clock frozen at 2024-03-05 14:07:09.25 in UTC-5.
+func fixedClock() func() time.Time {
+	t := time.Date(2024, 3, 5, 14, 7, 9, 250000000, time.FixedZone("EST", -5*3600))
+	return func() time.Time { return t }
+}
+
+func TestCurrentTime(t *testing.T) {
+	option := Option{Clock: fixedClock()}
+	tcases := []*tcase{
+		&tcase{src: `(= (time-second (current-time)) 1709665629)`, expect: "#t", option: option},
+		&tcase{src: `(= (time-nanosecond (current-time)) 250000000)`, expect: "#t", option: option},
+		&tcase{src: `(time-type (current-time time-monotonic))`, expect: "time-monotonic", option: option},
+		&tcase{src: `(= (current-second) 1709665629.25)`, expect: "#t", option: option},
+		&tcase{src: `(current-jiffy)`, expect: "0", option: option},
+		&tcase{src: `(= (jiffies-per-second) 1000000)`, expect: "#t", option: option},
+		&tcase{src: `(date->string (current-date))`, expect: "Tue Mar 05 14:07:09-0500 2024", option: option},
+		&tcase{src: `(date->string (current-date 0) "~4")`, expect: "2024-03-05T19:07:09Z", option: option},
+		&tcase{src: `(current-time time-duration)`, expectErr: true, option: option},
+		&tcase{src: `(current-time 'time-tai)`, expectErr: true, option: option},
+	}
+	testTcases(t, tcases, (*State).OpenTime)
+}
+
+func TestAdvanceClock(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewState(Option{Clock: func() time.Time { return now }}).OpenTime()
+	now = now.Add(1500 * time.Millisecond)
+	if err := s.ExecString(`(current-jiffy)`); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.CallStack.Top().String(); v != "1.5e+06" {
+		t.Fatalf("expected 1.5e+06, but got %v", v)
+	}
+}
+
+func TestTimeArithmetic(t *testing.T) {
+	tcases := []*tcase{
+		&tcase{src: `(define d (time-difference (make-time time-utc 0 10) (make-time time-utc 500000000 8))) (list (time-type d) (time-second d) (= (time-nanosecond d) 500000000))`, expect: "(time-duration 1 #t)"},
+		&tcase{src: `(time-second (time-difference (make-time time-utc 0 8) (make-time time-utc 500000000 8)))`, expect: "-1"},
+		&tcase{src: `(define t (add-duration (make-time time-utc 600000000 1) (make-time time-duration 600000000 2))) (list (time-second t) (= (time-nanosecond t) 200000000))`, expect: "(4 #t)"},
+		&tcase{src: `(time-second (subtract-duration (make-time time-utc 0 10) (make-time time-duration 0 3)))`, expect: "7"},
+		&tcase{src: `(define t (make-time time-utc 0 10)) (add-duration! t (make-time time-duration 0 5)) (time-second t)`, expect: "15"},
+		&tcase{src: `(define t (make-time time-utc 0 10)) (time-difference! t (make-time time-utc 0 4)) (list (time-type t) (time-second t))`, expect: "(time-duration 6)"},
+		&tcase{src: `(define t (make-time time-utc 0 10)) (define u (copy-time t)) (set-time-second! u 1) (time-second t)`, expect: "10"},
+		&tcase{src: `(define t (make-time time-utc 0 10)) (set-time-nanosecond! t 1500000000) (time-second t)`, expect: "11"},
+		&tcase{src: `(time<? (make-time time-utc 0 1) (make-time time-utc 1 1))`, expect: "#t"},
+		&tcase{src: `(time=? (make-time time-utc 1000000000 1) (make-time time-utc 0 2))`, expect: "#t"},
+		&tcase{src: `(time>=? (make-time time-utc 0 1) (make-time time-utc 0 2))`, expect: "#f"},
+		&tcase{src: `(time? (make-time time-duration 0 0))`, expect: "#t"},
+		&tcase{src: `(time-resolution)`, expect: "1"},
+		&tcase{src: `(time<? (make-time time-utc 0 1) (make-time time-monotonic 0 1))`, expectErr: true},
+		&tcase{src: `(add-duration (make-time time-utc 0 1) (make-time time-utc 0 1))`, expectErr: true},
+		&tcase{src: `(make-time 'time-foo 0 1)`, expectErr: true},
+		&tcase{src: `(make-time time-utc 0.5 1)`, expectErr: true},
+	}
+	testTcases(t, tcases, (*State).OpenTime)
+}
+
+func TestDate(t *testing.T) {
+	option := Option{Clock: fixedClock()}
+	tcases := []*tcase{
+		&tcase{src: `(define d (make-date 5 9 7 14 5 3 2024 3600)) (list (date-year d) (date-month d) (date-day d) (date-hour d) (date-minute d) (date-second d) (date-nanosecond d) (date-zone-offset d))`, expect: "(2024 3 5 14 7 9 5 3600)", option: option},
+		&tcase{src: `(define d (make-date 0 0 0 0 5 3 2024 0)) (list (date-year-day d) (date-week-day d) (date-week-number d 0) (date-week-number d 1))`, expect: "(65 2 9 10)", option: option},
+		&tcase{src: `(time-second (date->time-utc (make-date 0 0 0 1 1 1 1970 3600)))`, expect: "0", option: option},
+		&tcase{src: `(date-hour (time-utc->date (make-time time-utc 0 0) 7200))`, expect: "2", option: option},
+		&tcase{src: `(date-zone-offset (time-utc->date (make-time time-utc 0 0)))`, expect: "-18000", option: option},
+		&tcase{src: `(time-type (time-utc->time-monotonic (make-time time-utc 0 1)))`, expect: "time-monotonic", option: option},
+		&tcase{src: `(date? (time-monotonic->date (date->time-monotonic (current-date))))`, expect: "#t", option: option},
+		&tcase{src: `(make-date 0 0 0 0 30 2 2024 0)`, expectErr: true, option: option},
+		&tcase{src: `(time-utc->date (make-time time-duration 0 0))`, expectErr: true, option: option},
+	}
+	testTcases(t, tcases, (*State).OpenTime)
+}
+
+func TestDateToString(t *testing.T) {
+	option := Option{Clock: fixedClock()}
+	tcases := []*tcase{
+		&tcase{src: `(date->string (make-date 0 5 4 3 2 1 2006 -25200) "~Y-~m-~d ~H:~M:~S ~z")`, expect: "2006-01-02 03:04:05 -0700", option: option},
+		&tcase{src: `(date->string (make-date 0 5 4 15 2 1 2006 0) "~a ~A ~b ~B ~e ~I ~l ~p ~j ~y")`, expect: "Mon Monday Jan January  2 03  3 PM 002 06", option: option},
+		&tcase{src: `(date->string (make-date 120000000 5 4 0 2 1 2006 0) "~f ~N ~k ~T ~D")`, expect: "05.12 120000000  0 00:04:05 01/02/06", option: option},
+		&tcase{src: `(date->string (make-date 0 0 0 0 1 1 2021 0) "~U ~W ~V ~w ~s ~~")`, expect: "00 00 53 5 1609459200 ~", option: option},
+		&tcase{src: `(date->string (current-date) "~Z ~5")`, expect: "EST 2024-03-05T14:07:09", option: option},
+		&tcase{src: `(date->string (current-date) "~q")`, expectErr: true, option: option},
+		&tcase{src: `(date->string (current-date) "~")`, expectErr: true, option: option},
+	}
+	testTcases(t, tcases, (*State).OpenTime)
+}
+
+func TestStringToDate(t *testing.T) {
+	option := Option{Clock: fixedClock()}
+	tcases := []*tcase{
+		&tcase{src: `(date->string (string->date "2006-01-02 03:04:05 +0900" "~Y-~m-~d ~H:~M:~S ~z") "~4")`, expect: "2006-01-02T03:04:05+0900", option: option},
+		&tcase{src: `(date->string (string->date "Mon, 02 jan 06 15:04Z" "~a, ~d ~b ~y ~H:~M~z") "~4")`, expect: "2006-01-02T15:04:00Z", option: option},
+		&tcase{src: `(date->string (string->date "1999/7" "~Y/~m") "~4")`, expect: "1999-07-01T00:00:00-0500", option: option},
+		&tcase{src: `(date-year (string->date "80" "~y"))`, expect: "1980", option: option},
+		&tcase{src: `(date-day (string->date " 2 June" "~e ~B"))`, expect: "2", option: option},
+		&tcase{src: `(string->date "2006-1" "~Y-~m-~d")`, expectErr: true, option: option},
+		&tcase{src: `(string->date "2006-13-01" "~Y-~m-~d")`, expectErr: true, option: option},
+		&tcase{src: `(string->date "2006x" "~Y")`, expectErr: true, option: option},
+		&tcase{src: `(string->date "12:00 +09" "~H:~M ~z")`, expectErr: true, option: option},
+	}
+	testTcases(t, tcases, (*State).OpenTime)
+}
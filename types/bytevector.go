@@ -0,0 +1,65 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Bytevector struct {
+	bytes     []byte
+	immutable bool
+}
+
+// NewBytevector creates a bytevector which uses b as its storage.
+// b is not copied, so go functions can pass a []byte to scheme without copying.
+func NewBytevector(b []byte) *Bytevector {
+	return &Bytevector{bytes: b}
+}
+
+func (bv *Bytevector) Type() ObjectType {
+	return TyBytevector
+}
+
+func (bv *Bytevector) String() string {
+	strs := make([]string, len(bv.bytes))
+	for i, b := range bv.bytes {
+		strs[i] = strconv.Itoa(int(b))
+	}
+	return "#u8(" + strings.Join(strs, " ") + ")"
+}
+
+func (bv *Bytevector) Len() int {
+	return len(bv.bytes)
+}
+
+// Bytes returns the storage of bv. The slice is shared with bv.
+func (bv *Bytevector) Bytes() []byte {
+	return bv.bytes
+}
+
+// CheckMutable returns an error if bv is immutable.
+// Modify the bytes returned by Bytes only after the check.
+func (bv *Bytevector) CheckMutable() error {
+	if bv.immutable {
+		return NewInternalError("attempt to modify an immutable bytevector")
+	}
+	return nil
+}
+
+// Set stores b as the k-th byte of bv.
+func (bv *Bytevector) Set(k int, b byte) error {
+	if err := bv.CheckMutable(); err != nil {
+		return err
+	}
+	bv.bytes[k] = b
+	return nil
+}
+
+// Freeze makes bv immutable.
+func (bv *Bytevector) Freeze() {
+	bv.immutable = true
+}
+
+func (bv *Bytevector) IsImmutable() bool {
+	return bv.immutable
+}
@@ -0,0 +1,47 @@
+package types
+
+type Char rune
+
+func (c Char) Type() ObjectType {
+	return TyChar
+}
+
+func (c Char) String() string {
+	return string(rune(c))
+}
+
+// charNames is the names of characters defined in R7RS.
+var charNames = []struct {
+	name string
+	ch   Char
+}{
+	{"alarm", '\a'},
+	{"backspace", '\b'},
+	{"delete", 0x7f},
+	{"escape", 0x1b},
+	{"newline", '\n'},
+	{"null", 0},
+	{"return", '\r'},
+	{"space", ' '},
+	{"tab", '\t'},
+}
+
+// CharByName returns the character named name. (e.g. "space")
+func CharByName(name string) (Char, bool) {
+	for _, cn := range charNames {
+		if cn.name == name {
+			return cn.ch, true
+		}
+	}
+	return 0, false
+}
+
+// Name returns the name of c if c has a name.
+func (c Char) Name() (string, bool) {
+	for _, cn := range charNames {
+		if cn.ch == c {
+			return cn.name, true
+		}
+	}
+	return "", false
+}
@@ -57,12 +57,14 @@ const (
 )
 
 type ClosureProto struct {
-	Insts   []uint32
-	Consts  []Object
-	Args    []*Symbol
-	Protos  []*ClosureProto // function prototypes inside the function
-	NUpVals int
-	Mode    ArgMode
+	Name     string // name of the procedure defined by define, or empty
+	Insts    []uint32
+	LineInfo []Pos // source position of each instruction, parallel to Insts
+	Consts   []Object
+	Args     []*Symbol
+	Protos   []*ClosureProto // function prototypes inside the function
+	NUpVals  int
+	Mode     ArgMode
 }
 
 func NewClosureProto() *ClosureProto {
@@ -5,14 +5,18 @@ type Continuation struct {
 	CallStack  *Stack
 	Pc         int
 	NExecCalls int
+	VM         uint64 // id of the VM loop which captured the continuation
+	Depth      int    // nesting depth of the VM loop, 1 at the top level
 }
 
-func NewContinuation(callinfos, callstack *Stack, pc, nexeccalls int) *Continuation {
+func NewContinuation(callinfos, callstack *Stack, pc, nexeccalls int, vm uint64, depth int) *Continuation {
 	return &Continuation{
 		CallInfos:  callinfos,
 		CallStack:  callstack,
 		Pc:         pc,
 		NExecCalls: nexeccalls,
+		VM:         vm,
+		Depth:      depth,
 	}
 }
 
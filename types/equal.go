@@ -0,0 +1,195 @@
+package types
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+)
+
+// Eqv reports whether a and b are equivalent in the sense of eqv?.
+// Numbers, booleans and characters are compared by value, symbols by name and
+// the other objects by identity.
+func Eqv(a, b Object) bool {
+	switch x := a.(type) {
+	case Number:
+		y, ok := b.(Number)
+		return ok && (x == y || (x != x && y != y)) // NaN is eqv? to NaN
+	case *Symbol:
+		y, ok := b.(*Symbol)
+		return ok && x.Name == y.Name
+	}
+	return a == b
+}
+
+// Equal reports whether a and b are equivalent in the sense of equal?.
+// Pairs, vectors, strings and bytevectors are compared by their contents.
+// It terminates even if a and b are circular.
+func Equal(a, b Object) bool {
+	var c equalComparer
+	return c.equal(a, b)
+}
+
+// maxEqualSteps is the number of pairs and vectors Equal compares before
+// it starts remembering them. Small structures are compared without allocation.
+const maxEqualSteps = 64
+
+// equalComparer remembers the pairs and vectors being compared. A pair of
+// objects met again is assumed to be equal, which makes the comparison of
+// circular structures finite.
+type equalComparer struct {
+	steps   int
+	visited map[[2]Object]struct{}
+}
+
+// visit reports whether x and y have been compared already.
+func (c *equalComparer) visit(x, y Object) bool {
+	c.steps++
+	if c.steps <= maxEqualSteps {
+		return false
+	}
+	if c.visited == nil {
+		c.visited = map[[2]Object]struct{}{}
+	}
+	key := [2]Object{x, y}
+	if _, ok := c.visited[key]; ok {
+		return true
+	}
+	c.visited[key] = struct{}{}
+	return false
+}
+
+func (c *equalComparer) equal(a, b Object) bool {
+	for {
+		switch x := a.(type) {
+		case *Pair:
+			y, ok := b.(*Pair)
+			if !ok {
+				return false
+			}
+			if x == y || c.visit(x, y) {
+				return true
+			}
+			if !c.equal(x.car, y.car) {
+				return false
+			}
+			a, b = x.cdr, y.cdr
+			continue
+		case *String:
+			y, ok := b.(*String)
+			return ok && x.String() == y.String()
+		case *Vector:
+			y, ok := b.(*Vector)
+			if !ok || x.Len() != y.Len() {
+				return false
+			}
+			if x == y || c.visit(x, y) {
+				return true
+			}
+			for i, elem := range x.elems {
+				if !c.equal(elem, y.elems[i]) {
+					return false
+				}
+			}
+			return true
+		case *Bytevector:
+			y, ok := b.(*Bytevector)
+			return ok && bytes.Equal(x.bytes, y.bytes)
+		}
+		return Eqv(a, b)
+	}
+}
+
+const (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
+
+func hashUint(h uint64, v uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h ^= v & 0xff
+		h *= fnvPrime
+		v >>= 8
+	}
+	return h
+}
+
+func hashString(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime
+	}
+	return h
+}
+
+// maxHashElems is the maximum number of elements of pairs and vectors
+// taken into account by EqualHash. It keeps hashing of large or
+// circular structures cheap.
+const maxHashElems = 64
+
+// EqvHash returns the hash value of obj which is consistent with Eqv.
+// The value is stable across runs except for objects compared by identity.
+func EqvHash(obj Object) uint64 {
+	h := hashUint(fnvOffset, uint64(obj.Type()))
+	switch o := obj.(type) {
+	case Number:
+		f := float64(o)
+		switch {
+		case f == 0:
+			f = 0 // -0 is = to 0
+		case f != f:
+			f = math.NaN()
+		}
+		return hashUint(h, math.Float64bits(f))
+	case Boolean:
+		if o {
+			return hashUint(h, 1)
+		}
+		return hashUint(h, 0)
+	case Char:
+		return hashUint(h, uint64(o))
+	case *Symbol:
+		return hashString(h, o.Name)
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr {
+		return hashUint(h, uint64(v.Pointer()))
+	}
+	return h
+}
+
+// EqualHash returns the hash value of obj which is consistent with Equal.
+func EqualHash(obj Object) uint64 {
+	budget := maxHashElems
+	return equalHash(fnvOffset, obj, &budget)
+}
+
+func equalHash(h uint64, obj Object, budget *int) uint64 {
+	h = hashUint(h, uint64(obj.Type()))
+	switch o := obj.(type) {
+	case *Pair:
+		var cur Object = o
+		for pair, ok := cur.(*Pair); ok && *budget > 0; pair, ok = cur.(*Pair) {
+			*budget--
+			h = equalHash(h, pair.car, budget)
+			cur = pair.cdr
+		}
+		if _, ok := cur.(*Pair); !ok {
+			h = equalHash(h, cur, budget)
+		}
+		return h
+	case *Vector:
+		for _, elem := range o.elems {
+			if *budget <= 0 {
+				break
+			}
+			*budget--
+			h = equalHash(h, elem, budget)
+		}
+		return h
+	case *String:
+		return hashString(h, o.String())
+	case *Bytevector:
+		return hashString(h, string(o.bytes))
+	}
+	return hashUint(h, EqvHash(obj))
+}
@@ -0,0 +1,71 @@
+package types
+
+import "testing"
+
+func TestEqvEqual(t *testing.T) {
+	str := NewString("a")
+	testcases := []struct {
+		a, b  Object
+		eqv   bool
+		equal bool
+	}{
+		{Number(1), Number(1), true, true},
+		{Number(1), Number(2), false, false},
+		{NewSymbol("a"), NewSymbol("a"), true, true},
+		{Char('a'), Char('a'), true, true},
+		{str, str, true, true},
+		{NewString("a"), NewString("a"), false, true},
+		{List(Number(1), NewString("a")), List(Number(1), NewString("a")), false, true},
+		{List(Number(1)), List(Number(1), Number(2)), false, false},
+		{NewVector([]Object{Number(1)}), NewVector([]Object{Number(1)}), false, true},
+		{NewBytevector([]byte{1}), NewBytevector([]byte{1}), false, true},
+		{NilObject, NilObject, true, true},
+		{Boolean(false), NilObject, false, false},
+	}
+	for i, tc := range testcases {
+		if eqv := Eqv(tc.a, tc.b); eqv != tc.eqv {
+			t.Fatalf("case %d: expected eqv %t, but got %t", i, tc.eqv, eqv)
+		}
+		if equal := Equal(tc.a, tc.b); equal != tc.equal {
+			t.Fatalf("case %d: expected equal %t, but got %t", i, tc.equal, equal)
+		}
+		if tc.equal && EqualHash(tc.a) != EqualHash(tc.b) {
+			t.Fatalf("case %d: expected the same hash", i)
+		}
+		if tc.eqv && EqvHash(tc.a) != EqvHash(tc.b) {
+			t.Fatalf("case %d: expected the same eqv hash", i)
+		}
+	}
+}
+
+func TestEqualCircular(t *testing.T) {
+	cycle := func(elems ...Object) *Pair {
+		list := List(elems...).(*Pair)
+		last := list
+		for p, ok := last.cdr.(*Pair); ok; p, ok = last.cdr.(*Pair) {
+			last = p
+		}
+		last.cdr = list
+		return list
+	}
+	testcases := []struct {
+		a, b  Object
+		equal bool
+	}{
+		{cycle(Number(1)), cycle(Number(1)), true},
+		{cycle(Number(1)), cycle(Number(1), Number(1), Number(1)), true},
+		{cycle(Number(1), Number(2)), cycle(Number(1), Number(2), Number(1)), false},
+		{cycle(Number(1)), cycle(Number(2)), false},
+	}
+	for i, tc := range testcases {
+		if equal := Equal(tc.a, tc.b); equal != tc.equal {
+			t.Fatalf("case %d: expected equal %t, but got %t", i, tc.equal, equal)
+		}
+	}
+	// a list containing itself
+	a, b := List(Number(1)).(*Pair), List(Number(1)).(*Pair)
+	a.car, b.car = a, b
+	if !Equal(a, b) {
+		t.Fatalf("expected equal circular cars")
+	}
+}
@@ -11,11 +11,16 @@ const (
 	ErrInternal
 	// typeError is thrown when a object is not of the expected type.
 	ErrType
+	// ErrFile is thrown when a file cannot be opened, created or deleted.
+	ErrFile
+	// ErrRead is thrown when the reader encounters a malformed datum.
+	ErrRead
 )
 
 type Error struct {
 	s       string
 	errType ErrorType
+	pos     Pos // where the error occurred, if known
 }
 
 func NewSyntaxError(s string, v ...interface{}) *Error {
@@ -30,14 +35,51 @@ func NewTypeError(s string, v ...interface{}) *Error {
 	return &Error{s: fmt.Sprintf(s, v...), errType: ErrType}
 }
 
+func NewFileError(s string, v ...interface{}) *Error {
+	return &Error{s: fmt.Sprintf(s, v...), errType: ErrFile}
+}
+
+// NewReadError creates an error of the reader at pos.
+func NewReadError(pos Pos, s string, v ...interface{}) *Error {
+	return &Error{s: fmt.Sprintf(s, v...), errType: ErrRead, pos: pos}
+}
+
+// Position returns where the error occurred. It is invalid if the position is unknown.
+func (e *Error) Position() Pos {
+	return e.pos
+}
+
+// SetPosition sets where the error occurred.
+func (e *Error) SetPosition(pos Pos) {
+	e.pos = pos
+}
+
+// ErrorType returns the kind of the error.
+func (e *Error) ErrorType() ErrorType {
+	return e.errType
+}
+
 func (e *Error) Type() ObjectType {
 	return TyError
 }
 
+// Error returns the message prefixed with the position if it is known.
 func (e *Error) Error() string {
+	if e.pos.IsValid() {
+		return e.pos.String() + ": " + e.s
+	}
 	return e.s
 }
 
+// Message returns the message without the position.
+func (e *Error) Message() string {
+	return e.s
+}
+
+func (e *Error) String() string {
+	return e.Error()
+}
+
 func (e *Error) Set(s string) {
 	e.s = s
 }
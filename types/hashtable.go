@@ -0,0 +1,194 @@
+package types
+
+// EquivFunc reports whether two keys of a hash table are the same.
+type EquivFunc func(a, b Object) (bool, error)
+
+// HashFunc returns the hash value of a key of a hash table.
+// Keys which are the same according to the EquivFunc must have the same hash value.
+type HashFunc func(key Object) (uint64, error)
+
+type htEntry struct {
+	key   Object
+	value Object
+	hash  uint64
+}
+
+// HashTable is a hash table whose keys are compared by an arbitrary equivalence.
+// Entries are iterated in insertion order.
+//
+// The functions of tables created by NewEqualHashTable and NewEqvHashTable never fail,
+// so the errors returned by their methods can be ignored.
+type HashTable struct {
+	equiv   EquivFunc
+	hash    HashFunc
+	entries []*htEntry       // entries in insertion order. deleted entries are nil
+	buckets map[uint64][]int // hash value -> indices of entries
+	count   int
+}
+
+func NewHashTable(equiv EquivFunc, hash HashFunc) *HashTable {
+	return &HashTable{
+		equiv:   equiv,
+		hash:    hash,
+		buckets: map[uint64][]int{},
+	}
+}
+
+// NewEqualHashTable creates a hash table whose keys are compared by equal?.
+func NewEqualHashTable() *HashTable {
+	return NewHashTable(
+		func(a, b Object) (bool, error) { return Equal(a, b), nil },
+		func(key Object) (uint64, error) { return EqualHash(key), nil },
+	)
+}
+
+// NewEqvHashTable creates a hash table whose keys are compared by eqv?.
+func NewEqvHashTable() *HashTable {
+	return NewHashTable(
+		func(a, b Object) (bool, error) { return Eqv(a, b), nil },
+		func(key Object) (uint64, error) { return EqvHash(key), nil },
+	)
+}
+
+func (h *HashTable) Type() ObjectType {
+	return TyHashTable
+}
+
+func (h *HashTable) String() string {
+	return "hash-table"
+}
+
+// lookup returns the hash value of key and the index of its entry, or -1.
+func (h *HashTable) lookup(key Object) (uint64, int, error) {
+	hv, err := h.hash(key)
+	if err != nil {
+		return 0, -1, err
+	}
+	for _, i := range h.buckets[hv] {
+		same, err := h.equiv(h.entries[i].key, key)
+		if err != nil {
+			return 0, -1, err
+		}
+		if same {
+			return hv, i, nil
+		}
+	}
+	return hv, -1, nil
+}
+
+// Get returns the value associated with key.
+func (h *HashTable) Get(key Object) (Object, bool, error) {
+	_, i, err := h.lookup(key)
+	if err != nil || i < 0 {
+		return nil, false, err
+	}
+	return h.entries[i].value, true, nil
+}
+
+// Set associates value with key.
+func (h *HashTable) Set(key, value Object) error {
+	hv, i, err := h.lookup(key)
+	if err != nil {
+		return err
+	}
+	if i >= 0 {
+		h.entries[i].value = value
+		return nil
+	}
+	h.entries = append(h.entries, &htEntry{key: key, value: value, hash: hv})
+	h.buckets[hv] = append(h.buckets[hv], len(h.entries)-1)
+	h.count++
+	return nil
+}
+
+// Delete removes the entry of key.
+// It reports whether the entry existed.
+func (h *HashTable) Delete(key Object) (bool, error) {
+	hv, i, err := h.lookup(key)
+	if err != nil || i < 0 {
+		return false, err
+	}
+	h.entries[i] = nil
+	indices := h.buckets[hv]
+	for j, idx := range indices {
+		if idx == i {
+			indices = append(indices[:j], indices[j+1:]...)
+			break
+		}
+	}
+	if len(indices) == 0 {
+		delete(h.buckets, hv)
+	} else {
+		h.buckets[hv] = indices
+	}
+	h.count--
+	if len(h.entries) > 32 && h.count < len(h.entries)/2 {
+		h.compact()
+	}
+	return true, nil
+}
+
+// compact removes the deleted entries.
+func (h *HashTable) compact() {
+	entries := make([]*htEntry, 0, h.count)
+	buckets := make(map[uint64][]int, len(h.buckets))
+	for _, e := range h.entries {
+		if e != nil {
+			entries = append(entries, e)
+			buckets[e.hash] = append(buckets[e.hash], len(entries)-1)
+		}
+	}
+	h.entries = entries
+	h.buckets = buckets
+}
+
+// Clear removes all the entries.
+func (h *HashTable) Clear() {
+	h.entries = nil
+	h.buckets = map[uint64][]int{}
+	h.count = 0
+}
+
+// Len returns the number of entries.
+func (h *HashTable) Len() int {
+	return h.count
+}
+
+// Range calls fn for each entry in insertion order until fn returns false.
+// fn may modify the table. Entries added during the iteration are not visited.
+func (h *HashTable) Range(fn func(key, value Object) bool) {
+	entries := make([]*htEntry, 0, h.count)
+	for _, e := range h.entries {
+		if e != nil {
+			entries = append(entries, e)
+		}
+	}
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+// Keys returns the keys in insertion order.
+func (h *HashTable) Keys() []Object {
+	keys := make([]Object, 0, h.count)
+	h.Range(func(key, value Object) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Copy returns a shallow copy of h which uses the same functions.
+func (h *HashTable) Copy() *HashTable {
+	c := NewHashTable(h.equiv, h.hash)
+	for _, e := range h.entries {
+		if e != nil {
+			c.entries = append(c.entries, &htEntry{key: e.key, value: e.value, hash: e.hash})
+			c.buckets[e.hash] = append(c.buckets[e.hash], len(c.entries)-1)
+		}
+	}
+	c.count = h.count
+	return c
+}
@@ -0,0 +1,34 @@
+package types
+
+import "testing"
+
+func TestHashTable(t *testing.T) {
+	h := NewEqualHashTable()
+	for i := 0; i < 100; i++ {
+		h.Set(List(Number(i)), Number(i*i))
+	}
+	for i := 0; i < 100; i += 2 {
+		if ok, _ := h.Delete(List(Number(i))); !ok {
+			t.Fatalf("expected %d to be deleted", i)
+		}
+	}
+	if h.Len() != 50 {
+		t.Fatalf("expected %d, but got %d", 50, h.Len())
+	}
+	v, ok, _ := h.Get(List(Number(7)))
+	if !ok || v != Number(49) {
+		t.Fatalf("expected %d, but got %v", 49, v)
+	}
+	if _, ok, _ := h.Get(List(Number(8))); ok {
+		t.Fatalf("expected %d not to exist", 8)
+	}
+	keys := h.Keys()
+	if len(keys) != 50 || !Equal(keys[0], List(Number(1))) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	c := h.Copy()
+	c.Set(List(Number(1)), Number(0))
+	if v, _, _ := h.Get(List(Number(1))); v != Number(1) {
+		t.Fatalf("expected %d, but got %v", 1, v)
+	}
+}
@@ -24,6 +24,22 @@ const (
 	TyVector
 	TyUndefined
 	TyError
+	TyChar
+	TyBytevector
+	TyHashTable
+	TyValues
+	TyEOF
+	TyPromise
+	TyStreamPair
+	TyRegexp
+	TyRegexpMatch
+	TyRecordType
+	TyRecord
+	TyPort
+	TyJSONNull
+	TyTime
+	TyDate
+	TyRandomSource
 
 	TyCallInfo // for internal use
 )
@@ -41,6 +57,22 @@ var typeProps = []*typeProp{
 	&typeProp{TyVector, "vector"},
 	&typeProp{TyUndefined, "undefined"},
 	&typeProp{TyError, "error"},
+	&typeProp{TyChar, "char"},
+	&typeProp{TyBytevector, "bytevector"},
+	&typeProp{TyHashTable, "hash-table"},
+	&typeProp{TyValues, "values"},
+	&typeProp{TyEOF, "eof"},
+	&typeProp{TyPromise, "promise"},
+	&typeProp{TyStreamPair, "stream-pair"},
+	&typeProp{TyRegexp, "regexp"},
+	&typeProp{TyRegexpMatch, "regexp-match"},
+	&typeProp{TyRecordType, "record-type"},
+	&typeProp{TyRecord, "record"},
+	&typeProp{TyPort, "port"},
+	&typeProp{TyJSONNull, "json-null"},
+	&typeProp{TyTime, "time"},
+	&typeProp{TyDate, "date"},
+	&typeProp{TyRandomSource, "random-source"},
 	&typeProp{TyCallInfo, "callinfo"},
 }
 
@@ -60,13 +92,14 @@ type SlicableObject interface {
 
 type (
 	Number float64
-	String string
 	Nil    struct{}
 	Symbol struct {
-		Name String
+		Name string
 	}
 	Boolean   bool
 	Undefined struct{}
+	EOF       struct{}
+	JSONNull  struct{}
 )
 
 func (num Number) String() string {
@@ -75,20 +108,12 @@ func (num Number) String() string {
 
 func (num Number) Type() ObjectType { return TyNumber }
 
-func (s String) String() string {
-	return string(s)
-}
-
-func (s String) Type() ObjectType {
-	return TyString
-}
-
 func NewSymbol(name string) *Symbol {
-	return &Symbol{Name: String(name)}
+	return &Symbol{Name: name}
 }
 
 func (s *Symbol) String() string {
-	return s.Name.String()
+	return s.Name
 }
 
 func (s *Symbol) Type() ObjectType {
@@ -129,3 +154,25 @@ func (un *Undefined) String() string {
 }
 
 var UndefinedObject = &Undefined{}
+
+func (e *EOF) Type() ObjectType {
+	return TyEOF
+}
+
+func (e *EOF) String() string {
+	return "#<eof>"
+}
+
+// EOFObject is the end of file object. It is also returned by exhausted generators.
+var EOFObject = &EOF{}
+
+func (n *JSONNull) Type() ObjectType {
+	return TyJSONNull
+}
+
+func (n *JSONNull) String() string {
+	return "#<json-null>"
+}
+
+// JSONNullObject represents null in JSON, distinguished from '() and #f.
+var JSONNullObject = &JSONNull{}
@@ -8,7 +8,8 @@ func TestPairString(t *testing.T) {
 		str    string
 	}{
 		{Cons(Number(1), Number(2)), "(1 . 2)"},
-		{Cons(Number(1), Cons(Number(2), NilObject)), "(1 . (2 . ()))"},
+		{Cons(Number(1), Cons(Number(2), NilObject)), "(1 2)"},
+		{List(List(Number(1)), NewVector([]Object{Number(2), NewBytevector([]byte{3})})), "((1) #(2 #u8(3)))"},
 	}
 
 	for i, tc := range testcases {
@@ -2,45 +2,118 @@ package types
 
 import (
 	"fmt"
+	"strings"
 )
 
 type Pair struct {
-	car Object
-	cdr Object
+	car       Object
+	cdr       Object
+	immutable bool
 }
 
+// String returns the list notation of p, such as (1 2) or (1 . 2).
+// A reference to an enclosing pair or vector is printed as "...", so that
+// cyclic lists terminate. The printer package prints them with datum labels.
 func (p *Pair) String() string {
-	return fmt.Sprintf("(%s . %s)", p.car.String(), p.cdr.String())
+	var b strings.Builder
+	writeObject(&b, p, map[Object]bool{})
+	return b.String()
+}
+
+// writeObject writes the list or vector notation of obj.
+// path holds the pairs and vectors enclosing obj.
+func writeObject(b *strings.Builder, obj Object, path map[Object]bool) {
+	switch o := obj.(type) {
+	case *Pair:
+		if path[o] {
+			b.WriteString("...")
+			return
+		}
+		b.WriteByte('(')
+		var rest Object = o
+		var visited []Object
+		for pair, ok := rest.(*Pair); ok && !path[pair]; pair, ok = rest.(*Pair) {
+			if rest != o {
+				b.WriteByte(' ')
+			}
+			path[pair] = true
+			visited = append(visited, pair)
+			writeObject(b, pair.car, path)
+			rest = pair.cdr
+		}
+		if rest != NilObject {
+			b.WriteString(" . ")
+			writeObject(b, rest, path)
+		}
+		b.WriteByte(')')
+		for _, pair := range visited {
+			delete(path, pair)
+		}
+	case *Vector:
+		if path[o] {
+			b.WriteString("...")
+			return
+		}
+		path[o] = true
+		b.WriteString("#(")
+		for i, elem := range o.elems {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeObject(b, elem, path)
+		}
+		b.WriteByte(')')
+		delete(path, o)
+	default:
+		b.WriteString(obj.String())
+	}
 }
 
 func (p *Pair) Type() ObjectType {
 	return TyPair
 }
 
-func (p *Pair) Slice() ([]Object, error) {
-	arr := []Object{}
-	pair := p
-	var ok bool
-	for pair.cdr.Type() != TyNil {
-		arr = append(arr, pair.car)
-		pair, ok = pair.cdr.(*Pair)
+// length returns the number of the pairs in the cdr chain of p and the last cdr.
+// n is -1 if the chain is circular.
+func (p *Pair) length() (n int, tail Object) {
+	slow := p
+	var obj Object = p
+	for {
+		pair, ok := obj.(*Pair)
 		if !ok {
-			return arr, fmt.Errorf("%v is not slicable object", p)
+			return n, obj
 		}
+		obj = pair.cdr
+		n++
+		// the slow pointer advances at half the speed, and meets obj if the chain is circular
+		if n%2 == 0 {
+			slow = slow.cdr.(*Pair)
+			if obj == Object(slow) {
+				return -1, nil
+			}
+		}
+	}
+}
+
+func (p *Pair) Slice() ([]Object, error) {
+	n, tail := p.length()
+	if n < 0 {
+		return nil, fmt.Errorf("%v is a circular list", p)
+	}
+	if tail != NilObject {
+		return nil, fmt.Errorf("%v is not slicable object", p)
+	}
+	arr := make([]Object, 0, n)
+	for obj := Object(p); obj != NilObject; obj = obj.(*Pair).cdr {
+		arr = append(arr, obj.(*Pair).car)
 	}
-	arr = append(arr, pair.car)
 	return arr, nil
 }
 
+// Len returns the number of the pairs in the cdr chain of p, or -1 if the chain is circular.
 func (p *Pair) Len() int {
-	len := 1
-	cdr := p.cdr
-	for cdr.Type() == TyPair {
-		len++
-		p := cdr.(*Pair)
-		cdr = p.cdr
-	}
-	return len
+	n, _ := p.length()
+	return n
 }
 
 func (p *Pair) Car() Object {
@@ -51,6 +124,38 @@ func (p *Pair) Cdr() Object {
 	return p.cdr
 }
 
+func (p *Pair) checkMutable() error {
+	if p.immutable {
+		return NewInternalError("attempt to modify an immutable pair")
+	}
+	return nil
+}
+
+func (p *Pair) SetCar(obj Object) error {
+	if err := p.checkMutable(); err != nil {
+		return err
+	}
+	p.car = obj
+	return nil
+}
+
+func (p *Pair) SetCdr(obj Object) error {
+	if err := p.checkMutable(); err != nil {
+		return err
+	}
+	p.cdr = obj
+	return nil
+}
+
+// Freeze makes p immutable.
+func (p *Pair) Freeze() {
+	p.immutable = true
+}
+
+func (p *Pair) IsImmutable() bool {
+	return p.immutable
+}
+
 func (p *Pair) Cdar() (Object, error) {
 	cdr, ok := p.cdr.(*Pair)
 	if !ok {
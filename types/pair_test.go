@@ -87,3 +87,77 @@ func TestCarCdr(t *testing.T) {
 		}
 	}
 }
+
+func TestSetCar(t *testing.T) {
+	p := Cons(Number(1), Number(2))
+	if err := p.SetCar(Number(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetCdr(NilObject); err != nil {
+		t.Fatal(err)
+	}
+	if p.Car().String() != "3" || p.Cdr() != NilObject {
+		t.Fatalf("unexpected pair %v", p)
+	}
+	p.Freeze()
+	if err := p.SetCar(Number(4)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if p.Car().String() != "3" {
+		t.Fatalf("expected %s, but got %s", "3", p.Car().String())
+	}
+}
+
+func TestListWithTail(t *testing.T) {
+	l := ListWithTail(Number(3), Number(1), Number(2))
+	if l.String() != "(1 2 . 3)" {
+		t.Fatalf("unexpected list %v", l)
+	}
+	if ListWithTail(Number(3)).String() != "3" {
+		t.Fatalf("expected tail only")
+	}
+}
+
+func TestCircularList(t *testing.T) {
+	for n := 1; n <= 4; n++ {
+		elems := make([]Object, n)
+		for i := range elems {
+			elems[i] = Number(i)
+		}
+		l := List(elems...).(*Pair)
+		last := l
+		for last.Cdr() != NilObject {
+			last = last.Cdr().(*Pair)
+		}
+		if l.Len() != n {
+			t.Fatalf("expected %d, but got %d", n, l.Len())
+		}
+		last.SetCdr(l)
+		if l.Len() != -1 {
+			t.Fatalf("expected -1 for a circular list of %d pairs, but got %d", n, l.Len())
+		}
+		if _, err := l.Slice(); err == nil {
+			t.Fatalf("expected error for a circular list of %d pairs", n)
+		}
+		if IsList(l) {
+			t.Fatalf("expected a circular list not to be a list")
+		}
+	}
+}
+
+func TestCircularString(t *testing.T) {
+	l := List(Number(1), Number(2)).(*Pair)
+	l.Cdr().(*Pair).SetCdr(l)
+	if l.String() != "(1 2 . ...)" {
+		t.Fatalf("unexpected string %s", l.String())
+	}
+	v := NewVector([]Object{Number(1), NilObject})
+	v.Set(1, Cons(v, NilObject))
+	if v.String() != "#(1 (...))" {
+		t.Fatalf("unexpected string %s", v.String())
+	}
+	shared := List(Number(1))
+	if s := List(shared, shared).String(); s != "((1) (1))" {
+		t.Fatalf("unexpected string %s", s)
+	}
+}
@@ -0,0 +1,346 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// Port is an input or output port over io.Reader or io.Writer.
+// A port is either textual or binary.
+type Port struct {
+	r      *bufio.Reader // nil if the port is not an input port
+	w      io.Writer     // nil if the port is not an output port
+	binary bool
+	closed bool
+	close  func() error // releases the underlying file, or nil
+	// position of the next character read from the textual input port
+	line, column         int
+	prevLine, prevColumn int // position before the last character, for UnreadRune
+}
+
+// NewInputPort creates an input port reading from r.
+func NewInputPort(r io.Reader, binary bool) *Port {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Port{r: br, binary: binary, line: 1, column: 1}
+}
+
+// NewOutputPort creates an output port writing to w.
+// The port is not buffered unless w is buffered.
+func NewOutputPort(w io.Writer, binary bool) *Port {
+	return &Port{w: w, binary: binary}
+}
+
+// NewFileInputPort creates an input port reading from f.
+// Closing the port closes f.
+func NewFileInputPort(f io.ReadCloser, binary bool) *Port {
+	p := NewInputPort(f, binary)
+	p.close = f.Close
+	return p
+}
+
+// NewFileOutputPort creates a buffered output port writing to f.
+// Closing the port flushes the buffer and closes f.
+func NewFileOutputPort(f io.WriteCloser, binary bool) *Port {
+	w := bufio.NewWriter(f)
+	p := NewOutputPort(w, binary)
+	p.close = func() error {
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return p
+}
+
+// NewOutputStringPort creates a textual output port which accumulates the characters.
+func NewOutputStringPort() *Port {
+	return NewOutputPort(&strings.Builder{}, false)
+}
+
+// NewOutputBytevectorPort creates a binary output port which accumulates the bytes.
+func NewOutputBytevectorPort() *Port {
+	return NewOutputPort(&bytes.Buffer{}, true)
+}
+
+// OutputString returns the string accumulated in the port created by NewOutputStringPort.
+// ok is false if p is not such a port.
+func (p *Port) OutputString() (str string, ok bool) {
+	b, ok := p.w.(*strings.Builder)
+	if !ok {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// OutputBytes returns a copy of the bytes accumulated in the port created by NewOutputBytevectorPort.
+// ok is false if p is not such a port.
+func (p *Port) OutputBytes() (bs []byte, ok bool) {
+	b, ok := p.w.(*bytes.Buffer)
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(b.Bytes()), true
+}
+
+func (p *Port) Type() ObjectType {
+	return TyPort
+}
+
+func (p *Port) String() string {
+	return "port"
+}
+
+func (p *Port) IsInput() bool {
+	return p.r != nil
+}
+
+func (p *Port) IsOutput() bool {
+	return p.w != nil
+}
+
+func (p *Port) IsBinary() bool {
+	return p.binary
+}
+
+func (p *Port) IsOpen() bool {
+	return !p.closed
+}
+
+// Close closes the port. Closing a closed port has no effect.
+func (p *Port) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.close != nil {
+		if err := p.close(); err != nil {
+			return NewFileError("%v", err)
+		}
+	}
+	return nil
+}
+
+func (p *Port) checkInput(binary bool) error {
+	switch {
+	case !p.IsInput():
+		return NewTypeError("input port required, but got output port")
+	case binary && !p.binary:
+		return NewTypeError("binary input port required, but got textual port")
+	case !binary && p.binary:
+		return NewTypeError("textual input port required, but got binary port")
+	case p.closed:
+		return NewInternalError("port is closed")
+	}
+	return nil
+}
+
+func (p *Port) checkOutput(binary bool) error {
+	switch {
+	case !p.IsOutput():
+		return NewTypeError("output port required, but got input port")
+	case binary && !p.binary:
+		return NewTypeError("binary output port required, but got textual port")
+	case !binary && p.binary:
+		return NewTypeError("textual output port required, but got binary port")
+	case p.closed:
+		return NewInternalError("port is closed")
+	}
+	return nil
+}
+
+// readError converts the error of the underlying reader. io.EOF is returned as is.
+func readError(err error) error {
+	if err == io.EOF {
+		return err
+	}
+	return NewInternalError("%v", err)
+}
+
+// Position returns the line and the column of the next character
+// read from the textual input port.
+func (p *Port) Position() (line, column int) {
+	return p.line, p.column
+}
+
+// advance updates the position after reading str.
+func (p *Port) advance(str string) {
+	p.prevLine, p.prevColumn = p.line, p.column
+	for _, r := range str {
+		if r == '\n' {
+			p.line++
+			p.column = 1
+		} else {
+			p.column++
+		}
+	}
+}
+
+// ReadRune reads a character from the textual port and implements io.RuneReader.
+// It returns io.EOF at the end.
+func (p *Port) ReadRune() (r rune, size int, err error) {
+	if err := p.checkInput(false); err != nil {
+		return 0, 0, err
+	}
+	r, size, err = p.r.ReadRune()
+	if err != nil {
+		return 0, 0, readError(err)
+	}
+	p.advance(string(r))
+	return r, size, nil
+}
+
+// UnreadRune unreads the last character read by ReadRune and implements io.RuneScanner.
+func (p *Port) UnreadRune() error {
+	if err := p.checkInput(false); err != nil {
+		return err
+	}
+	if err := p.r.UnreadRune(); err != nil {
+		return err
+	}
+	p.line, p.column = p.prevLine, p.prevColumn
+	return nil
+}
+
+// ReadChar reads a character. It returns io.EOF at the end.
+func (p *Port) ReadChar() (rune, error) {
+	r, _, err := p.ReadRune()
+	return r, err
+}
+
+// PeekChar returns the next character without consuming it. It returns io.EOF at the end.
+func (p *Port) PeekChar() (rune, error) {
+	r, err := p.ReadChar()
+	if err != nil {
+		return 0, err
+	}
+	p.UnreadRune()
+	return r, nil
+}
+
+// UnreadChar unreads the last character read by ReadChar.
+func (p *Port) UnreadChar() error {
+	return p.UnreadRune()
+}
+
+// ReadLine reads a line without the line ending. It returns io.EOF at the end.
+func (p *Port) ReadLine() (string, error) {
+	if err := p.checkInput(false); err != nil {
+		return "", err
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", readError(err)
+	}
+	p.advance(line)
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// ReadString reads at most k characters. It returns io.EOF at the end.
+func (p *Port) ReadString(k int) (string, error) {
+	if err := p.checkInput(false); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := 0; i < k; i++ {
+		r, _, err := p.r.ReadRune()
+		if err == io.EOF && b.Len() > 0 {
+			break
+		}
+		if err != nil {
+			return "", readError(err)
+		}
+		b.WriteRune(r)
+	}
+	p.advance(b.String())
+	return b.String(), nil
+}
+
+// ReadByte reads a byte from the binary port. It returns io.EOF at the end.
+func (p *Port) ReadByte() (byte, error) {
+	if err := p.checkInput(true); err != nil {
+		return 0, err
+	}
+	c, err := p.r.ReadByte()
+	if err != nil {
+		return 0, readError(err)
+	}
+	return c, nil
+}
+
+// PeekByte returns the next byte without consuming it. It returns io.EOF at the end.
+func (p *Port) PeekByte() (byte, error) {
+	if err := p.checkInput(true); err != nil {
+		return 0, err
+	}
+	bs, err := p.r.Peek(1)
+	if err != nil {
+		return 0, readError(err)
+	}
+	return bs[0], nil
+}
+
+// ReadBytes reads at most k bytes. It returns io.EOF at the end.
+func (p *Port) ReadBytes(k int) ([]byte, error) {
+	if err := p.checkInput(true); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, k)
+	n, err := io.ReadFull(p.r, buf)
+	if n > 0 || k == 0 {
+		return buf[:n], nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return nil, readError(err)
+}
+
+// WriteString writes str to the textual port.
+func (p *Port) WriteString(str string) error {
+	if err := p.checkOutput(false); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(p.w, str); err != nil {
+		return NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// WriteChar writes the character r to the textual port.
+func (p *Port) WriteChar(r rune) error {
+	return p.WriteString(string(r))
+}
+
+// WriteBytes writes bs to the binary port.
+func (p *Port) WriteBytes(bs []byte) error {
+	if err := p.checkOutput(true); err != nil {
+		return err
+	}
+	if _, err := p.w.Write(bs); err != nil {
+		return NewInternalError("%v", err)
+	}
+	return nil
+}
+
+// Flush flushes the underlying writer if it is buffered.
+func (p *Port) Flush() error {
+	if !p.IsOutput() {
+		return NewTypeError("output port required, but got input port")
+	}
+	if p.closed {
+		return NewInternalError("port is closed")
+	}
+	if f, ok := p.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return NewInternalError("%v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPortReadBytes(t *testing.T) {
+	p := NewInputPort(bytes.NewReader([]byte{1, 2, 3}), true)
+	bs, err := p.ReadBytes(2)
+	if err != nil || !bytes.Equal(bs, []byte{1, 2}) {
+		t.Fatalf("expected [1 2], but got %v, %v", bs, err)
+	}
+	if b, err := p.PeekByte(); err != nil || b != 3 {
+		t.Fatalf("expected 3, but got %v, %v", b, err)
+	}
+	if bs, err = p.ReadBytes(2); err != nil || !bytes.Equal(bs, []byte{3}) {
+		t.Fatalf("expected [3], but got %v, %v", bs, err)
+	}
+	if _, err := p.ReadBytes(2); err != io.EOF {
+		t.Fatalf("expected io.EOF, but got %v", err)
+	}
+	if _, err := p.ReadChar(); err == nil {
+		t.Fatalf("expected error for reading a character from a binary port")
+	}
+}
+
+func TestPortUnreadChar(t *testing.T) {
+	p := NewInputPort(strings.NewReader("日本"), false)
+	r, _ := p.ReadChar()
+	if err := p.UnreadChar(); err != nil {
+		t.Fatal(err)
+	}
+	if r2, _ := p.ReadChar(); r2 != r || r != '日' {
+		t.Fatalf("expected %c, but got %c", '日', r2)
+	}
+	p.Close()
+	if _, err := p.ReadChar(); err == nil {
+		t.Fatalf("expected error for reading a closed port")
+	}
+}
+
+func TestPortPosition(t *testing.T) {
+	p := NewInputPort(strings.NewReader("ab\ncd\nef"), false)
+	p.ReadChar()
+	p.PeekChar()
+	if line, column := p.Position(); line != 1 || column != 2 {
+		t.Fatalf("expected 1:2, but got %d:%d", line, column)
+	}
+	p.ReadLine()
+	p.ReadString(2)
+	if line, column := p.Position(); line != 2 || column != 3 {
+		t.Fatalf("expected 2:3, but got %d:%d", line, column)
+	}
+	p.ReadChar()
+	p.UnreadChar()
+	if line, column := p.Position(); line != 2 || column != 3 {
+		t.Fatalf("expected 2:3 after unreading the newline, but got %d:%d", line, column)
+	}
+}
+
+func TestPortWrite(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewOutputPort(&buf, false)
+	p.WriteString("ab")
+	p.WriteChar('c')
+	if buf.String() != "abc" {
+		t.Fatalf("expected %q, but got %q", "abc", buf.String())
+	}
+	if err := p.WriteBytes([]byte{1}); err == nil {
+		t.Fatalf("expected error for writing bytes to a textual port")
+	}
+}
@@ -0,0 +1,36 @@
+package types
+
+import "fmt"
+
+// Pos is a position in a source. Line and Column start from 1.
+// The zero value means the position is unknown.
+type Pos struct {
+	File   string // empty if the source has no name
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position in the form of file:line:column, or line:column without the file.
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return ""
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Span is the range of a datum in a source. End is the position just after the datum.
+type Span struct {
+	Start, End Pos
+}
+
+// SourceMap records the spans of the lists read from a source, keyed by their first pairs.
+// Keeping the spans aside leaves the representation of the data unchanged.
+type SourceMap map[*Pair]Span
@@ -0,0 +1,79 @@
+package types
+
+// promiseContent is the state of a promise.
+// Promises chained by delay-force share the same content,
+// so that forcing a long chain runs in constant space. (SRFI-45)
+type promiseContent struct {
+	done  bool
+	value Object // the value if done, otherwise the thunk
+	lazy  bool   // the thunk returns a promise (delay-force)
+}
+
+// Promise is a promise created by delay, delay-force or make-promise.
+type Promise struct {
+	content *promiseContent
+}
+
+// NewPromise creates a promise which is not forced yet.
+// If lazy is true, thunk must return a promise, which is forced in turn. (delay-force)
+func NewPromise(thunk Object, lazy bool) *Promise {
+	return &Promise{content: &promiseContent{value: thunk, lazy: lazy}}
+}
+
+// NewForcedPromise creates a promise which is already forced to value.
+func NewForcedPromise(value Object) *Promise {
+	return &Promise{content: &promiseContent{done: true, value: value}}
+}
+
+func (p *Promise) Type() ObjectType {
+	return TyPromise
+}
+
+func (p *Promise) String() string {
+	return "promise"
+}
+
+// IsDone reports whether p is already forced.
+func (p *Promise) IsDone() bool {
+	return p.content.done
+}
+
+// Value returns the value of a forced promise.
+func (p *Promise) Value() Object {
+	return p.content.value
+}
+
+// Thunk returns the thunk of a promise which is not forced yet,
+// and whether the thunk returns a promise.
+func (p *Promise) Thunk() (Object, bool) {
+	return p.content.value, p.content.lazy
+}
+
+// Resolve forces p to value.
+func (p *Promise) Resolve(value Object) {
+	p.content.done = true
+	p.content.value = value
+	p.content.lazy = false
+}
+
+// Merge makes p and q share the state of q.
+// It is called when the thunk of p returns q.
+func (p *Promise) Merge(q *Promise) {
+	*p.content = *q.content
+	q.content = p.content
+}
+
+// StreamPair is the value of a forced non-empty stream. (SRFI-41)
+// Car is a promise of the first element, and Cdr is the rest of the stream.
+type StreamPair struct {
+	Car *Promise
+	Cdr *Promise
+}
+
+func (sp *StreamPair) Type() ObjectType {
+	return TyStreamPair
+}
+
+func (sp *StreamPair) String() string {
+	return "stream-pair"
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"math/rand/v2"
+)
+
+// RandomSource is a source of pseudo random numbers. (SRFI-27)
+type RandomSource struct {
+	pcg *rand.PCG
+	*rand.Rand
+}
+
+// NewRandomSource creates a random source seeded with seed1 and seed2.
+func NewRandomSource(seed1, seed2 uint64) *RandomSource {
+	pcg := rand.NewPCG(seed1, seed2)
+	return &RandomSource{pcg: pcg, Rand: rand.New(pcg)}
+}
+
+// Seed resets the state as if it were created by NewRandomSource(seed1, seed2).
+func (r *RandomSource) Seed(seed1, seed2 uint64) {
+	r.pcg.Seed(seed1, seed2)
+}
+
+// State returns the state of the generator, which can be restored by SetState.
+func (r *RandomSource) State() []byte {
+	state, _ := r.pcg.MarshalBinary()
+	return state
+}
+
+// SetState restores the state returned by State.
+func (r *RandomSource) SetState(state []byte) error {
+	if err := r.pcg.UnmarshalBinary(state); err != nil {
+		return NewTypeError("invalid random source state")
+	}
+	return nil
+}
+
+func (r *RandomSource) Type() ObjectType {
+	return TyRandomSource
+}
+
+func (r *RandomSource) String() string {
+	return "random-source"
+}
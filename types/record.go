@@ -0,0 +1,48 @@
+package types
+
+// RecordType is the type of records defined by define-record-type.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+func NewRecordType(name string, fields []string) *RecordType {
+	return &RecordType{Name: name, Fields: fields}
+}
+
+func (rt *RecordType) Type() ObjectType {
+	return TyRecordType
+}
+
+func (rt *RecordType) String() string {
+	return "record-type"
+}
+
+// FieldIndex returns the index of the field name, or -1.
+func (rt *RecordType) FieldIndex(name string) int {
+	for i, field := range rt.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record is an instance of a record type.
+type Record struct {
+	RecordType *RecordType
+	Fields     []Object
+}
+
+// NewRecord creates a record of rt. The number of fields must be len(rt.Fields).
+func NewRecord(rt *RecordType, fields []Object) *Record {
+	return &Record{RecordType: rt, Fields: fields}
+}
+
+func (r *Record) Type() ObjectType {
+	return TyRecord
+}
+
+func (r *Record) String() string {
+	return "record"
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// Regexp is a compiled regular expression.
+type Regexp struct {
+	re       *regexp.Regexp
+	anchored *regexp.Regexp // re matching the whole string; compiled lazily
+}
+
+func NewRegexp(re *regexp.Regexp) *Regexp {
+	return &Regexp{re: re}
+}
+
+func (r *Regexp) Type() ObjectType {
+	return TyRegexp
+}
+
+func (r *Regexp) String() string {
+	return "regexp"
+}
+
+// Regexp returns the underlying go regexp.
+func (r *Regexp) Regexp() *regexp.Regexp {
+	return r.re
+}
+
+// Anchored returns the regexp which matches only the whole string.
+func (r *Regexp) Anchored() *regexp.Regexp {
+	if r.anchored == nil {
+		r.anchored = regexp.MustCompile(`^(?:` + r.re.String() + `)$`)
+	}
+	return r.anchored
+}
+
+// RegexpMatch is the result of a successful match.
+// Submatch 0 is the whole match.
+type RegexpMatch struct {
+	str     string // the searched string
+	offset  int    // the index of the character at str[0] in the original string
+	indices []int  // byte offsets of the submatches in str, as returned by go regexp
+	names   []string
+}
+
+// NewRegexpMatch creates a match of re on str.
+// indices are the result of re.FindStringSubmatchIndex(str), and offset is
+// the index of the first character of str in the string the user passed.
+func NewRegexpMatch(re *regexp.Regexp, str string, indices []int, offset int) *RegexpMatch {
+	return &RegexpMatch{str: str, offset: offset, indices: indices, names: re.SubexpNames()}
+}
+
+func (m *RegexpMatch) Type() ObjectType {
+	return TyRegexpMatch
+}
+
+func (m *RegexpMatch) String() string {
+	return "regexp-match"
+}
+
+// Count returns the number of submatches, not counting the whole match.
+func (m *RegexpMatch) Count() int {
+	return len(m.indices)/2 - 1
+}
+
+// Index returns the submatch index of name, or -1.
+func (m *RegexpMatch) Index(name string) int {
+	for i, n := range m.names {
+		if n != "" && n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Submatch returns the i-th submatch. ok is false if the submatch did not participate in the match.
+func (m *RegexpMatch) Submatch(i int) (sub string, ok bool) {
+	start, end := m.indices[2*i], m.indices[2*i+1]
+	if start < 0 {
+		return "", false
+	}
+	return m.str[start:end], true
+}
+
+// Bounds returns the character indices of the i-th submatch in the original string.
+// ok is false if the submatch did not participate in the match.
+func (m *RegexpMatch) Bounds(i int) (start int, end int, ok bool) {
+	s, e := m.indices[2*i], m.indices[2*i+1]
+	if s < 0 {
+		return 0, 0, false
+	}
+	start = m.offset + utf8.RuneCountInString(m.str[:s])
+	end = start + utf8.RuneCountInString(m.str[s:e])
+	return start, end, true
+}
+
+// Prefix returns the part of the searched string before the match.
+func (m *RegexpMatch) Prefix() string {
+	return m.str[:m.indices[0]]
+}
+
+// Suffix returns the part of the searched string after the match.
+func (m *RegexpMatch) Suffix() string {
+	return m.str[m.indices[1]:]
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"unicode/utf8"
+)
+
+// String is a scheme string.
+//
+// The characters are kept in a go string until the string is mutated, so
+// passing a string to go code is cheap. Strings consisting of ASCII
+// characters only are indexed directly on the go string; the others are
+// converted to a rune slice the first time they are indexed.
+type String struct {
+	str       string // go representation; stale while dirty is true
+	runes     []rune // character representation; nil until it is required
+	ascii     bool
+	dirty     bool
+	immutable bool
+}
+
+func NewString(s string) *String {
+	return &String{str: s, ascii: isASCII(s)}
+}
+
+func NewStringFromRunes(runes []rune) *String {
+	return &String{runes: runes, dirty: true}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the go string of s.
+func (s *String) String() string {
+	if s.dirty {
+		s.str = string(s.runes)
+		s.dirty = false
+	}
+	return s.str
+}
+
+func (s *String) Type() ObjectType {
+	return TyString
+}
+
+func (s *String) useRunes() bool {
+	return s.runes != nil || !s.ascii
+}
+
+func (s *String) toRunes() []rune {
+	if s.runes == nil {
+		s.runes = []rune(s.str)
+	}
+	return s.runes
+}
+
+// Len returns the number of characters in s.
+func (s *String) Len() int {
+	if s.runes != nil {
+		return len(s.runes)
+	}
+	if s.ascii {
+		return len(s.str)
+	}
+	return utf8.RuneCountInString(s.str)
+}
+
+// Ref returns the k-th character of s.
+// k must be in the range [0, s.Len()).
+func (s *String) Ref(k int) Char {
+	if !s.useRunes() {
+		return Char(s.str[k])
+	}
+	return Char(s.toRunes()[k])
+}
+
+// Runes returns a copy of the characters from start to end.
+func (s *String) Runes(start, end int) []rune {
+	runes := make([]rune, end-start)
+	if !s.useRunes() {
+		for i := start; i < end; i++ {
+			runes[i-start] = rune(s.str[i])
+		}
+		return runes
+	}
+	copy(runes, s.toRunes()[start:end])
+	return runes
+}
+
+// Substring returns the go string of the characters from start to end.
+func (s *String) Substring(start, end int) string {
+	if !s.useRunes() {
+		return s.String()[start:end]
+	}
+	return string(s.toRunes()[start:end])
+}
+
+func (s *String) checkMutable() error {
+	if s.immutable {
+		return NewInternalError("attempt to modify an immutable string")
+	}
+	return nil
+}
+
+// Set stores c as the k-th character of s.
+func (s *String) Set(k int, c Char) error {
+	if err := s.checkMutable(); err != nil {
+		return err
+	}
+	s.toRunes()[k] = rune(c)
+	s.dirty = true
+	return nil
+}
+
+// Fill stores c in every position of s from start to end.
+func (s *String) Fill(c Char, start, end int) error {
+	if err := s.checkMutable(); err != nil {
+		return err
+	}
+	runes := s.toRunes()
+	for i := start; i < end; i++ {
+		runes[i] = rune(c)
+	}
+	s.dirty = true
+	return nil
+}
+
+// CopyFrom copies the characters of from between start and end into s,
+// starting at at. Overlapping regions are handled correctly.
+func (s *String) CopyFrom(at int, from *String, start, end int) error {
+	if err := s.checkMutable(); err != nil {
+		return err
+	}
+	runes := from.Runes(start, end)
+	copy(s.toRunes()[at:], runes)
+	s.dirty = true
+	return nil
+}
+
+// Freeze makes s immutable.
+func (s *String) Freeze() {
+	s.immutable = true
+}
+
+func (s *String) IsImmutable() bool {
+	return s.immutable
+}
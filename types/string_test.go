@@ -0,0 +1,47 @@
+package types
+
+import "testing"
+
+func TestStringLen(t *testing.T) {
+	testcases := []struct {
+		str    *String
+		expect int
+	}{
+		{NewString(""), 0},
+		{NewString("hello"), 5},
+		{NewString("héllo"), 5},
+		{NewStringFromRunes([]rune("日本語")), 3},
+	}
+	for i, tc := range testcases {
+		if l := tc.str.Len(); l != tc.expect {
+			t.Fatalf("case %d: expected %d, but got %d", i, tc.expect, l)
+		}
+	}
+}
+
+func TestStringMutation(t *testing.T) {
+	s := NewString("héllo")
+	if err := s.Set(0, 'j'); err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != "jéllo" {
+		t.Fatalf("expected %s, but got %s", "jéllo", s.String())
+	}
+	if err := s.Fill('x', 3, 5); err != nil {
+		t.Fatal(err)
+	}
+	if s.String() != "jélxx" {
+		t.Fatalf("expected %s, but got %s", "jélxx", s.String())
+	}
+	if c := s.Ref(1); c != 'é' {
+		t.Fatalf("expected %c, but got %c", 'é', c)
+	}
+	if sub := s.Substring(1, 3); sub != "él" {
+		t.Fatalf("expected %s, but got %s", "él", sub)
+	}
+
+	s.Freeze()
+	if err := s.Set(0, 'a'); err == nil {
+		t.Fatalf("expected error")
+	}
+}
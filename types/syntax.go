@@ -3,12 +3,12 @@ package types
 import "fmt"
 
 type Syntax struct {
-	Name String
+	Name string
 	Fn   interface{}
 }
 
 func NewSyntax(name string, fn interface{}) *Syntax {
-	return &Syntax{String(name), fn}
+	return &Syntax{name, fn}
 }
 
 func (s *Syntax) Type() ObjectType {
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeType is the type of a time object, named as the corresponding symbol. (SRFI-19)
+type TimeType string
+
+const (
+	TimeUTC       TimeType = "time-utc"
+	TimeMonotonic TimeType = "time-monotonic"
+	TimeDuration  TimeType = "time-duration"
+)
+
+// Time is a point in time or a duration in nanosecond resolution. (SRFI-19)
+// Nanosecond is kept in [0, 1e9), so a negative duration has a negative Second.
+type Time struct {
+	TimeType   TimeType
+	Second     int64
+	Nanosecond int64
+}
+
+// NewTime creates a time, carrying the overflow of nanosecond into second.
+func NewTime(typ TimeType, second, nanosecond int64) *Time {
+	t := &Time{TimeType: typ, Second: second, Nanosecond: nanosecond}
+	t.normalize()
+	return t
+}
+
+// NewTimeFromGo creates a time of typ from the go time t.
+func NewTimeFromGo(typ TimeType, t time.Time) *Time {
+	return &Time{TimeType: typ, Second: t.Unix(), Nanosecond: int64(t.Nanosecond())}
+}
+
+func (t *Time) normalize() {
+	t.Second += t.Nanosecond / 1e9
+	t.Nanosecond %= 1e9
+	if t.Nanosecond < 0 {
+		t.Second--
+		t.Nanosecond += 1e9
+	}
+}
+
+// Set replaces the fields of t.
+func (t *Time) Set(typ TimeType, second, nanosecond int64) {
+	t.TimeType = typ
+	t.Second = second
+	t.Nanosecond = nanosecond
+	t.normalize()
+}
+
+// Compare returns -1, 0 or 1 as t is before, equal to or after u, ignoring the types.
+func (t *Time) Compare(u *Time) int {
+	switch {
+	case t.Second < u.Second || (t.Second == u.Second && t.Nanosecond < u.Nanosecond):
+		return -1
+	case t.Second == u.Second && t.Nanosecond == u.Nanosecond:
+		return 0
+	}
+	return 1
+}
+
+// GoTime returns the go time at t, regarding t as seconds since the Unix epoch.
+func (t *Time) GoTime() time.Time {
+	return time.Unix(t.Second, t.Nanosecond)
+}
+
+func (t *Time) Type() ObjectType {
+	return TyTime
+}
+
+func (t *Time) String() string {
+	sec, nsec := t.Second, t.Nanosecond
+	sign := ""
+	if sec < 0 {
+		sign = "-"
+		sec, nsec = -sec, -nsec
+		if nsec < 0 {
+			sec--
+			nsec += 1e9
+		}
+	}
+	return fmt.Sprintf("%s %s%d.%09d", t.TimeType, sign, sec, nsec)
+}
+
+// Date is a date and a time of day in a time zone. (SRFI-19)
+type Date struct {
+	Time time.Time
+}
+
+func NewDate(t time.Time) *Date {
+	return &Date{Time: t}
+}
+
+// ZoneOffset returns the offset of the time zone in seconds east of UTC.
+func (d *Date) ZoneOffset() int {
+	_, offset := d.Time.Zone()
+	return offset
+}
+
+func (d *Date) Type() ObjectType {
+	return TyDate
+}
+
+func (d *Date) String() string {
+	return "date " + d.Time.Format(time.RFC3339Nano)
+}
@@ -36,17 +36,14 @@ func IsClosure(obj Object) bool {
 	return obj.Type() == TyClosure
 }
 
+// IsList reports whether obj is a proper list. Circular lists are not.
 func IsList(obj Object) bool {
-	if IsNull(obj) {
-		return true
-	}
-	o := obj
-	pair, ok := o.(*Pair)
-	for ok {
-		o = pair.Cdr()
-		pair, ok = o.(*Pair)
+	pair, ok := obj.(*Pair)
+	if !ok {
+		return IsNull(obj)
 	}
-	return IsNull(o)
+	n, tail := pair.length()
+	return n >= 0 && IsNull(tail)
 }
 
 func Cons(car Object, cdr Object) *Pair {
@@ -57,13 +54,16 @@ func Cons(car Object, cdr Object) *Pair {
 }
 
 func List(args ...Object) Object {
-	if len(args) == 0 {
-		return NilObject
-	}
-	return &Pair{
-		car: args[0],
-		cdr: List(args[1:len(args)]...),
+	return ListWithTail(NilObject, args...)
+}
+
+// ListWithTail creates a list of args whose last cdr is tail.
+func ListWithTail(tail Object, args ...Object) Object {
+	list := tail
+	for i := len(args) - 1; i >= 0; i-- {
+		list = Cons(args[i], list)
 	}
+	return list
 }
 
 func AssertType(typ ObjectType, objs ...Object) error {
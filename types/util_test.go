@@ -35,12 +35,12 @@ func TestIs(t *testing.T) {
 		{Boolean(true), false, IsNumber},
 
 		// test IsString
-		{String("a"), true, IsString},
+		{NewString("a"), true, IsString},
 		{Number(1), false, IsString},
 
 		// test IsSymbol
 		{NewSymbol("a"), true, IsSymbol},
-		{String("a"), false, IsSymbol},
+		{NewString("a"), false, IsSymbol},
 
 		// test IsClosure
 		{NewScmClosure(nil, 0), true, IsClosure},
@@ -0,0 +1,28 @@
+package types
+
+import "strings"
+
+// Values is the multiple values returned by the values procedure.
+// A single value is never wrapped by Values.
+type Values struct {
+	Objs []Object
+}
+
+func NewValues(objs []Object) Object {
+	if len(objs) == 1 {
+		return objs[0]
+	}
+	return &Values{Objs: objs}
+}
+
+func (v *Values) Type() ObjectType {
+	return TyValues
+}
+
+func (v *Values) String() string {
+	strs := make([]string, len(v.Objs))
+	for i, obj := range v.Objs {
+		strs[i] = obj.String()
+	}
+	return strings.Join(strs, " ")
+}
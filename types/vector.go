@@ -1,11 +1,85 @@
 package types
 
-type Vector []Object
+import "strings"
 
-func (v Vector) Type() ObjectType {
+type Vector struct {
+	elems     []Object
+	immutable bool
+}
+
+func NewVector(elems []Object) *Vector {
+	return &Vector{elems: elems}
+}
+
+func (v *Vector) Type() ObjectType {
 	return TyVector
 }
 
-func (v Vector) String() string {
-	return "vector"
+// String returns the vector notation of v, such as #(1 2).
+// Cyclic references are printed as "..." like Pair.String.
+func (v *Vector) String() string {
+	var b strings.Builder
+	writeObject(&b, v, map[Object]bool{})
+	return b.String()
+}
+
+func (v *Vector) Len() int {
+	return len(v.elems)
+}
+
+// Elems returns the elements of v. The slice is shared with v.
+func (v *Vector) Elems() []Object {
+	return v.elems
+}
+
+// Ref returns the k-th element of v.
+// k must be in the range [0, v.Len()).
+func (v *Vector) Ref(k int) Object {
+	return v.elems[k]
+}
+
+func (v *Vector) checkMutable() error {
+	if v.immutable {
+		return NewInternalError("attempt to modify an immutable vector")
+	}
+	return nil
+}
+
+// Set stores obj as the k-th element of v.
+func (v *Vector) Set(k int, obj Object) error {
+	if err := v.checkMutable(); err != nil {
+		return err
+	}
+	v.elems[k] = obj
+	return nil
+}
+
+// Fill stores obj in every position of v from start to end.
+func (v *Vector) Fill(obj Object, start, end int) error {
+	if err := v.checkMutable(); err != nil {
+		return err
+	}
+	for i := start; i < end; i++ {
+		v.elems[i] = obj
+	}
+	return nil
+}
+
+// CopyFrom copies the elements of from between start and end into v,
+// starting at at. Overlapping regions are handled correctly.
+func (v *Vector) CopyFrom(at int, from *Vector, start, end int) error {
+	if err := v.checkMutable(); err != nil {
+		return err
+	}
+	copy(v.elems[at:], from.elems[start:end])
+	return nil
+}
+
+// Freeze makes v immutable.
+func (v *Vector) Freeze() {
+	v.immutable = true
+}
+
+func (v *Vector) IsImmutable() bool {
+	return v.immutable
 }
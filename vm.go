@@ -1,21 +1,52 @@
 package tama
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hyusuk/tama/compiler"
 	"github.com/hyusuk/tama/types"
 )
 
-func runVM(s *State, debug bool) error {
+func runVM(s *State, debug bool) (err error) {
 	nexeccalls := 1
 	nuated := false            // true if came back by using the continuation
 	var nuatedObj types.Object // argument of the continuation
+	var ci *types.CallInfo
+	var cl *types.Closure
+	s.vmSerial++
+	id := s.vmSerial
+	s.vms = append(s.vms, id)
+	defer func() {
+		s.vms = s.vms[:len(s.vms)-1]
+	}()
+	// resume resumes the continuation if err is the escape of a continuation owned by this loop.
+	resume := func(err error) bool {
+		var esc *continuationEscape
+		if !errors.As(err, &esc) || esc.vm != id {
+			return false
+		}
+		s.CallStack.Restore(esc.cont.CallStack)
+		s.CallInfos.Restore(esc.cont.CallInfos)
+		s.CallInfos.Top().(*types.CallInfo).Pc = esc.cont.Pc
+		nexeccalls = esc.cont.NExecCalls
+		nuated = true
+		nuatedObj = esc.arg
+		return true
+	}
+	defer func() {
+		// report the error at the instruction being executed unless it already has a position
+		if e, ok := err.(*types.Error); ok && !e.Position().IsValid() && ci != nil {
+			if pc := ci.Pc - 1; pc >= 0 && pc < len(cl.Proto.LineInfo) {
+				e.SetPosition(cl.Proto.LineInfo[pc])
+			}
+		}
+	}()
 reentry:
 	if debug {
 		fmt.Println("[Enter function]")
 	}
-	ci := s.CallInfos.Top().(*types.CallInfo)
-	cl := ci.Cl
+	ci = s.CallInfos.Top().(*types.CallInfo)
+	cl = ci.Cl
 	base := ci.Base
 	for {
 		inst := cl.Proto.Insts[ci.Pc]
@@ -149,10 +180,21 @@ reentry:
 			if debug {
 				fmt.Printf("%-20s ; R[%d] = %v(R[%d]...R[%d])\n", compiler.DumpInst(inst), ra, obj, ra+1, ra+b-1)
 			}
+			// expand apply in place, so that the procedure is called in the same way
+			// as a direct call. (e.g. a tail call via apply does not grow CallInfos)
+			for obj == s.applyCl {
+				if err := s.spreadApplyArgs(ra); err != nil {
+					return err
+				}
+				obj = s.CallStack.Get(ra)
+			}
 			switch o := obj.(type) {
 			case *types.Closure:
 				curCi, err := s.precall(ra)
 				if err != nil {
+					if resume(err) {
+						goto reentry
+					}
 					return err
 				}
 
@@ -171,6 +213,9 @@ reentry:
 						_ = s.CallInfos.Pop()
 						prevCi := s.CallInfos.Top().(*types.CallInfo)
 
+						// the closures created in the frame must keep its variables after it is reused
+						s.closeUpValues(prevCi.Base)
+
 						// place the current closure and arguments to the previous closure sp
 						s.CallStack.Set(prevCi.FuncSp, curCi.Cl)
 						for i := 0; i < nargs; i++ {
@@ -186,15 +231,83 @@ reentry:
 					}
 				}
 			case *types.Continuation:
-				cont := o
-				s.CallStack.Restore(cont.CallStack)
-				s.CallInfos.Restore(cont.CallInfos)
-				ci := s.CallInfos.Top().(*types.CallInfo)
-				ci.Pc = cont.Pc
-				nexeccalls = cont.NExecCalls
-				nuated = true
-				nuatedObj = s.CallStack.Get(ra + b - 1)
+				// a continuation captured by an outer loop escapes from the go functions between
+				if err := s.escape(o, s.CallStack.Top()); !resume(err) {
+					return err
+				}
 				goto reentry
+			default:
+				return types.NewInternalError("invalid application: %v", obj)
+			}
+		case compiler.OP_PROMISE:
+			rb := base + compiler.GetArgB(inst)
+			lazy := compiler.GetArgC(inst) == 1
+			p := types.NewPromise(s.CallStack.Get(rb), lazy)
+			s.CallStack.Set(ra, p)
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = promise(R[%d])\n", compiler.DumpInst(inst), ra, rb)
+			}
+		case compiler.OP_STREAMCONS:
+			rb := base + compiler.GetArgB(inst)
+			rc := base + compiler.GetArgC(inst)
+			car, _ := s.CallStack.Get(rb).(*types.Promise)
+			cdr, _ := s.CallStack.Get(rc).(*types.Promise)
+			s.CallStack.Set(ra, types.NewForcedPromise(&types.StreamPair{Car: car, Cdr: cdr}))
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = stream(R[%d], R[%d])\n", compiler.DumpInst(inst), ra, rb, rc)
+			}
+		case compiler.OP_MATCH:
+			rb := base + compiler.GetArgB(inst)
+			c := compiler.GetArgC(inst)
+			v, err := matchOperation(c, s.CallStack.Get(rb))
+			if err != nil {
+				return err
+			}
+			s.CallStack.Set(ra, v)
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = match(R[%d])\n", compiler.DumpInst(inst), ra, rb)
+			}
+		case compiler.OP_CONS:
+			rb := base + compiler.GetArgB(inst)
+			rc := base + compiler.GetArgC(inst)
+			s.CallStack.Set(ra, types.Cons(s.CallStack.Get(rb), s.CallStack.Get(rc)))
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = (R[%d] . R[%d])\n", compiler.DumpInst(inst), ra, rb, rc)
+			}
+		case compiler.OP_EQUAL:
+			rb := base + compiler.GetArgB(inst)
+			rc := base + compiler.GetArgC(inst)
+			s.CallStack.Set(ra, types.Boolean(types.Equal(s.CallStack.Get(rb), s.CallStack.Get(rc))))
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = equal?(R[%d], R[%d])\n", compiler.DumpInst(inst), ra, rb, rc)
+			}
+		case compiler.OP_SPLIT:
+			rb := base + compiler.GetArgB(inst)
+			c := compiler.GetArgC(inst)
+			head, rest, ok := splitList(s.CallStack.Get(rb), c)
+			if ok {
+				s.CallStack.Set(ra, head)
+				s.CallStack.Set(ra+1, rest)
+			} else {
+				s.CallStack.Set(ra, types.Boolean(false))
+			}
+			if debug {
+				fmt.Printf("%-20s ; R[%d], R[%d] = split(R[%d], %d)\n", compiler.DumpInst(inst), ra, ra+1, rb, c)
+			}
+		case compiler.OP_RECORDFIELDS:
+			rb := base + compiler.GetArgB(inst)
+			rc := base + compiler.GetArgC(inst)
+			rt, ok := s.CallStack.Get(rc).(*types.RecordType)
+			if !ok {
+				return types.NewTypeError("match: record type required, but got %v", s.CallStack.Get(rc))
+			}
+			var fields types.Object = types.Boolean(false)
+			if rec, ok := s.CallStack.Get(rb).(*types.Record); ok && rec.RecordType == rt {
+				fields = types.List(rec.Fields...)
+			}
+			s.CallStack.Set(ra, fields)
+			if debug {
+				fmt.Printf("%-20s ; R[%d] = fields(R[%d], R[%d])\n", compiler.DumpInst(inst), ra, rb, rc)
 			}
 		case compiler.OP_CALLCC:
 			if nuated {
@@ -208,7 +321,7 @@ reentry:
 				callinfos := s.CallInfos.Store(s.CallInfos.Sp())
 				callstack := s.CallStack.Store(s.CallStack.Sp())
 				pc := ci.Pc - 1
-				cont := types.NewContinuation(callinfos, callstack, pc, nexeccalls)
+				cont := types.NewContinuation(callinfos, callstack, pc, nexeccalls, id, len(s.vms))
 				// set the current continuation as an argument
 				s.CallStack.Set(ra+1, cont)
 				s.CallStack.SetSp(ra + 1)
@@ -220,6 +333,9 @@ reentry:
 				// same with OP_CALL
 				precalledCi, err := s.precall(ra)
 				if err != nil {
+					if resume(err) {
+						goto reentry
+					}
 					return err
 				}
 				if !precalledCi.Cl.IsGo {
@@ -229,5 +345,55 @@ reentry:
 			}
 		}
 	}
-	return nil
+}
+
+// matchOperation returns the result of the operation op of OP_MATCH on v.
+func matchOperation(op int, v types.Object) (types.Object, error) {
+	switch op {
+	case compiler.MatchPair:
+		return types.Boolean(v.Type() == types.TyPair), nil
+	case compiler.MatchNull:
+		return types.Boolean(types.IsNull(v)), nil
+	case compiler.MatchCar:
+		return v.(*types.Pair).Car(), nil
+	case compiler.MatchCdr:
+		return v.(*types.Pair).Cdr(), nil
+	case compiler.MatchVector:
+		if vec, ok := v.(*types.Vector); ok {
+			return types.List(vec.Elems()...), nil
+		}
+		return types.Boolean(false), nil
+	case compiler.MatchReverse:
+		var rev types.Object = types.NilObject
+		for p, ok := v.(*types.Pair); ok; p, ok = p.Cdr().(*types.Pair) {
+			rev = types.Cons(p.Car(), rev)
+		}
+		return rev, nil
+	case compiler.MatchError:
+		return nil, types.NewInternalError("match: no matching pattern for %v", v)
+	}
+	return nil, types.NewInternalError("invalid match operation %d", op)
+}
+
+// splitList splits list into the list of the elements except the last n elements and the rest.
+// ok is false if list has less than n elements or is circular.
+func splitList(list types.Object, n int) (head types.Object, rest types.Object, ok bool) {
+	if p, ok := list.(*types.Pair); ok && p.Len() < 0 {
+		return nil, nil, false
+	}
+	elems := []types.Object{}
+	rest = list
+	for p, ok := rest.(*types.Pair); ok; p, ok = rest.(*types.Pair) {
+		elems = append(elems, p.Car())
+		rest = p.Cdr()
+	}
+	if len(elems) < n {
+		return nil, nil, false
+	}
+	// the last n elements are in the rest
+	rest = list
+	for range len(elems) - n {
+		rest = rest.(*types.Pair).Cdr()
+	}
+	return types.List(elems[:len(elems)-n]...), rest, true
 }